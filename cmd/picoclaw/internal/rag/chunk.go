@@ -0,0 +1,43 @@
+package rag
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newChunkCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		ordinal       int
+		paragraph     string
+		contextRadius int
+	)
+
+	cmd := &cobra.Command{
+		Use:     "chunk [source-path]",
+		Short:   "Print one indexed chunk by reference",
+		Example: "picoclaw rag chunk notes/deploy.md --ordinal 2\npicoclaw rag chunk --paragraph 4f1c2e...",
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			if paragraph != "" {
+				return ragChunkByParagraphCmd(cmd.Context(), svc, paragraph)
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("source path required unless --paragraph is given")
+			}
+			return ragChunkCmd(cmd.Context(), svc, args[0], ordinal, contextRadius)
+		},
+	}
+
+	cmd.Flags().IntVar(&ordinal, "ordinal", 1, "1-based chunk number within the document")
+	cmd.Flags().StringVar(&paragraph, "paragraph", "", "resolve by stable paragraph id instead of path and ordinal")
+	cmd.Flags().IntVar(&contextRadius, "context", 0, "also print up to N neighboring chunks on each side")
+
+	return cmd
+}
@@ -0,0 +1,68 @@
+package rag
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+type deps struct {
+	svc *rag.Service
+}
+
+func NewRAGCommand() *cobra.Command {
+	var d deps
+
+	cmd := &cobra.Command{
+		Use:   "rag",
+		Short: "Search and manage the knowledge base index",
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			svc, err := rag.NewService(cfg.WorkspacePath(), cfg.Tools.RAG)
+			if err != nil {
+				return fmt.Errorf("error initializing RAG service: %w", err)
+			}
+			d.svc = svc
+			return nil
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, _ []string) error {
+			if d.svc != nil {
+				return d.svc.Close()
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	svcFn := func() (*rag.Service, error) {
+		if d.svc == nil {
+			return nil, fmt.Errorf("RAG service is not initialized")
+		}
+		return d.svc, nil
+	}
+
+	cmd.AddCommand(
+		newSearchCommand(svcFn),
+		newIndexCommand(svcFn),
+		newChunkCommand(svcFn),
+		newCompactCommand(svcFn),
+		newSimilarCommand(svcFn),
+		newStatsCommand(svcFn),
+		newStatusCommand(svcFn),
+		newServeCommand(svcFn),
+		newExportCommand(svcFn),
+		newGoldenInitCommand(svcFn),
+		newGoldenEvalCommand(svcFn),
+		newMigrateCommand(),
+	)
+
+	return cmd
+}
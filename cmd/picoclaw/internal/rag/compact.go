@@ -0,0 +1,23 @@
+package rag
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newCompactCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "compact",
+		Short: "Reclaim disk space used by the index",
+		Long:  "Rewrites the index database to drop free-page fragmentation left by repeated rebuilds and removes orphaned temp files.",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragCompactCmd(svc)
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newExportCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		output      string
+		withVectors bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the full index as JSON lines",
+		Long: "Streams every indexed chunk as one JSON object per line, including all metadata " +
+			"and security annotations. Embedding vectors are not included unless --with-vectors " +
+			"is given, in which case each line carries a base64-encoded vector_base64 field.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragExportCmd(cmd.Context(), svc, output, withVectors)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write to this file instead of stdout")
+	cmd.Flags().BoolVar(&withVectors, "with-vectors", false, "include base64-encoded embedding vectors")
+
+	return cmd
+}
+
+func ragExportCmd(ctx context.Context, svc *rag.Service, output string, withVectors bool) error {
+	if output == "" {
+		return svc.ExportChunks(ctx, os.Stdout, withVectors)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := svc.ExportChunks(ctx, f, withVectors); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+	return nil
+}
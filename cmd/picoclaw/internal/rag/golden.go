@@ -0,0 +1,178 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+	"github.com/sipeed/picoclaw/pkg/rag/eval"
+)
+
+func newGoldenInitCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		queriesPath string
+		outPath     string
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "golden-init",
+		Short: "Bootstrap a golden eval file from a list of queries",
+		Long: "Runs each query from --queries (one per line, # comments allowed) through Search and " +
+			"records the top result's document as a provisional relevance=1 judgment. The output is a " +
+			"curation starting point, not ground truth: review every judgment, fix the misses and " +
+			"adjust grades before evaluating against it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragGoldenInitCmd(cmd.Context(), svc, queriesPath, outPath, force)
+		},
+	}
+
+	cmd.Flags().StringVar(&queriesPath, "queries", "", "file with one query per line (required)")
+	cmd.Flags().StringVar(&outPath, "out", "golden.yml", "output YAML path")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite an existing output file")
+	cmd.MarkFlagRequired("queries")
+
+	return cmd
+}
+
+func newGoldenEvalCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		goldenPath string
+		k          int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "golden-eval",
+		Short: "Evaluate retrieval against a curated golden file",
+		Long: "Runs every judged query from --golden through Search and reports the standard ranking " +
+			"metrics. Judgments with grade 0 or below mark documents as known irrelevant: the command " +
+			"exits non-zero when any of them surfaces in the top k, so a regression that leaks a " +
+			"forbidden document fails the run instead of only nudging an average.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragGoldenEvalCmd(cmd.Context(), svc, goldenPath, k)
+		},
+	}
+
+	cmd.Flags().StringVar(&goldenPath, "golden", "golden.yml", "curated golden YAML file")
+	cmd.Flags().IntVar(&k, "k", 10, "metric cutoff")
+
+	return cmd
+}
+
+func ragGoldenEvalCmd(ctx context.Context, svc *rag.Service, goldenPath string, k int) error {
+	ds, err := eval.LoadGoldenDataset(goldenPath)
+	if err != nil {
+		return err
+	}
+	report, err := eval.EvaluateGolden(ctx, svc, ds, k)
+	if err != nil {
+		return err
+	}
+	if len(report.Queries) == 0 && len(report.Failures) == 0 {
+		return fmt.Errorf("%s contains no judged queries", goldenPath)
+	}
+
+	s := report.Summary
+	fmt.Printf("Evaluated %d queries at k=%d\n", len(report.Queries), report.K)
+	fmt.Printf("  Recall %.4f  nDCG %.4f  MRR %.4f  MAP %.4f  P@10 %.4f\n",
+		s.Recall, s.NDCG, s.MRR, s.MAP, s.Precision10)
+	for _, f := range report.Failures {
+		fmt.Printf("  ⚠ %q failed: %s\n", f.Query, f.Error)
+	}
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d queries failed to evaluate", len(report.Failures))
+	}
+	if s.ForbiddenHitRate > 0 {
+		for _, q := range report.Queries {
+			if q.Metrics.ForbiddenHitRate > 0 {
+				fmt.Printf("  ⛔ %q surfaced a known-irrelevant document\n", q.Query)
+			}
+		}
+		return fmt.Errorf("known-irrelevant documents leaked into the top %d (rate %.4f)", report.K, s.ForbiddenHitRate)
+	}
+	return nil
+}
+
+func ragGoldenInitCmd(ctx context.Context, svc *rag.Service, queriesPath, outPath string, force bool) error {
+	if !force {
+		if _, err := os.Stat(outPath); err == nil {
+			return fmt.Errorf("%s already exists; use --force to overwrite", outPath)
+		}
+	}
+	queries, err := readQueryLines(queriesPath)
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("%s contains no queries", queriesPath)
+	}
+
+	var ds eval.GoldenDataset
+	judged := 0
+	for _, q := range queries {
+		gq := eval.GoldenQuery{Query: q, Relevant: map[string]float64{}}
+		result, err := svc.Search(ctx, rag.SearchRequest{Query: q, TopK: 1})
+		if err != nil {
+			return fmt.Errorf("searching %q: %w", q, err)
+		}
+		if len(result.Items) > 0 {
+			gq.Relevant[result.Items[0].Ref.SourcePath] = 1
+			judged++
+		}
+		ds.Queries = append(ds.Queries, gq)
+	}
+
+	data, err := yaml.Marshal(&ds)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("# Auto-generated by `picoclaw rag golden-init` on %s.\n"+
+		"# Judgments are provisional (top search hit, relevance 1): curate before use.\n",
+		time.Now().UTC().Format("2006-01-02"))
+	if err := os.WriteFile(outPath, append([]byte(header), data...), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d queries to %s (%d with a provisional judgment)\n", len(ds.Queries), outPath, judged)
+	return nil
+}
+
+// readQueryLines reads one query per line, skipping blanks and #
+// comments.
+func readQueryLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
@@ -0,0 +1,336 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func ragSearchCmd(svc *rag.Service, req rag.SearchRequest, asJSON, verify, explain bool) error {
+	result, err := svc.Search(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	if verify {
+		second, err := svc.Search(context.Background(), req)
+		if err != nil {
+			return fmt.Errorf("verification search failed: %w", err)
+		}
+		a, _ := json.Marshal(result)
+		b, _ := json.Marshal(second)
+		if !bytes.Equal(a, b) {
+			return fmt.Errorf("nondeterministic results: two runs of the same query differ")
+		}
+		fmt.Println("✓ results are identical across two runs")
+	}
+
+	return printSearchResult(result, asJSON, explain)
+}
+
+func ragSimilarCmd(svc *rag.Service, sourcePath string, topK int, asJSON bool) error {
+	result, err := svc.MoreLikeThis(context.Background(), sourcePath, topK)
+	if err != nil {
+		return fmt.Errorf("similarity search failed: %w", err)
+	}
+	return printSearchResult(result, asJSON, false)
+}
+
+func printSearchResult(result *rag.SearchResult, asJSON, explain bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(result.Items) == 0 {
+		fmt.Printf("No results for %q.\n", result.Query)
+		return nil
+	}
+
+	fmt.Printf("\n%d results for %q (profile %s, mode %s):\n\n",
+		len(result.Items), result.Query, result.ProfileID, result.Mode)
+	for i, item := range result.Items {
+		fmt.Printf("%2d. %.3f  %s#%d", i+1, item.Breakdown.FinalScore, item.Ref.SourcePath, item.Ref.ChunkOrdinal)
+		if item.Title != "" {
+			fmt.Printf("  — %s", item.Title)
+		}
+		fmt.Println()
+		if len(item.HeadingPath) > 0 {
+			fmt.Printf("     %s\n", strings.Join(item.HeadingPath, " > "))
+		}
+		snippet := item.Snippet
+		if idx := strings.IndexByte(snippet, '\n'); idx > 0 {
+			snippet = snippet[:idx] + " …"
+		}
+		fmt.Printf("     %s\n", snippet)
+		if explain {
+			b := item.Breakdown
+			fmt.Printf("     bm25:      %.3f\n", b.BM25Norm)
+			fmt.Printf("     cosine:    %.3f\n", b.CosineNorm)
+			fmt.Printf("     freshness: %.3f\n", b.FreshnessNorm)
+			fmt.Printf("     metadata:  %.3f\n", b.MetadataBoost)
+			if b.AcronymBoost > 0 {
+				fmt.Printf("     acronym:   %.3f\n", b.AcronymBoost)
+			}
+			if b.RiskPenalty > 0 {
+				fmt.Printf("     risk penalty: -%.1f%%\n", b.RiskPenalty*100)
+			}
+		}
+		fmt.Println()
+	}
+	for _, note := range result.Notes {
+		fmt.Printf("note: %s\n", note)
+	}
+	return nil
+}
+
+func ragWhyNotCmd(svc *rag.Service, req rag.SearchRequest, sourcePath string, asJSON bool) error {
+	explanation, err := svc.ExplainDocument(context.Background(), req, sourcePath)
+	if err != nil {
+		return fmt.Errorf("explain failed: %w", err)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\n%s against %q (profile %s, mode %s, top-k %d):\n\n",
+		explanation.SourcePath, explanation.Query, explanation.ProfileID, explanation.Mode, explanation.TopK)
+	for _, ce := range explanation.Chunks {
+		fmt.Printf("chunk %d: %s", ce.Ref.ChunkOrdinal, ce.Verdict)
+		switch {
+		case !ce.Retrieved:
+			fmt.Print(" (no candidate match for the query)")
+		case ce.Verdict == rag.VerdictFiltered:
+			fmt.Printf(" (%s; bm25 %.3f, cosine %.3f)",
+				strings.Join(ce.FailedFilters, ", "), ce.Breakdown.RawBM25, ce.Breakdown.RawCosine)
+		default:
+			fmt.Printf(" (rank %d, score %.3f; bm25 %.3f, cosine %.3f)",
+				ce.Rank, ce.Breakdown.FinalScore, ce.Breakdown.RawBM25, ce.Breakdown.RawCosine)
+		}
+		fmt.Println()
+	}
+	for _, note := range explanation.Notes {
+		fmt.Printf("note: %s\n", note)
+	}
+	return nil
+}
+
+func ragPlanCmd(ctx context.Context, svc *rag.Service) error {
+	plan, err := svc.PlanIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("index plan failed: %w", err)
+	}
+	if plan.InSync() {
+		fmt.Printf("✓ Index is up to date (%d documents)\n", len(plan.Unchanged))
+		return nil
+	}
+	fmt.Printf("Rebuild would add %d, re-chunk %d, keep %d and remove %d documents\n",
+		len(plan.Added), len(plan.Changed), len(plan.Unchanged), len(plan.Removed))
+	for _, p := range plan.Added {
+		fmt.Printf("  + %s\n", p)
+	}
+	for _, p := range plan.Changed {
+		fmt.Printf("  ~ %s\n", p)
+	}
+	for _, p := range plan.Removed {
+		fmt.Printf("  - %s\n", p)
+	}
+	return nil
+}
+
+func ragIndexCmd(ctx context.Context, svc *rag.Service, watch bool) error {
+	info, err := svc.BuildIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("index build failed: %w", err)
+	}
+
+	fmt.Printf("✓ Indexed %d documents (%d chunks) with provider %s\n",
+		info.TotalDocuments, info.TotalChunks, info.IndexProvider)
+	if info.EmbeddingModelID != "" {
+		fmt.Printf("  embeddings: %s (%d dims)\n", info.EmbeddingModelID, info.VectorDims)
+	}
+	for _, w := range info.Warnings {
+		printWarning("  ", w)
+	}
+
+	if !watch {
+		return nil
+	}
+
+	fmt.Println("Watching for changes (Ctrl-C to stop)...")
+	watchCtx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := svc.Watch(watchCtx); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+func ragStatsCmd(svc *rag.Service, asJSON bool) error {
+	info, err := svc.IndexInfo(context.Background())
+	if err != nil {
+		return fmt.Errorf("no index info available: %w", err)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\nIndex %s (provider %s, built %s)\n",
+		info.IndexVersion, info.IndexProvider, info.BuiltAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("documents: %d, chunks: %d\n", info.TotalDocuments, info.TotalChunks)
+	if info.EmbeddingModelID != "" {
+		fmt.Printf("embeddings: %s (%d dims)\n", info.EmbeddingModelID, info.VectorDims)
+	}
+	if s := info.Stats; s != nil {
+		fmt.Printf("chunk length: avg %.0f runes, median %d runes\n", s.AvgChunkRunes, s.MedianChunkRunes)
+		fmt.Printf("vocabulary: %d unique terms\n", s.VocabSize)
+		printDistribution("doc types", s.DocTypes)
+		printDistribution("confidentiality", s.Confidentiality)
+	}
+	for _, w := range info.Warnings {
+		printWarning("", w)
+	}
+	return nil
+}
+
+func ragStatusCmd(svc *rag.Service, asJSON bool) error {
+	status, err := svc.Status(context.Background())
+	if err != nil {
+		return fmt.Errorf("no index status available: %w", err)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\nIndex %s (provider %s, built %s)\n",
+		status.IndexVersion, status.Provider, status.BuiltAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("documents: %d, chunks: %d\n", status.TotalDocuments, status.TotalChunks)
+	if status.EmbeddingModel != "" {
+		fmt.Printf("embedding model: %s\n", status.EmbeddingModel)
+	}
+	if status.HasVectors {
+		fmt.Printf("vectors: present (%d dims)\n", status.VectorDims)
+	}
+	if status.Dirty {
+		fmt.Println("⚠ unflushed writes pending; run a flush or rebuild before compacting")
+	}
+	for _, w := range status.Warnings {
+		printWarning("", w)
+	}
+	return nil
+}
+
+// printWarning renders one build warning, making security-severity
+// entries stand out from routine skips and cleanups.
+func printWarning(indent, w string) {
+	if rag.ClassifyWarning(w).Severity == rag.SeveritySecurity {
+		fmt.Printf("%s⛔ %s (security)\n", indent, w)
+		return
+	}
+	fmt.Printf("%s⚠ %s\n", indent, w)
+}
+
+// printDistribution renders a count map sorted by key, with the empty key
+// shown as (none).
+func printDistribution(label string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		name := k
+		if name == "" {
+			name = "(none)"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[k]))
+	}
+	fmt.Printf("%s: %s\n", label, strings.Join(parts, ", "))
+}
+
+func ragCompactCmd(svc *rag.Service) error {
+	reclaimed, err := svc.Compact()
+	if err != nil {
+		return fmt.Errorf("compaction failed: %w", err)
+	}
+	fmt.Printf("✓ Compacted index, reclaimed %d bytes\n", reclaimed)
+	return nil
+}
+
+func ragChunkCmd(ctx context.Context, svc *rag.Service, sourcePath string, ordinal, contextRadius int) error {
+	if contextRadius > 0 {
+		chunks, err := svc.FetchChunkWithContext(ctx, sourcePath, ordinal, contextRadius)
+		if err != nil {
+			return fmt.Errorf("fetch failed: %w", err)
+		}
+		for i := range chunks {
+			printChunk(&chunks[i])
+		}
+		return nil
+	}
+	chunk, err := svc.FetchChunk(ctx, sourcePath, ordinal)
+	if err != nil {
+		return fmt.Errorf("fetch failed: %w", err)
+	}
+	printChunk(chunk)
+	return nil
+}
+
+func ragChunkByParagraphCmd(ctx context.Context, svc *rag.Service, paragraphID string) error {
+	chunk, err := svc.FetchChunkByParagraphID(ctx, paragraphID)
+	if err != nil {
+		return fmt.Errorf("fetch failed: %w", err)
+	}
+	printChunk(chunk)
+	return nil
+}
+
+func printChunk(chunk *rag.IndexedChunk) {
+	fmt.Printf("\n[%s#%d]", chunk.SourcePath, chunk.ChunkOrdinal)
+	if chunk.Title != "" {
+		fmt.Printf(" %s", chunk.Title)
+	}
+	fmt.Println()
+	if len(chunk.HeadingPath) > 0 {
+		fmt.Printf("section: %s\n", strings.Join(chunk.HeadingPath, " > "))
+	}
+	if chunk.ChunkLoc != "" {
+		fmt.Printf("loc: %s\n", chunk.ChunkLoc)
+	}
+	fmt.Println("----------------------")
+	fmt.Println(chunk.Text)
+}
@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newIndexCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var watch, plan bool
+
+	cmd := &cobra.Command{
+		Use:     "index",
+		Short:   "Build (or rebuild) the knowledge base index",
+		Example: `picoclaw rag index --watch`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			if plan {
+				return ragPlanCmd(cmd.Context(), svc)
+			}
+			return ragIndexCmd(cmd.Context(), svc, watch)
+		},
+	}
+
+	cmd.Flags().BoolVar(&watch, "watch", false, "keep running and reindex on file changes")
+	cmd.Flags().BoolVar(&plan, "plan", false, "report what a rebuild would change without building")
+
+	return cmd
+}
@@ -0,0 +1,54 @@
+package rag
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newMigrateCommand() *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Convert a built index to another provider without re-chunking",
+		Long: "Reads the simple provider's JSON store and feeds its chunks directly into\n" +
+			"the comet provider's build, reusing stored vectors where possible instead\n" +
+			"of re-walking and re-embedding the knowledge base.",
+		Args: cobra.NoArgs,
+		// The parent's pre-run would open the configured provider against
+		// the same state directory the migration writes into; the command
+		// loads config itself instead.
+		PersistentPreRunE:  func(*cobra.Command, []string) error { return nil },
+		PersistentPostRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if from != "simple" || to != "comet" {
+				return fmt.Errorf("unsupported migration %s -> %s (only simple -> comet)", from, to)
+			}
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			ragCfg := cfg.Tools.RAG
+			ragCfg.IndexProvider = to
+
+			info, err := rag.MigrateSimpleToComet(cmd.Context(), cfg.WorkspacePath(), ragCfg)
+			if err != nil {
+				return fmt.Errorf("migration failed: %w", err)
+			}
+
+			fmt.Printf("✓ Migrated %d documents (%d chunks) to provider %s\n",
+				info.TotalDocuments, info.TotalChunks, info.IndexProvider)
+			if info.EmbeddingModelID != "" {
+				fmt.Printf("  embeddings: %s (%d dims)\n", info.EmbeddingModelID, info.VectorDims)
+			}
+			fmt.Println("Set tools.rag.index_provider to \"comet\" to serve from the migrated index.")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "simple", "Source index provider")
+	cmd.Flags().StringVar(&to, "to", "comet", "Target index provider")
+	return cmd
+}
@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newSearchCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		topK      int
+		mode      string
+		profile   string
+		blend     []string
+		asJSON    bool
+		docTypes  []string
+		tags      []string
+		whyNot    string
+		diversity int
+		parent    bool
+		verify    bool
+		explain   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:     "search <query>",
+		Short:   "Search the knowledge base",
+		Example: `picoclaw rag search "deployment checklist" --top-k 5`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			req := rag.SearchRequest{
+				Query:            args[0],
+				TopK:             topK,
+				Mode:             mode,
+				Profile:          profile,
+				BlendProfiles:    blend,
+				DocTypeDiversity: diversity,
+				ReturnParent:     parent,
+				Filters: rag.SearchFilters{
+					DocTypes: docTypes,
+					Tags:     tags,
+				},
+			}
+			if whyNot != "" {
+				return ragWhyNotCmd(svc, req, whyNot, asJSON)
+			}
+			return ragSearchCmd(svc, req, asJSON, verify, explain)
+		},
+	}
+
+	cmd.Flags().IntVar(&topK, "top-k", 0, "number of results (default 8)")
+	cmd.Flags().StringVar(&mode, "mode", "", "retrieval mode: keyword, semantic or hybrid")
+	cmd.Flags().StringVar(&profile, "profile", "", "scoring profile")
+	cmd.Flags().StringSliceVar(&blend, "blend", nil, "blend two or more profiles' rankings (RRF)")
+	cmd.Flags().StringSliceVar(&docTypes, "doc-type", nil, "restrict to doc types")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "restrict to tags")
+	cmd.Flags().IntVar(&diversity, "doc-type-diversity", 0, "soft cap on results per doc type (0 disables)")
+	cmd.Flags().BoolVar(&parent, "parent", false, "return each hit's full parent section as context")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the full result as JSON")
+	cmd.Flags().StringVar(&whyNot, "why-not", "", "explain why this document (KB-relative path) did or did not rank")
+	cmd.Flags().BoolVar(&verify, "verify-deterministic", false, "run the search twice and fail if the results differ")
+	cmd.Flags().BoolVar(&explain, "explain", false, "print the score breakdown for each result")
+
+	return cmd
+}
@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newServeCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve search, chunk and index endpoints over HTTP",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragServeCmd(cmd.Context(), svc, addr)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8750", "listen address; keep loopback unless auth is configured")
+
+	return cmd
+}
+
+func ragServeCmd(ctx context.Context, svc *rag.Service, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           svc.HTTPHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serving RAG endpoints on http://%s (Ctrl-C to stop)\n", addr)
+
+	serveCtx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-serveCtx.Done():
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		// Drain in-flight searches and flush index state before exit.
+		return svc.Shutdown(shutdownCtx)
+	}
+}
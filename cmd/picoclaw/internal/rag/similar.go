@@ -0,0 +1,33 @@
+package rag
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func newSimilarCommand(svcFn func() (*rag.Service, error)) *cobra.Command {
+	var (
+		topK   int
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:     "similar <source-path>",
+		Short:   "Find documents similar to an indexed document",
+		Example: `picoclaw rag similar notes/decision-042.md --top-k 5`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			svc, err := svcFn()
+			if err != nil {
+				return err
+			}
+			return ragSimilarCmd(svc, args[0], topK, asJSON)
+		},
+	}
+
+	cmd.Flags().IntVar(&topK, "top-k", 0, "number of results (default 8)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the full result as JSON")
+
+	return cmd
+}
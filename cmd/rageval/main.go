@@ -0,0 +1,161 @@
+// rageval sweeps indexing strategies over BEIR-layout retrieval datasets
+// and reports standard ranking metrics, for offline tuning of the RAG
+// pipeline.
+//
+// Usage:
+//
+//	go run ./cmd/rageval -data ~/beir -datasets scifact,nfcorpus
+//	go run ./cmd/rageval -data ~/beir -datasets scifact -sample 50 -seed 7
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/rag/eval"
+)
+
+func main() {
+	var (
+		dataRoot   = flag.String("data", "", "Root directory holding BEIR-layout datasets (required)")
+		datasets   = flag.String("datasets", "", "Comma-separated dataset names (default: all registered)")
+		strategies = flag.String("strategies", "", "Comma-separated strategy names (default: the full sweep)")
+		provider   = flag.String("provider", "simple", "Index provider to evaluate")
+		k          = flag.Int("k", 10, "Metric cutoff")
+		workDir    = flag.String("work", "", "Directory for built indices (default: temp, rebuilt each run)")
+		sample     = flag.Int("sample", 0, "Evaluate only this many labeled queries per dataset (0 = all)")
+		seed       = flag.Int64("seed", 0, "Seed for random query sampling (0 = first N by query id)")
+		parallel   = flag.Int("parallel", 1, "Evaluate up to this many strategies concurrently per dataset")
+		outPath    = flag.String("out", "", "Write full results to this file (.csv for a summary CSV, else JSON)")
+	)
+	flag.Parse()
+
+	if *dataRoot == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names := eval.RegisteredDatasets()
+	if *datasets != "" {
+		names = splitList(*datasets)
+	}
+	strats, err := selectStrategies(*strategies)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runner := eval.NewRunner(eval.RunConfig{
+		DatasetRoot:   *dataRoot,
+		Datasets:      names,
+		Strategies:    strats,
+		Base:          config.RAGToolsConfig{IndexProvider: *provider},
+		K:             *k,
+		WorkDir:       *workDir,
+		SampleQueries: *sample,
+		SampleSeed:    *seed,
+		Parallelism:   *parallel,
+		Log:           log.Printf,
+	})
+	defer runner.Close()
+
+	results, err := runner.Run(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printResults(results, *k)
+	if *outPath != "" {
+		if err := writeResults(*outPath, results); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// writeResults persists the run: a summary CSV when the path ends in
+// .csv, the full results as JSON otherwise.
+func writeResults(path string, results []eval.DatasetResult) error {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := eval.WriteCSV(f, results); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// printResults renders the comparison table, with the published BM25
+// baseline alongside where one is known.
+func printResults(results []eval.DatasetResult, k int) {
+	w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "dataset\tstrategy\tqueries\tnDCG@%d\trecall@%d\tMRR\tBM25 paper\n", k, k)
+	for _, res := range results {
+		queries := fmt.Sprintf("%d", len(res.Report.Queries))
+		if res.SampledQueries > 0 {
+			queries += " (sampled)"
+		}
+		baseline := "-"
+		if b, ok := eval.PublishedBaselines[res.Dataset]; ok {
+			baseline = fmt.Sprintf("%.3f", b)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.4f\t%.4f\t%.4f\t%s\n",
+			res.Dataset, res.Strategy, queries,
+			res.Report.Summary.NDCG, res.Report.Summary.Recall, res.Report.Summary.MRR, baseline)
+	}
+	w.Flush()
+}
+
+// selectStrategies resolves a comma-separated name list against the
+// default sweep; empty means all of it.
+func selectStrategies(names string) ([]eval.Strategy, error) {
+	if names == "" {
+		return eval.DefaultStrategies, nil
+	}
+	byName := make(map[string]eval.Strategy, len(eval.DefaultStrategies))
+	for _, s := range eval.DefaultStrategies {
+		byName[s.Name] = s
+	}
+	var out []eval.Strategy
+	for _, name := range splitList(names) {
+		s, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (have: %s)", name, strategyNames())
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func strategyNames() string {
+	names := make([]string, len(eval.DefaultStrategies))
+	for i, s := range eval.DefaultStrategies {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/rag"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
@@ -217,6 +218,18 @@ func registerSharedTools(
 			}
 		}
 
+		// Knowledge base retrieval tools share one service per agent so
+		// index state and the search queue are not duplicated.
+		if cfg.Tools.IsToolEnabled("rag") {
+			ragSvc, err := rag.NewService(agent.Workspace, cfg.Tools.RAG)
+			if err != nil {
+				logger.ErrorCF("agent", "Failed to create RAG service", map[string]any{"error": err.Error()})
+			} else {
+				agent.Tools.Register(tools.NewRAGSearchTool(ragSvc, cfg.Tools.RAG.ToolMaxTopK))
+				agent.Tools.Register(tools.NewRAGFetchTool(ragSvc))
+			}
+		}
+
 		// Spawn tool with allowlist checker
 		if cfg.Tools.IsToolEnabled("spawn") {
 			if cfg.Tools.IsToolEnabled("subagent") {
@@ -623,6 +623,165 @@ type MediaCleanupConfig struct {
 	Interval   int `                                    env:"PICOCLAW_MEDIA_CLEANUP_INTERVAL" json:"interval_minutes"`
 }
 
+// SecretMaskRule is one custom redaction rule: text matching Pattern (a
+// Go regex) is replaced with Replacement, or the standard redaction
+// marker when Replacement is empty.
+type SecretMaskRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RiskRule is one custom injection-risk detection rule: a chunk
+// containing any of the substrings (matched case-insensitively) gets the
+// flag and adds the weight to its risk score.
+type RiskRule struct {
+	Substrings []string `json:"substrings"`
+	Flag       string   `json:"flag"`
+	Weight     float64  `json:"weight"`
+}
+
+// RAGToolsConfig configures the knowledge-base retrieval (RAG) subsystem:
+// where the markdown KB lives, which index provider and embedding
+// endpoint to use, and the chunking/scoring knobs.
+type RAGToolsConfig struct {
+	ToolConfig `envPrefix:"PICOCLAW_TOOLS_RAG_"`
+	// KBRoot is the knowledge base directory, relative to the workspace
+	// unless absolute. Defaults to "knowledge".
+	KBRoot string `json:"kb_root,omitempty" env:"PICOCLAW_TOOLS_RAG_KB_ROOT"`
+	// KBRoots lists further knowledge base directories indexed together
+	// with KBRoot, each relative to the workspace unless absolute. With
+	// more than one root, indexed paths are prefixed with the root's
+	// directory name so sources stay unambiguous across roots.
+	KBRoots []string `json:"kb_roots,omitempty" env:"PICOCLAW_TOOLS_RAG_KB_ROOTS"`
+	// IndexProvider selects the index backend: "simple" (JSON, default),
+	// "comet" (bbolt + vectors) or "bleve".
+	IndexProvider string `json:"index_provider,omitempty" env:"PICOCLAW_TOOLS_RAG_INDEX_PROVIDER"`
+	// ConfidentialityLevels orders confidentiality values from least to
+	// most sensitive for ceiling-style filters; the last entry is the
+	// level the AllowRestricted gate protects. Defaults to
+	// ["public", "internal", "confidential", "restricted"].
+	ConfidentialityLevels []string `json:"confidentiality_levels,omitempty" env:"PICOCLAW_TOOLS_RAG_CONFIDENTIALITY_LEVELS"`
+	// Denylist lists directory names skipped during indexing.
+	Denylist []string `json:"denylist,omitempty" env:"PICOCLAW_TOOLS_RAG_DENYLIST"`
+	// IndexExtensions lists the file extensions indexed and watched
+	// (default [".md"]). ".mdx" files additionally have import and JSX
+	// lines stripped before chunking.
+	IndexExtensions []string `json:"index_extensions,omitempty" env:"PICOCLAW_TOOLS_RAG_INDEX_EXTENSIONS"`
+	// BoilerplatePatterns are regexes stripped from document bodies
+	// before chunking (repeated headers/footers that would otherwise be
+	// embedded and indexed on every document).
+	BoilerplatePatterns []string `json:"boilerplate_patterns,omitempty" env:"PICOCLAW_TOOLS_RAG_BOILERPLATE_PATTERNS"`
+	// SecretMaskRules extend the built-in secret redaction patterns with
+	// deployment-specific token formats (GitLab/GitHub/Slack tokens, ...).
+	SecretMaskRules []SecretMaskRule `json:"secret_mask_rules,omitempty"`
+	// RiskRules extend the built-in injection-risk detection rules, e.g.
+	// with non-English phrasings the English built-ins cannot catch.
+	RiskRules []RiskRule `json:"risk_rules,omitempty"`
+
+	// Embedding configuration; empty provider means keyword-only.
+	EmbeddingProvider string `json:"embedding_provider,omitempty" env:"PICOCLAW_TOOLS_RAG_EMBEDDING_PROVIDER"`
+	EmbeddingModel    string `json:"embedding_model,omitempty"    env:"PICOCLAW_TOOLS_RAG_EMBEDDING_MODEL"`
+	EmbeddingAPIKey   string `json:"embedding_api_key,omitempty"  env:"PICOCLAW_TOOLS_RAG_EMBEDDING_API_KEY"`
+	EmbeddingAPIBase  string `json:"embedding_api_base,omitempty" env:"PICOCLAW_TOOLS_RAG_EMBEDDING_API_BASE"`
+	// EmbeddingMaxAttempts bounds how often a rate-limited (429/503)
+	// embedding call is tried before the error surfaces; 0 means the
+	// built-in default of 3.
+	EmbeddingMaxAttempts int `json:"embedding_max_attempts,omitempty" env:"PICOCLAW_TOOLS_RAG_EMBEDDING_MAX_ATTEMPTS"`
+	// EmbeddingAWSRegion selects the AWS region for the bedrock provider;
+	// empty falls back to AWS_REGION / AWS_DEFAULT_REGION.
+	EmbeddingAWSRegion string `json:"embedding_aws_region,omitempty" env:"PICOCLAW_TOOLS_RAG_EMBEDDING_AWS_REGION"`
+	// EmbeddingDims overrides the provider's default output
+	// dimensionality for models that support it (Titan v2: 256/512/1024;
+	// Jina v3 via Matryoshka truncation).
+	EmbeddingDims int `json:"embedding_dims,omitempty" env:"PICOCLAW_TOOLS_RAG_EMBEDDING_DIMS"`
+	// TruncateDims, when positive and below the model's native size,
+	// truncates embeddings to this many dimensions (Matryoshka models:
+	// OpenAI v3, Jina v3, Nomic). Passed to APIs that support a dimensions
+	// parameter and enforced client-side with L2 renormalization either
+	// way.
+	TruncateDims int `json:"truncate_dims,omitempty" env:"PICOCLAW_TOOLS_RAG_TRUNCATE_DIMS"`
+	// StrictIndexMatch makes searches fail outright when the loaded index
+	// was built with different chunking parameters or a different
+	// embedding model than currently configured, instead of answering
+	// from the stale index with a warning note.
+	StrictIndexMatch bool `json:"strict_index_match,omitempty" env:"PICOCLAW_TOOLS_RAG_STRICT_INDEX_MATCH"`
+	// QueryEmbedCacheSize caps the LRU of query embeddings kept so
+	// repeated searches (agent retries, refinement loops) skip the
+	// embedding API call. 0 means the built-in default of 128; negative
+	// disables the cache.
+	QueryEmbedCacheSize int `json:"query_embed_cache_size,omitempty" env:"PICOCLAW_TOOLS_RAG_QUERY_EMBED_CACHE_SIZE"`
+	// PCADims, when positive, reduces stored embeddings to this many
+	// dimensions via a PCA projection fitted at build time (comet
+	// provider). Cuts vector storage and cosine cost on constrained
+	// devices at a small recall cost.
+	PCADims int `json:"pca_dims,omitempty" env:"PICOCLAW_TOOLS_RAG_PCA_DIMS"`
+
+	// BM25K1 and BM25B tune the comet provider's lexical scoring (term
+	// frequency saturation and length normalization); zero means the
+	// standard 1.2 / 0.75. Mostly useful for eval sweeps.
+	BM25K1 float64 `json:"bm25_k1,omitempty" env:"PICOCLAW_TOOLS_RAG_BM25_K1"`
+	BM25B  float64 `json:"bm25_b,omitempty"  env:"PICOCLAW_TOOLS_RAG_BM25_B"`
+
+	// Chunking limits in runes; zero means the built-in defaults.
+	ChunkSoftLimit int `json:"chunk_soft_limit,omitempty" env:"PICOCLAW_TOOLS_RAG_CHUNK_SOFT_LIMIT"`
+	ChunkHardLimit int `json:"chunk_hard_limit,omitempty" env:"PICOCLAW_TOOLS_RAG_CHUNK_HARD_LIMIT"`
+	// AttachHeadings merges heading lines into the following content
+	// chunk instead of letting them stand alone as searchable units.
+	AttachHeadings bool `json:"attach_headings,omitempty" env:"PICOCLAW_TOOLS_RAG_ATTACH_HEADINGS"`
+	// DocumentHardBytes is the per-file size ceiling (default 10MB).
+	DocumentHardBytes int64 `json:"document_hard_bytes,omitempty" env:"PICOCLAW_TOOLS_RAG_DOCUMENT_HARD_BYTES"`
+	// DedupeChunks skips chunks whose normalized text already appeared in
+	// an earlier document during a build, keeping the first occurrence.
+	// Useful for KBs that copy boilerplate sections verbatim.
+	DedupeChunks bool `json:"dedupe_chunks,omitempty" env:"PICOCLAW_TOOLS_RAG_DEDUPE_CHUNKS"`
+
+	// QueueSize bounds concurrent searches.
+	QueueSize int `json:"queue_size,omitempty" env:"PICOCLAW_TOOLS_RAG_QUEUE_SIZE"`
+	// Concurrency bounds the internal parallelism of batch searches
+	// (default 4); the whole batch occupies one queue slot.
+	Concurrency int `json:"concurrency,omitempty" env:"PICOCLAW_TOOLS_RAG_CONCURRENCY"`
+	// SlowSearchMillis logs a structured warning (with redacted query and
+	// phase timings) for any search slower than this; 0 disables it.
+	SlowSearchMillis int `json:"slow_search_millis,omitempty" env:"PICOCLAW_TOOLS_RAG_SLOW_SEARCH_MILLIS"`
+	// MaxTopK is the per-request result ceiling on the library/CLI path
+	// (default 100); ToolMaxTopK is the tighter ceiling for the agent tool
+	// (default 20), kept small so evidence packs fit LLM context.
+	MaxTopK     int `json:"max_top_k,omitempty"      env:"PICOCLAW_TOOLS_RAG_MAX_TOP_K"`
+	ToolMaxTopK int `json:"tool_max_top_k,omitempty" env:"PICOCLAW_TOOLS_RAG_TOOL_MAX_TOP_K"`
+	// DefaultProfile names the scoring profile used when requests do not
+	// specify one.
+	DefaultProfile string `json:"default_profile,omitempty" env:"PICOCLAW_TOOLS_RAG_DEFAULT_PROFILE"`
+	// QuerySynonyms is a static synonym table for query expansion: a
+	// query token matching a key has the value's tokens appended before
+	// retrieval ("k8s" → "kubernetes").
+	QuerySynonyms map[string]string `json:"query_synonyms,omitempty"`
+	// AcronymBoost rewards chunks containing the exact-case form of
+	// all-caps query tokens (RAG, TTL, ...), which ordinary lowercased
+	// matching conflates with common words.
+	AcronymBoost bool `json:"acronym_boost,omitempty" env:"PICOCLAW_TOOLS_RAG_ACRONYM_BOOST"`
+	// CJKBigrams tokenizes CJK text spans as character bigrams instead of
+	// whitespace words, which Chinese/Japanese lack. Opt-in because it
+	// changes token statistics; rebuild the index after enabling it.
+	CJKBigrams bool `json:"cjk_bigrams,omitempty" env:"PICOCLAW_TOOLS_RAG_CJK_BIGRAMS"`
+	// Stopwords are dropped from query tokens before the searchable-token
+	// guard and lexical scoring, so a query of only filler words fails
+	// fast instead of retrieving noise. Unset selects a built-in English
+	// set; an explicitly empty list disables stopword filtering. Chunk
+	// token statistics are unaffected.
+	Stopwords []string `json:"stopwords,omitempty"`
+	// MinTokenLength drops query tokens shorter than this many runes.
+	// Zero or one keeps every token.
+	MinTokenLength int `json:"min_token_length,omitempty" env:"PICOCLAW_TOOLS_RAG_MIN_TOKEN_LENGTH"`
+
+	// ServeAuthToken, when set, requires "Authorization: Bearer <token>"
+	// on every serve-mode endpoint. Leave empty for localhost development
+	// only — never expose an unauthenticated server beyond loopback.
+	ServeAuthToken string `json:"serve_auth_token,omitempty" env:"PICOCLAW_TOOLS_RAG_SERVE_AUTH_TOKEN"`
+	// ServeAllowedOrigins lists origins allowed to call the serve-mode
+	// endpoints from a browser ("*" allows any). Empty disables CORS.
+	ServeAllowedOrigins []string `json:"serve_allowed_origins,omitempty" env:"PICOCLAW_TOOLS_RAG_SERVE_ALLOWED_ORIGINS"`
+}
+
 type ToolsConfig struct {
 	AllowReadPaths  []string           `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
 	AllowWritePaths []string           `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
@@ -630,6 +789,7 @@ type ToolsConfig struct {
 	Cron            CronToolsConfig    `json:"cron"`
 	Exec            ExecConfig         `json:"exec"`
 	Skills          SkillsToolsConfig  `json:"skills"`
+	RAG             RAGToolsConfig     `json:"rag"`
 	MediaCleanup    MediaCleanupConfig `json:"media_cleanup"`
 	MCP             MCPConfig          `json:"mcp"`
 	AppendFile      ToolConfig         `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
@@ -888,6 +1048,8 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.Exec.Enabled
 	case "skills":
 		return t.Skills.Enabled
+	case "rag":
+		return t.RAG.Enabled
 	case "media_cleanup":
 		return t.MediaCleanup.Enabled
 	case "append_file":
@@ -404,6 +404,13 @@ func DefaultConfig() *Config {
 					TTLSeconds: 300,
 				},
 			},
+			RAG: RAGToolsConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+				KBRoot:        "knowledge",
+				IndexProvider: "comet",
+			},
 			SendFile: ToolConfig{
 				Enabled: true,
 			},
@@ -0,0 +1,72 @@
+package rag
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultBatchConcurrency bounds how many batch queries run in parallel
+// when cfg.Concurrency is unset.
+const defaultBatchConcurrency = 4
+
+// SearchBatch runs several requests under a single queue slot with
+// bounded internal concurrency, for callers that fire many related
+// queries back to back. The first request runs alone so the provider's
+// lazy index load happens exactly once before the fan-out. Results and
+// errors are positional: a failed request leaves a nil result and its
+// error at the same index instead of aborting the batch.
+func (s *Service) SearchBatch(ctx context.Context, reqs []SearchRequest) ([]*SearchResult, []error) {
+	results := make([]*SearchResult, len(reqs))
+	errs := make([]error, len(reqs))
+	if len(reqs) == 0 {
+		return results, errs
+	}
+
+	release, err := s.beginQueued()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return results, errs
+	}
+	defer release()
+
+	run := func(i int) {
+		req := reqs[i]
+		if err := s.validateSearchRequest(req); err != nil {
+			errs[i] = err
+			return
+		}
+		key := s.cacheKeyFor(ctx, req)
+		if cached, ok := s.cacheGet(key); ok {
+			results[i] = cached
+			return
+		}
+		results[i], errs[i] = s.searchUnqueued(ctx, req)
+		if errs[i] == nil {
+			s.cachePut(key, results[i])
+		}
+	}
+
+	// Warm the provider (and any cold caches) on the first request before
+	// spawning the rest.
+	run(0)
+
+	limit := s.cfg.Concurrency
+	if limit <= 0 {
+		limit = defaultBatchConcurrency
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 1; i < len(reqs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+	return results, errs
+}
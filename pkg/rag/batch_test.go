@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchBatch(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"deploy.md": doc("Deploy", "2026-01-01", "deployment checklist and rollback steps"),
+		"kafka.md":  doc("Kafka", "2026-01-02", "kafka consumer rebalancing notes"),
+	})
+
+	reqs := []SearchRequest{
+		{Query: "deployment checklist"},
+		{Query: "kafka rebalancing"},
+		{Query: "   "}, // invalid: no tokens
+	}
+	results, errs := svc.SearchBatch(context.Background(), reqs)
+	if len(results) != 3 || len(errs) != 3 {
+		t.Fatalf("got %d results, %d errors", len(results), len(errs))
+	}
+
+	if errs[0] != nil || len(results[0].Items) == 0 {
+		t.Errorf("request 0: err=%v items=%v", errs[0], results[0])
+	}
+	if results[0].Items[0].Ref.SourcePath != "deploy.md" {
+		t.Errorf("request 0 top hit = %s", results[0].Items[0].Ref.SourcePath)
+	}
+	if errs[1] != nil || len(results[1].Items) == 0 {
+		t.Errorf("request 1: err=%v", errs[1])
+	}
+	if results[1].Items[0].Ref.SourcePath != "kafka.md" {
+		t.Errorf("request 1 top hit = %s", results[1].Items[0].Ref.SourcePath)
+	}
+
+	// The invalid request fails alone; the batch still completes.
+	if errs[2] == nil {
+		t.Error("request 2 should have failed validation")
+	}
+	if results[2] != nil {
+		t.Error("failed request should leave a nil result")
+	}
+}
+
+func TestSearchBatchEmpty(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "content"),
+	})
+	results, errs := svc.SearchBatch(context.Background(), nil)
+	if len(results) != 0 || len(errs) != 0 {
+		t.Errorf("empty batch returned %d results, %d errors", len(results), len(errs))
+	}
+}
+
+func TestSearchBatchMatchesSearch(t *testing.T) {
+	svc := newTestService(t, blendCorpus())
+	single, err := svc.Search(context.Background(), SearchRequest{Query: "alpha rollout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, errs := svc.SearchBatch(context.Background(), []SearchRequest{{Query: "alpha rollout"}})
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if !equalRanking(rankingOf(single), rankingOf(results[0])) {
+		t.Errorf("batch ranking %v differs from single-search ranking %v",
+			rankingOf(results[0]), rankingOf(single))
+	}
+}
@@ -0,0 +1,268 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Bedrock Titan defaults. Titan v2 supports 256, 512 or 1024 output
+// dimensions; EmbeddingDims selects among them.
+const (
+	bedrockDefaultModel = "amazon.titan-embed-text-v2:0"
+	bedrockDefaultDims  = 1024
+)
+
+// awsCredentials is the static credential triple used for SigV4 signing.
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsCredentialsFromEnv reads the standard AWS credential variables.
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return awsCredentials{}, fmt.Errorf(
+			"embedding provider %q requires AWS credentials in the environment", "bedrock")
+	}
+	return creds, nil
+}
+
+// bedrockEmbedder calls the Bedrock runtime InvokeModel endpoint with
+// SigV4-signed requests — Bedrock has no Bearer-token auth, so it cannot
+// ride on httpEmbedder. Titan embedding models take one text per
+// invocation, so Embed issues one request per input.
+type bedrockEmbedder struct {
+	endpoint string
+	region   string
+	model    string
+	dims     int
+	creds    awsCredentials
+	client   *http.Client
+	// now is injectable so signing is deterministic in tests.
+	now func() time.Time
+}
+
+// newBedrockEmbedder wires the bedrock provider: region from config or
+// the AWS environment, endpoint derived from the region unless
+// EmbeddingAPIBase overrides it (tests, VPC endpoints).
+func newBedrockEmbedder(cfg config.RAGToolsConfig) (*bedrockEmbedder, error) {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	region := cfg.EmbeddingAWSRegion
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	endpoint := strings.TrimSuffix(cfg.EmbeddingAPIBase, "/")
+	if endpoint != "" && region == "" {
+		region = regionFromBedrockHost(endpoint)
+	}
+	if region == "" {
+		return nil, fmt.Errorf(
+			"embedding provider %q requires a region (embedding_aws_region or AWS_REGION)", "bedrock")
+	}
+	if endpoint == "" {
+		endpoint = "https://bedrock-runtime." + region + ".amazonaws.com"
+	}
+	model := cfg.EmbeddingModel
+	if model == "" {
+		model = bedrockDefaultModel
+	}
+	dims := cfg.EmbeddingDims
+	if dims == 0 {
+		dims = bedrockDefaultDims
+	}
+	switch dims {
+	case 256, 512, 1024:
+	default:
+		return nil, fmt.Errorf("bedrock embedding dims must be 256, 512 or 1024, got %d", dims)
+	}
+	return &bedrockEmbedder{
+		endpoint: endpoint,
+		region:   region,
+		model:    model,
+		dims:     dims,
+		creds:    creds,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		now:      time.Now,
+	}, nil
+}
+
+// regionFromBedrockHost extracts the region from a standard
+// bedrock-runtime.<region>.amazonaws.com endpoint; other hosts yield "".
+func regionFromBedrockHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 3 && parts[0] == "bedrock-runtime" {
+		return parts[1]
+	}
+	return ""
+}
+
+type titanEmbedRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions"`
+	Normalize  bool   `json:"normalize"`
+}
+
+type titanEmbedResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+func (e *bedrockEmbedder) ModelID() string {
+	return "bedrock/" + e.model
+}
+
+func (e *bedrockEmbedder) Dims() int {
+	return e.dims
+}
+
+func (e *bedrockEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, t := range texts {
+		v, err := e.invoke(ctx, t)
+		if err != nil {
+			return nil, fmt.Errorf("embedding input %d: %w", i, err)
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// EmbedQuery delegates to the document path; Titan is symmetric.
+func (e *bedrockEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return e.invoke(ctx, text)
+}
+
+// invoke embeds one text through InvokeModel.
+func (e *bedrockEmbedder) invoke(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(titanEmbedRequest{InputText: text, Dimensions: e.dims, Normalize: true})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := e.endpoint + "/model/" + url.PathEscape(e.model) + "/invoke"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signSigV4(req, body, e.creds, e.region, "bedrock", e.now())
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("embedding request returned %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var parsed titanEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	if len(parsed.Embedding) == 0 {
+		return nil, fmt.Errorf("embedding response carried no vector")
+	}
+	return parsed.Embedding, nil
+}
+
+// signSigV4 signs req in place with AWS Signature Version 4: it sets the
+// X-Amz-Date, X-Amz-Content-Sha256 (and, for temporary credentials,
+// X-Amz-Security-Token) headers and the derived Authorization header.
+func signSigV4(req *http.Request, payload []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	dateStamp := now.UTC().Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+		headers["x-amz-security-token"] = creds.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{dateStamp, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), dateStamp)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
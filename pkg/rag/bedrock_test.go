@@ -0,0 +1,194 @@
+package rag
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var sigV4AuthPattern = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/([^/]+)/aws4_request, ` +
+		`SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// verifySigV4 recomputes the request's signature from the server side
+// with the known secret and compares it to the Authorization header, so
+// the test fails on any canonicalization drift, not just a malformed
+// header.
+func verifySigV4(t *testing.T, r *http.Request, payload []byte, secretKey string) {
+	t.Helper()
+	auth := r.Header.Get("Authorization")
+	m := sigV4AuthPattern.FindStringSubmatch(auth)
+	if m == nil {
+		t.Fatalf("Authorization header is not a SigV4 signature: %q", auth)
+	}
+	accessKey, dateStamp, region, service, signedHeaders, signature :=
+		m[1], m[2], m[3], m[4], m[5], m[6]
+	if accessKey != "AKIDEXAMPLE" {
+		t.Errorf("credential access key = %q", accessKey)
+	}
+	if region != "us-east-1" || service != "bedrock" {
+		t.Errorf("credential scope = %s/%s, want us-east-1/bedrock", region, service)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+	scope := strings.Join([]string{dateStamp, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		r.Header.Get("X-Amz-Date"),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	want := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+}
+
+func TestBedrockEmbedderSigV4(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if want := "/model/amazon.titan-embed-text-v2:0/invoke"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifySigV4(t, r, payload, "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+		var req titanEmbedRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		if req.Dimensions != 512 || !req.Normalize {
+			t.Errorf("request = %+v, want dimensions 512, normalize true", req)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embedding": make([]float32, req.Dimensions)})
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider:  "bedrock",
+		EmbeddingAPIBase:   ts.URL,
+		EmbeddingAWSRegion: "us-east-1",
+		EmbeddingDims:      512,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "bedrock/amazon.titan-embed-text-v2:0" {
+		t.Errorf("ModelID = %q", got)
+	}
+	if got := emb.Dims(); got != 512 {
+		t.Errorf("Dims = %d, want 512", got)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"alpha", "beta"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 512 {
+		t.Errorf("got %d vectors of %d dims", len(vectors), len(vectors[0]))
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "query"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+	// Titan takes one text per invocation.
+	if requests != 3 {
+		t.Errorf("server saw %d requests, want 3", requests)
+	}
+}
+
+func TestBedrockEmbedderConfig(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	// Region from the standard endpoint host.
+	emb, err := newBedrockEmbedder(config.RAGToolsConfig{
+		EmbeddingAPIBase: "https://bedrock-runtime.eu-west-1.amazonaws.com",
+	})
+	if err != nil {
+		t.Fatalf("newBedrockEmbedder: %v", err)
+	}
+	if emb.region != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", emb.region)
+	}
+	if emb.dims != 1024 {
+		t.Errorf("default dims = %d, want 1024", emb.dims)
+	}
+
+	// No region anywhere is a configuration error.
+	if _, err := newBedrockEmbedder(config.RAGToolsConfig{}); err == nil {
+		t.Error("expected error without a region")
+	}
+	// Titan v2 only supports 256/512/1024.
+	if _, err := newBedrockEmbedder(config.RAGToolsConfig{
+		EmbeddingAWSRegion: "us-east-1", EmbeddingDims: 768,
+	}); err == nil {
+		t.Error("expected error for unsupported dims")
+	}
+
+	// Missing credentials surface before any request.
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if _, err := newBedrockEmbedder(config.RAGToolsConfig{EmbeddingAWSRegion: "us-east-1"}); err == nil {
+		t.Error("expected error without credentials")
+	}
+}
+
+// TestSigV4SessionToken pins the security-token header into the signed
+// set when temporary credentials are in play.
+func TestSigV4SessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/m/invoke", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := awsCredentials{accessKey: "AKIDEXAMPLE", secretKey: "secret", sessionToken: "token"}
+	signSigV4(req, []byte("{}"), creds, "us-east-1", "bedrock", time.Date(2026, 8, 29, 12, 0, 0, 0, time.UTC))
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token not in signed headers: %q", auth)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20260829T120000Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+}
@@ -0,0 +1,353 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// bleveProvider indexes chunks in a bleve full-text index. Bleve returns
+// a single opaque relevance score per hit, so candidates carry RawFused
+// and the service normalizes that one value for both signal slots.
+type bleveProvider struct {
+	dir string
+
+	mu    sync.Mutex
+	index bleve.Index
+}
+
+// bleveDoc builds the document shape stored in bleve. Chunk metadata is
+// duplicated into keyword fields so it survives round-trips without a
+// secondary store; custom frontmatter keys become dynamic metadata_*
+// fields so they are queryable too. The pushdown-filterable fields are
+// lowercased so term queries match with the same case-insensitivity as
+// the service's Go-side filters.
+func bleveDoc(c *IndexedChunk, chunkJSON string) map[string]interface{} {
+	doc := map[string]interface{}{
+		"text":            c.Text,
+		"source_path":     c.SourcePath,
+		"chunk_ordinal":   c.ChunkOrdinal,
+		"chunk_json":      chunkJSON, // full IndexedChunk, stored verbatim
+		"doc_type":        strings.ToLower(c.DocType),
+		"confidentiality": strings.ToLower(c.Confidentiality),
+		"project":         strings.ToLower(c.Project),
+		"tags":            c.Tags,
+		"date":            c.Date,
+	}
+	// date_unix backs numeric range pushdown; unparseable dates get no
+	// value and so never match a ranged query, like passesFilters.
+	if t, ok := parseISODate(c.Date); ok {
+		doc["date_unix"] = float64(t.Unix())
+	}
+	for k, v := range c.Metadata {
+		doc["metadata_"+k] = v
+	}
+	return doc
+}
+
+func newBleveProvider(dir string) (*bleveProvider, error) {
+	return &bleveProvider{dir: dir}, nil
+}
+
+func (p *bleveProvider) Name() string {
+	return "bleve"
+}
+
+func (p *bleveProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Lexical: true, FilterPushdown: true}
+}
+
+// bleveFilterClauses translates the pushdown-eligible filter fields into
+// conjunction clauses: each configured field becomes a disjunction of
+// term queries over its allowed values. Values are lowercased to match
+// the normalization bleveDoc applies at index time.
+func bleveFilterClauses(f SearchFilters) []query.Query {
+	var clauses []query.Query
+	add := func(field string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		terms := make([]query.Query, 0, len(values))
+		for _, v := range values {
+			tq := bleve.NewTermQuery(strings.ToLower(v))
+			tq.SetField(field)
+			terms = append(terms, tq)
+		}
+		clauses = append(clauses, bleve.NewDisjunctionQuery(terms...))
+	}
+	add("doc_type", f.DocTypes)
+	add("project", f.Projects)
+	add("confidentiality", f.ConfidentialityAllow)
+	if f.DateFrom != "" || f.DateTo != "" {
+		var min, max *float64
+		if t, ok := parseISODate(f.DateFrom); ok {
+			v := float64(t.Unix())
+			min = &v
+		}
+		if t, ok := parseISODate(f.DateTo); ok {
+			v := float64(t.Unix())
+			max = &v
+		}
+		if min != nil || max != nil {
+			inclusive := true
+			nrq := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusive, &inclusive)
+			nrq.SetField("date_unix")
+			clauses = append(clauses, nrq)
+		}
+	}
+	return clauses
+}
+
+func bleveMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+	doc := bleve.NewDocumentMapping()
+
+	text := bleve.NewTextFieldMapping()
+	doc.AddFieldMappingsAt("text", text)
+
+	stored := bleve.NewKeywordFieldMapping()
+	stored.Index = false
+	doc.AddFieldMappingsAt("chunk_json", stored)
+
+	for _, f := range []string{"source_path", "doc_type", "confidentiality", "project", "tags", "date"} {
+		doc.AddFieldMappingsAt(f, bleve.NewKeywordFieldMapping())
+	}
+	doc.AddFieldMappingsAt("date_unix", bleve.NewNumericFieldMapping())
+	m.DefaultMapping = doc
+	return m
+}
+
+// open returns the index, opening or creating it as needed. Callers must
+// hold the lock.
+func (p *bleveProvider) open(create bool) (bleve.Index, error) {
+	if p.index != nil {
+		return p.index, nil
+	}
+	idx, err := bleve.Open(p.dir)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		if !create {
+			return nil, ErrNoIndex
+		}
+		idx, err = bleve.New(p.dir, bleveMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening bleve index: %w", err)
+	}
+	p.index = idx
+	return idx, nil
+}
+
+func bleveDocID(sourcePath string, ordinal int) string {
+	return sourcePath + "#" + strconv.Itoa(ordinal)
+}
+
+func (p *bleveProvider) Build(ctx context.Context, chunks []IndexedChunk, info IndexInfo) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Rebuild from scratch: drop the old index directory.
+	if p.index != nil {
+		p.index.Close()
+		p.index = nil
+	}
+	if err := os.RemoveAll(p.dir); err != nil {
+		return err
+	}
+	idx, err := p.open(true)
+	if err != nil {
+		return err
+	}
+
+	batch := idx.NewBatch()
+	for i := range chunks {
+		c := &chunks[i]
+		raw, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if err := batch.Index(bleveDocID(c.SourcePath, c.ChunkOrdinal), bleveDoc(c, string(raw))); err != nil {
+			return err
+		}
+	}
+	if err := idx.Batch(batch); err != nil {
+		return err
+	}
+
+	infoData, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.infoPath(), infoData, 0o644)
+}
+
+func (p *bleveProvider) infoPath() string {
+	return filepath.Join(filepath.Dir(p.dir), "bleve_info.json")
+}
+
+func (p *bleveProvider) Search(ctx context.Context, queryText string, opts ProviderSearchOptions) ([]Candidate, error) {
+	p.mu.Lock()
+	idx, err := p.open(false)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	topN := opts.TopN
+	if topN <= 0 {
+		topN = maxProviderCandidates
+	}
+
+	match := bleve.NewMatchQuery(queryText)
+	match.SetField("text")
+	var q query.Query = match
+	if clauses := bleveFilterClauses(opts.Filters); len(clauses) > 0 {
+		q = bleve.NewConjunctionQuery(append([]query.Query{match}, clauses...)...)
+	}
+	req := bleve.NewSearchRequestOptions(q, topN, 0, false)
+	req.Fields = []string{"chunk_json"}
+
+	res, err := idx.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		raw, _ := hit.Fields["chunk_json"].(string)
+		var c IndexedChunk
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Chunk:    c,
+			RawFused: hit.Score,
+			HasFused: true,
+		})
+	}
+	return candidates, nil
+}
+
+// maxFacetTerms bounds the per-field facet size; well above any sane
+// number of distinct doc types or confidentiality levels.
+const maxFacetTerms = 1000
+
+// CountChunks answers a filtered count with a zero-hit facet query:
+// bleve tallies matches per doc_type and confidentiality term inside the
+// engine, so no chunk content is loaded. Filters arrive pre-split to the
+// pushdown-eligible subset; excludeConfidentiality levels are dropped
+// from the match entirely.
+func (p *bleveProvider) CountChunks(ctx context.Context, filters SearchFilters, excludeConfidentiality []string) (*CountResult, error) {
+	p.mu.Lock()
+	idx, err := p.open(false)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var q query.Query = bleve.NewMatchAllQuery()
+	if clauses := bleveFilterClauses(filters); len(clauses) > 0 {
+		q = bleve.NewConjunctionQuery(append([]query.Query{q}, clauses...)...)
+	}
+	if len(excludeConfidentiality) > 0 {
+		terms := make([]query.Query, 0, len(excludeConfidentiality))
+		for _, level := range excludeConfidentiality {
+			tq := bleve.NewTermQuery(strings.ToLower(level))
+			tq.SetField("confidentiality")
+			terms = append(terms, tq)
+		}
+		bq := bleve.NewBooleanQuery()
+		bq.AddMust(q)
+		bq.AddMustNot(terms...)
+		q = bq
+	}
+
+	req := bleve.NewSearchRequestOptions(q, 0, 0, false)
+	req.AddFacet("doc_type", bleve.NewFacetRequest("doc_type", maxFacetTerms))
+	req.AddFacet("confidentiality", bleve.NewFacetRequest("confidentiality", maxFacetTerms))
+	res, err := idx.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := newCountResult()
+	out.Total = int(res.Total)
+	for name, into := range map[string]map[string]int{
+		"doc_type":        out.ByDocType,
+		"confidentiality": out.ByConfidentiality,
+	} {
+		fr := res.Facets[name]
+		if fr == nil {
+			continue
+		}
+		for _, term := range fr.Terms.Terms() {
+			into[term.Term] = term.Count
+		}
+		// Chunks without a value have no indexed term; count them under
+		// the empty key like the scan path does.
+		if fr.Missing > 0 {
+			into[""] = fr.Missing
+		}
+	}
+	return out, nil
+}
+
+func (p *bleveProvider) FetchChunk(ctx context.Context, sourcePath string, ordinal int) (*IndexedChunk, error) {
+	p.mu.Lock()
+	idx, err := p.open(false)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	q := bleve.NewDocIDQuery([]string{bleveDocID(sourcePath, ordinal)})
+	req := bleve.NewSearchRequestOptions(q, 1, 0, false)
+	req.Fields = []string{"chunk_json"}
+	res, err := idx.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Hits) == 0 {
+		return nil, fmt.Errorf("chunk %s#%d: %w", sourcePath, ordinal, ErrChunkNotFound)
+	}
+	raw, _ := res.Hits[0].Fields["chunk_json"].(string)
+	var c IndexedChunk
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (p *bleveProvider) LoadIndexInfo(ctx context.Context) (*IndexInfo, error) {
+	data, err := os.ReadFile(p.infoPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoIndex
+		}
+		return nil, err
+	}
+	var info IndexInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (p *bleveProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.index != nil {
+		err := p.index.Close()
+		p.index = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// newBleveTestService mirrors newTestService with the bleve provider so
+// pushdown behavior is exercised against the real engine.
+func newBleveTestService(t *testing.T, files map[string]string) *Service {
+	t.Helper()
+	workspace := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(workspace, "knowledge", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "bleve"})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	return svc
+}
+
+func TestBleveFilterPushdown(t *testing.T) {
+	svc := newBleveTestService(t, map[string]string{
+		"note.md":   "---\ntitle: Note\ndate: 2026-01-10\ndoc_type: Note\nconfidentiality: internal\n---\n\nThe aardvark budget was approved.\n",
+		"policy.md": "---\ntitle: Policy\ndate: 2026-01-11\ndoc_type: policy\nconfidentiality: public\n---\n\nThe aardvark policy applies to everyone.\n",
+		"secret.md": "---\ntitle: Secret\ndate: 2026-01-12\ndoc_type: note\nconfidentiality: restricted\n---\n\nThe aardvark acquisition is confidential.\n",
+	})
+	if !svc.provider.Capabilities().FilterPushdown {
+		t.Fatal("bleve provider should report FilterPushdown")
+	}
+	ctx := context.Background()
+
+	search := func(f SearchFilters) []EvidenceItemFull {
+		t.Helper()
+		result, err := svc.Search(ctx, SearchRequest{Query: "aardvark", TopK: 10, Filters: f})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		return result.Items
+	}
+	sources := func(items []EvidenceItemFull) map[string]bool {
+		out := make(map[string]bool, len(items))
+		for _, it := range items {
+			out[it.Ref.SourcePath] = true
+		}
+		return out
+	}
+
+	// No filters: the restricted hard gate still applies.
+	got := sources(search(SearchFilters{}))
+	if got["secret.md"] {
+		t.Error("restricted document leaked without AllowRestricted")
+	}
+	if !got["note.md"] {
+		t.Errorf("unfiltered search missing documents: %v", got)
+	}
+
+	// Doc-type pushdown is case-insensitive like the Go-side filter.
+	got = sources(search(SearchFilters{DocTypes: []string{"note"}}))
+	if got["policy.md"] {
+		t.Errorf("doc_type pushdown returned a policy document: %v", got)
+	}
+	if !got["note.md"] {
+		t.Errorf("doc_type pushdown dropped the mixed-case note: %v", got)
+	}
+	got = sources(search(SearchFilters{DocTypes: []string{"policy"}}))
+	if !got["policy.md"] || len(got) != 1 {
+		t.Errorf("policy pushdown returned %v", got)
+	}
+
+	// Allow-listing "restricted" must not bypass the hard gate: that check
+	// stays service-side even with pushdown active.
+	got = sources(search(SearchFilters{ConfidentialityAllow: []string{"restricted"}}))
+	if got["secret.md"] {
+		t.Error("restricted document leaked via ConfidentialityAllow pushdown")
+	}
+
+	// Allowing "restricted" is a ceiling too: lower levels stay eligible
+	// alongside the restricted document.
+	got = sources(search(SearchFilters{ConfidentialityAllow: []string{"restricted"}, AllowRestricted: true}))
+	if !got["secret.md"] || !got["note.md"] {
+		t.Errorf("explicit restricted access returned %v", got)
+	}
+
+	// The allow-list admits everything at or below each entry: "internal"
+	// covers public, and the pushdown expansion keeps that semantics
+	// in-engine.
+	result, err := svc.Search(ctx, SearchRequest{Query: "aardvark policy applies", TopK: 10,
+		Filters: SearchFilters{ConfidentialityAllow: []string{"internal"}}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	got = sources(result.Items)
+	if !got["policy.md"] || got["secret.md"] {
+		t.Errorf("internal ceiling returned %v", got)
+	}
+}
+
+func TestBleveDateRangePushdown(t *testing.T) {
+	svc := newBleveTestService(t, map[string]string{
+		"jan.md":     doc("January", "2026-01-05", "The aardvark visited in January."),
+		"feb.md":     doc("February", "2026-02-05", "The aardvark visited in February."),
+		"mar.md":     doc("March", "2026-03-05", "The aardvark visited in March."),
+		"undated.md": "---\ntitle: Undated\ndoc_type: note\n---\n\nThe aardvark visit has no date.\n",
+	})
+	ctx := context.Background()
+
+	search := func(f SearchFilters) map[string]bool {
+		t.Helper()
+		result, err := svc.Search(ctx, SearchRequest{Query: "aardvark", TopK: 10, Filters: f})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		out := make(map[string]bool, len(result.Items))
+		for _, it := range result.Items {
+			out[it.Ref.SourcePath] = true
+		}
+		return out
+	}
+
+	got := search(SearchFilters{DateFrom: "2026-01-15", DateTo: "2026-02-28"})
+	if !got["feb.md"] || got["jan.md"] || got["mar.md"] || got["undated.md"] {
+		t.Errorf("range filter returned %v, want only feb.md", got)
+	}
+
+	// Open-ended lower bound; the boundary date itself is inclusive.
+	got = search(SearchFilters{DateFrom: "2026-02-05"})
+	if !got["feb.md"] || !got["mar.md"] || got["jan.md"] || got["undated.md"] {
+		t.Errorf("open-ended range returned %v, want feb.md and mar.md", got)
+	}
+
+	// An unparseable bound falls back to the Go-side check, which still
+	// excludes undated documents.
+	got = search(SearchFilters{DateFrom: "not-a-date"})
+	if got["undated.md"] {
+		t.Errorf("undated document passed an invalid range filter: %v", got)
+	}
+}
+
+func TestBleveWithinDaysPushdown(t *testing.T) {
+	recent := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	old := time.Now().AddDate(0, 0, -200).Format("2006-01-02")
+	svc := newBleveTestService(t, map[string]string{
+		"fresh.md": doc("Fresh", recent, "The aardvark migration plan."),
+		"stale.md": doc("Stale", old, "The aardvark migration plan from long ago."),
+	})
+
+	result, err := svc.Search(context.Background(), SearchRequest{
+		Query:   "aardvark migration plan",
+		Filters: SearchFilters{WithinDays: 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 1 || result.Items[0].Ref.SourcePath != "fresh.md" {
+		t.Errorf("within 30 days should return only fresh.md, got %d items", len(result.Items))
+	}
+}
@@ -0,0 +1,765 @@
+package rag
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDocumentHardBytes is the per-file size ceiling; larger files are
+// skipped with a warning rather than chunked.
+const defaultDocumentHardBytes = 10 * 1024 * 1024
+
+// streamingChunkBytes is the file size above which a document is chunked
+// line-by-line from disk instead of being read fully into memory.
+const streamingChunkBytes = 1 << 20
+
+// defaultChunkConcurrency bounds the chunking worker pool when
+// Concurrency is not configured.
+const defaultChunkConcurrency = 4
+
+// defaultIndexExtensions is the extension set indexed when
+// IndexExtensions is not configured.
+var defaultIndexExtensions = []string{".md"}
+
+// resolveIndexExtensions normalizes a configured extension list:
+// lowercased, leading dot added, blanks dropped. An empty result falls
+// back to the default set.
+func resolveIndexExtensions(exts []string) []string {
+	out := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out = append(out, ext)
+	}
+	if len(out) == 0 {
+		return defaultIndexExtensions
+	}
+	return out
+}
+
+// isIndexedExt reports whether a path's extension is in the configured
+// set. A zero-value Service uses the default set.
+func (s *Service) isIndexedExt(path string) bool {
+	exts := s.indexExts
+	if len(exts) == 0 {
+		exts = defaultIndexExtensions
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkCandidate is one walk entry queued for the chunking worker pool.
+// A non-empty warning marks an entry that already failed a walk-time
+// check and only needs its warning recorded at the right position.
+type chunkCandidate struct {
+	path    string
+	relToKB string
+	entry   fs.DirEntry
+	warning string
+	// resolvedRoot is the symlink-resolved root the candidate came from;
+	// the symlink containment check runs against it.
+	resolvedRoot string
+}
+
+// chunkResult is the worker output for one candidate.
+type chunkResult struct {
+	chunks   []IndexedChunk
+	warnings []string
+}
+
+// buildChunksAndInfo walks the KB root, chunks every markdown document
+// and returns the chunk list plus an IndexInfo describing the build.
+// The walk enumerates candidates serially; reading and chunking runs on
+// a worker pool sized by Concurrency. Candidates are sorted by
+// KB-relative path and results are collected at each candidate's sorted
+// index, so chunk order — and thus chunk IDs — is deterministic and
+// filesystem-independent. Progress is emitted per collected document.
+// A non-zero since makes workers reuse the stored chunks in reuse for
+// candidates reusableChunks approves instead of re-chunking them.
+func (s *Service) buildChunksAndInfo(emit func(BuildEvent), since time.Time, reuse map[string][]IndexedChunk) ([]IndexedChunk, IndexInfo, error) {
+	hardBytes := s.cfg.DocumentHardBytes
+	if hardBytes <= 0 {
+		hardBytes = defaultDocumentHardBytes
+	}
+	emit(BuildEvent{Phase: PhaseWalking})
+
+	work, err := s.walkCandidates()
+	if err != nil {
+		return nil, IndexInfo{}, err
+	}
+
+	// Each worker writes only its own slot, so no locking is needed for
+	// results or warnings.
+	results := make([]chunkResult, len(work))
+	limit := s.cfg.Concurrency
+	if limit <= 0 {
+		limit = defaultChunkConcurrency
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < limit; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if work[i].warning != "" {
+					results[i] = chunkResult{warnings: []string{work[i].warning}}
+					continue
+				}
+				if old, ok := reusableChunks(work[i], since, reuse); ok {
+					results[i] = chunkResult{chunks: old}
+					continue
+				}
+				results[i] = s.chunkFile(work[i], hardBytes)
+			}
+		}()
+	}
+	for i := range work {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// seenContent, when dedup is on, maps content-only hashes to spot
+	// chunks copied verbatim between documents; collection runs in walk
+	// order, so the first occurrence wins deterministically.
+	var seenContent map[string]bool
+	if s.cfg.DedupeChunks {
+		seenContent = make(map[string]bool)
+	}
+	var (
+		chunks   []IndexedChunk
+		warnings []string
+		docCount int
+	)
+	for i := range results {
+		warnings = append(warnings, results[i].warnings...)
+		docChunks := results[i].chunks
+		if seenContent != nil {
+			kept := docChunks[:0]
+			skipped := 0
+			for _, c := range docChunks {
+				h := chunkContentHash(c.Text)
+				if seenContent[h] {
+					skipped++
+					continue
+				}
+				seenContent[h] = true
+				kept = append(kept, c)
+			}
+			docChunks = kept
+			if skipped > 0 {
+				warnings = append(warnings, fmt.Sprintf("dedupe_skipped:%s:%d", work[i].relToKB, skipped))
+			}
+		}
+		if len(docChunks) == 0 {
+			continue
+		}
+		docCount++
+		chunks = append(chunks, docChunks...)
+		emit(BuildEvent{Phase: PhaseChunking, Documents: docCount, Chunks: len(chunks)})
+	}
+
+	info := IndexInfo{
+		ChunkingHash:       s.chunkingHash(),
+		TotalDocuments:     docCount,
+		TotalChunks:        len(chunks),
+		Warnings:           warnings,
+		StructuredWarnings: ClassifyWarnings(warnings),
+		Stats:              computeCorpusStats(chunks),
+	}
+	return chunks, info, nil
+}
+
+// warningSeverity maps each warning code the build emits to its
+// severity class. security_path_blocked is the only security-severity
+// code: it means a file tried to escape the KB root via a symlink.
+var warningSeverity = map[string]string{
+	"walk_error":              SeverityWarn,
+	"security_path_blocked":   SeveritySecurity,
+	"doc_hard_limit":          SeverityWarn,
+	"read_error":              SeverityWarn,
+	"frontmatter_parse_error": SeverityWarn,
+	"boilerplate_stripped":    SeverityInfo,
+	"dedupe_skipped":          SeverityInfo,
+}
+
+// ClassifyWarning parses one flat "code:path" warning string into its
+// structured form. Counted codes (boilerplate_stripped, dedupe_skipped)
+// carry a trailing ":count" that is dropped from the path. Codes this
+// version does not know default to warn severity.
+func ClassifyWarning(w string) Warning {
+	code, path, _ := strings.Cut(w, ":")
+	switch code {
+	case "boilerplate_stripped", "dedupe_skipped":
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			path = path[:i]
+		}
+	}
+	sev, ok := warningSeverity[code]
+	if !ok {
+		sev = SeverityWarn
+	}
+	return Warning{Code: code, Path: path, Severity: sev}
+}
+
+// ClassifyWarnings classifies a flat warning list, preserving order.
+// It also backfills severities for indexes built before warnings were
+// stored structured.
+func ClassifyWarnings(warnings []string) []Warning {
+	if len(warnings) == 0 {
+		return nil
+	}
+	out := make([]Warning, len(warnings))
+	for i, w := range warnings {
+		out[i] = ClassifyWarning(w)
+	}
+	return out
+}
+
+// walkCandidates enumerates the indexable files under every KB root.
+// Directories are filtered through the denylist, files through the
+// extension set, and entries that failed a walk-time check carry a
+// warning instead of a path. With several roots each candidate's
+// relToKB is prefixed with the root's label so paths stay unambiguous.
+// The result is sorted by (labeled) KB-relative path: WalkDir is
+// lexical per directory, which almost — but not quite — matches that
+// order ("a.c/x.md" walks after "a/x.md" but sorts before it). Chunk
+// IDs follow chunk order, so pinning the sorted order the splice
+// helpers already assume makes builds produce identical IDs on every
+// filesystem.
+func (s *Service) walkCandidates() ([]chunkCandidate, error) {
+	var work []chunkCandidate
+	for _, root := range s.kbRoots {
+		resolvedRoot := mustResolve(root.path)
+		err := filepath.WalkDir(root.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root.path {
+					return fmt.Errorf("knowledge base root %s does not exist", root.path)
+				}
+				work = append(work, chunkCandidate{warning: "walk_error:" + path})
+				return nil
+			}
+			if d.IsDir() {
+				if s.isDenied(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !s.isIndexedExt(path) {
+				return nil
+			}
+			relToKB, err := filepath.Rel(root.path, path)
+			if err != nil {
+				work = append(work, chunkCandidate{warning: "walk_error:" + path})
+				return nil
+			}
+			relToKB = filepath.ToSlash(relToKB)
+			if root.label != "" {
+				relToKB = root.label + "/" + relToKB
+			}
+			work = append(work, chunkCandidate{path: path, relToKB: relToKB, entry: d, resolvedRoot: resolvedRoot})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(work, func(i, j int) bool { return work[i].relToKB < work[j].relToKB })
+	return work, nil
+}
+
+// reusableChunks decides whether a time-bounded build may keep a
+// candidate's stored chunks: its mtime must predate since AND its
+// content must still hash to the stored DocumentVersion, mirroring the
+// PlanIndex comparison. Files modified at or after since always
+// re-chunk, and so do documents whose stored version is empty — a
+// pre-DocumentVersion index cannot prove anything unchanged.
+func reusableChunks(c chunkCandidate, since time.Time, reuse map[string][]IndexedChunk) ([]IndexedChunk, bool) {
+	if since.IsZero() {
+		return nil, false
+	}
+	// Every chunk of a document carries the same DocumentVersion.
+	old := reuse[c.relToKB]
+	if len(old) == 0 || old[0].DocumentVersion == "" {
+		return nil, false
+	}
+	fi, err := c.entry.Info()
+	if err != nil || !fi.ModTime().Before(since) {
+		return nil, false
+	}
+	version, err := documentVersionFile(c.path)
+	if err != nil || version != old[0].DocumentVersion {
+		return nil, false
+	}
+	return old, true
+}
+
+// documentVersionFile computes the DocumentVersion a rebuild would stamp
+// on a file's chunks: the sha256 of its raw bytes, matching both
+// chunkDocument's in-memory hash and streamChunkDocument's streaming
+// hash. It streams, so it is safe on files past the hard limit.
+func documentVersionFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// chunkFile runs the per-file checks and chunking for one candidate. It
+// is called from the worker pool, so everything it touches on the
+// service must be read-only.
+func (s *Service) chunkFile(c chunkCandidate, hardBytes int64) chunkResult {
+	// Refuse symlinks resolving outside the candidate's own KB root.
+	if resolved, err := filepath.EvalSymlinks(c.path); err != nil ||
+		!strings.HasPrefix(resolved, c.resolvedRoot+string(filepath.Separator)) {
+		return chunkResult{warnings: []string{"security_path_blocked:" + c.relToKB}}
+	}
+
+	var size int64
+	if info, err := c.entry.Info(); err == nil {
+		size = info.Size()
+	}
+	if size > hardBytes {
+		return chunkResult{warnings: []string{"doc_hard_limit:" + c.relToKB}}
+	}
+
+	if size > streamingChunkBytes {
+		docChunks, docWarnings, err := s.streamChunkDocument(c.path, c.relToKB)
+		if err != nil {
+			return chunkResult{warnings: []string{"read_error:" + c.relToKB}}
+		}
+		return chunkResult{chunks: docChunks, warnings: docWarnings}
+	}
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		return chunkResult{warnings: []string{"read_error:" + c.relToKB}}
+	}
+	docChunks, docWarnings := s.chunkDocument(c.relToKB, string(raw))
+	return chunkResult{chunks: docChunks, warnings: docWarnings}
+}
+
+// computeCorpusStats aggregates corpus-wide statistics over the final
+// chunk set. Counts are per chunk, not per document, so they reflect
+// what retrieval actually ranks over.
+func computeCorpusStats(chunks []IndexedChunk) *CorpusStats {
+	if len(chunks) == 0 {
+		return nil
+	}
+	stats := &CorpusStats{
+		DocTypes:        make(map[string]int),
+		Confidentiality: make(map[string]int),
+	}
+	lengths := make([]int, 0, len(chunks))
+	vocab := make(map[string]struct{})
+	total := 0
+	for i := range chunks {
+		n := runeLen(chunks[i].Text)
+		lengths = append(lengths, n)
+		total += n
+		for _, tok := range tokenize(chunks[i].Text) {
+			vocab[tok] = struct{}{}
+		}
+		stats.DocTypes[strings.ToLower(chunks[i].DocType)]++
+		stats.Confidentiality[strings.ToLower(chunks[i].Confidentiality)]++
+	}
+	sort.Ints(lengths)
+	stats.AvgChunkRunes = float64(total) / float64(len(chunks))
+	stats.MedianChunkRunes = lengths[len(lengths)/2]
+	stats.VocabSize = len(vocab)
+	return stats
+}
+
+// spliceDocumentChunks removes sourcePath's chunks from the id-ordered
+// chunk list and inserts the replacements where the document sorts, so
+// incremental updates keep (approximately) the ordering a full lexical
+// walk would produce and tie-breaking stays stable.
+func spliceDocumentChunks(all []IndexedChunk, sourcePath string, repl []IndexedChunk) []IndexedChunk {
+	out := make([]IndexedChunk, 0, len(all)+len(repl))
+	inserted := false
+	for _, c := range all {
+		if c.SourcePath == sourcePath {
+			continue
+		}
+		if !inserted && c.SourcePath > sourcePath {
+			out = append(out, repl...)
+			inserted = true
+		}
+		out = append(out, c)
+	}
+	if !inserted {
+		out = append(out, repl...)
+	}
+	return out
+}
+
+// spliceDocumentVectors mirrors spliceDocumentChunks for a vector list
+// parallel to the pre-splice chunk slice: vectors of the replaced
+// document are dropped and repl inserted at the same sorted position.
+func spliceDocumentVectors(all []IndexedChunk, vecs [][]float32, sourcePath string, repl [][]float32) [][]float32 {
+	out := make([][]float32, 0, len(vecs)+len(repl))
+	inserted := false
+	for i, c := range all {
+		if c.SourcePath == sourcePath {
+			continue
+		}
+		if !inserted && c.SourcePath > sourcePath {
+			out = append(out, repl...)
+			inserted = true
+		}
+		out = append(out, vecs[i])
+	}
+	if !inserted {
+		out = append(out, repl...)
+	}
+	return out
+}
+
+// refreshIndexInfo recomputes the derived IndexInfo fields after an
+// incremental document update.
+func refreshIndexInfo(info *IndexInfo, chunks []IndexedChunk) {
+	docs := make(map[string]struct{}, len(chunks))
+	for i := range chunks {
+		docs[chunks[i].SourcePath] = struct{}{}
+	}
+	info.TotalDocuments = len(docs)
+	info.TotalChunks = len(chunks)
+	info.BuiltAt = time.Now().UTC()
+	info.IndexVersion = indexVersion(chunks, info.ChunkingHash)
+	info.Stats = computeCorpusStats(chunks)
+}
+
+// normalizeDocContent prepares raw file content for parsing: a leading
+// UTF-8 BOM (files authored on Windows or exported by some tools) is
+// stripped and CRLF becomes LF. Without this parseFrontmatter never sees
+// its `---\n` prefix and all the document's metadata is silently lost.
+func normalizeDocContent(content string) string {
+	content = strings.TrimPrefix(content, "\ufeff")
+	return strings.ReplaceAll(content, "\r\n", "\n")
+}
+
+// chunkDocument turns one markdown file into indexed chunks: frontmatter
+// parsing, boilerplate stripping, splitting, secret masking and
+// injection-risk annotation. The second return value carries per-document
+// warnings for the index info.
+func (s *Service) chunkDocument(relToKB, content string) ([]IndexedChunk, []string) {
+	var warnings []string
+	// DocumentVersion hashes the raw bytes so it agrees with the streaming
+	// path; everything downstream sees the normalized form, since a BOM or
+	// CRLF delimiter line would otherwise hide the frontmatter entirely.
+	docVersion := sha256Hex([]byte(content))
+	meta, body, err := parseFrontmatter(normalizeDocContent(content))
+	if err != nil {
+		warnings = append(warnings, "frontmatter_parse_error:"+relToKB)
+	}
+	body, stripped := s.stripBoilerplate(body)
+	if stripped > 0 {
+		warnings = append(warnings, fmt.Sprintf("boilerplate_stripped:%s:%d", relToKB, stripped))
+	}
+	if isMDXPath(relToKB) {
+		body = stripMDX(body)
+	}
+
+	raws := splitMarkdownChunks(body, s.chunkerCfg())
+	out := make([]IndexedChunk, 0, len(raws))
+	for i, rc := range raws {
+		text := s.maskSecrets(rc.Text)
+		risk, flags := s.detectInjectionRisk(text)
+		out = append(out, IndexedChunk{
+			SourcePath:      relToKB,
+			ChunkOrdinal:    i + 1,
+			ParagraphID:     sha256Hex([]byte(relToKB + "\x00" + normalizeText(text))),
+			DocumentVersion: docVersion,
+			Text:            text,
+			HeadingPath:     rc.HeadingPath,
+			ChunkLoc:        chunkLoc(rc),
+			ParentID:        parentID(relToKB, rc.HeadingPath),
+			Title:           meta.Title,
+			Date:            meta.Date,
+			DocType:         meta.DocType,
+			Confidentiality: meta.Confidentiality,
+			Project:         meta.Project,
+			Tags:            meta.Tags,
+			Metadata:        meta.Extra,
+			Flags:           flags,
+			RiskScore:       risk,
+		})
+	}
+	return out, warnings
+}
+
+// streamChunkDocument is the large-file variant of chunkDocument: it
+// reads the file line-by-line, hashing bytes as they pass, and feeds the
+// incremental chunker so neither the whole document nor its raw chunks
+// are ever resident at once. Boilerplate patterns are applied per line
+// on this path, so patterns spanning lines only match on the in-memory
+// path.
+func (s *Service) streamChunkDocument(path, relToKB string) ([]IndexedChunk, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(f, hasher))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var (
+		out      []IndexedChunk
+		meta     docMeta
+		warnings []string
+		stripped int
+	)
+	lc := newLineChunker(s.chunkerCfg(), func(rc RawChunk) {
+		text := s.maskSecrets(rc.Text)
+		risk, flags := s.detectInjectionRisk(text)
+		out = append(out, IndexedChunk{
+			SourcePath:      relToKB,
+			ChunkOrdinal:    len(out) + 1,
+			ParagraphID:     sha256Hex([]byte(relToKB + "\x00" + normalizeText(text))),
+			Text:            text,
+			HeadingPath:     rc.HeadingPath,
+			ChunkLoc:        chunkLoc(rc),
+			ParentID:        parentID(relToKB, rc.HeadingPath),
+			Title:           meta.Title,
+			Date:            meta.Date,
+			DocType:         meta.DocType,
+			Confidentiality: meta.Confidentiality,
+			Project:         meta.Project,
+			Tags:            meta.Tags,
+			Metadata:        meta.Extra,
+			Flags:           flags,
+			RiskScore:       risk,
+		})
+	})
+
+	mdx := isMDXPath(relToKB)
+	// Frontmatter state: 0 = before the first line, 1 = inside the block,
+	// 2 = body.
+	fmState := 0
+	var fmLines []string
+	trimLeading := false
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch fmState {
+		case 0:
+			// Mirror normalizeDocContent: a BOM would otherwise hide the
+			// opening frontmatter delimiter.
+			line = strings.TrimPrefix(line, "\ufeff")
+			fmState = 2
+			if line == "---" {
+				fmState = 1
+				continue
+			}
+		case 1:
+			if strings.HasPrefix(line, "---") {
+				var fmErr error
+				meta, fmErr = parseFrontmatterBlock(strings.Join(fmLines, "\n"))
+				if fmErr != nil {
+					warnings = append(warnings, "frontmatter_parse_error:"+relToKB)
+				}
+				fmState = 2
+				// parseFrontmatter trims blank lines between the block and
+				// the body; mirror that so chunk line numbers agree.
+				trimLeading = true
+			} else {
+				fmLines = append(fmLines, line)
+			}
+			continue
+		}
+		if trimLeading {
+			if line == "" {
+				continue
+			}
+			trimLeading = false
+		}
+		line, n := s.stripBoilerplateLine(line)
+		stripped += n
+		if mdx && isMDXStripLine(line) {
+			line = ""
+		}
+		lc.feed(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	// strings.Split yields one final empty line for a trailing newline;
+	// bufio.Scanner does not. Feed it so both paths chunk identically.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		var tail [1]byte
+		if _, err := f.ReadAt(tail[:], fi.Size()-1); err == nil && tail[0] == '\n' {
+			lc.feed("")
+		}
+	}
+	if fmState == 1 {
+		// Unterminated frontmatter: treat the collected lines as body,
+		// matching parseFrontmatter.
+		lc.feed("---")
+		for _, l := range fmLines {
+			lc.feed(l)
+		}
+	}
+	lc.finish()
+
+	docVersion := hex.EncodeToString(hasher.Sum(nil))
+	for i := range out {
+		out[i].DocumentVersion = docVersion
+	}
+	if stripped > 0 {
+		warnings = append(warnings, fmt.Sprintf("boilerplate_stripped:%s:%d", relToKB, stripped))
+	}
+	return out, warnings, nil
+}
+
+// isMDXPath reports whether a document needs MDX preprocessing.
+func isMDXPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mdx")
+}
+
+// jsxTagLine matches a line that is only a JSX tag — opening, closing or
+// self-closing — like "<Callout type=\"warn\">" or "</Callout>".
+var jsxTagLine = regexp.MustCompile(`^</?[A-Za-z][^<>]*/?>$`)
+
+// isMDXStripLine reports whether an MDX line carries no prose: ESM
+// imports and lines that are only a JSX tag.
+func isMDXStripLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "import ") || jsxTagLine.MatchString(trimmed)
+}
+
+// stripMDX blanks import and JSX-only lines in an MDX body so component
+// scaffolding is never chunked or embedded. Lines are blanked rather
+// than removed so chunk line numbers still match the file.
+func stripMDX(body string) string {
+	lines := strings.Split(body, "\n")
+	changed := false
+	for i, line := range lines {
+		if line != "" && isMDXStripLine(line) {
+			lines[i] = ""
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripBoilerplate removes every configured boilerplate pattern from a
+// document body before chunking, so recurring legal footers and headers
+// never reach embedding or scoring. It returns the cleaned body and the
+// number of matches removed.
+func (s *Service) stripBoilerplate(body string) (string, int) {
+	stripped := 0
+	for _, re := range s.boilerplate {
+		matches := re.FindAllStringIndex(body, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		stripped += len(matches)
+		body = re.ReplaceAllString(body, "")
+	}
+	return body, stripped
+}
+
+// stripBoilerplateLine is the per-line form used by the streaming path.
+func (s *Service) stripBoilerplateLine(line string) (string, int) {
+	stripped := 0
+	for _, re := range s.boilerplate {
+		matches := re.FindAllStringIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		stripped += len(matches)
+		line = re.ReplaceAllString(line, "")
+	}
+	return line, stripped
+}
+
+// chunkerCfg resolves the chunker configuration from service config.
+func (s *Service) chunkerCfg() chunkerConfig {
+	return chunkerConfig{
+		SoftLimit:      s.cfg.ChunkSoftLimit,
+		HardLimit:      s.cfg.ChunkHardLimit,
+		AttachHeadings: s.cfg.AttachHeadings,
+	}.withDefaults()
+}
+
+// chunkingHash fingerprints every parameter that affects chunk identity
+// or scoring identity, so a config change is detectable as an
+// index/config mismatch and eval sweeps with different BM25 tunings get
+// distinguishable index versions.
+func (s *Service) chunkingHash() string {
+	cfg := s.chunkerCfg()
+	return sha256Hex([]byte(fmt.Sprintf("v3|soft=%d|hard=%d|attach=%t|bm25=%g,%g",
+		cfg.SoftLimit, cfg.HardLimit, cfg.AttachHeadings, s.cfg.BM25K1, s.cfg.BM25B)))[:16]
+}
+
+// chunkContentHash is the path-independent content fingerprint used for
+// corpus-level dedup; ParagraphID stays path-keyed so per-document
+// caching and references survive a file rename.
+func chunkContentHash(text string) string {
+	return sha256Hex([]byte(normalizeText(text)))
+}
+
+// parentID identifies the section a chunk belongs to: the document plus
+// the heading path above the chunk. Every chunk split out of the same
+// section shares it, which is what lets ReturnParent reassemble the
+// section from its children.
+func parentID(relToKB string, headingPath []string) string {
+	return sha256Hex([]byte(relToKB + "\x00" + strings.Join(headingPath, "\x00")))[:16]
+}
+
+// isDenied reports whether a directory name is excluded from indexing.
+func (s *Service) isDenied(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, d := range s.cfg.Denylist {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func mustResolve(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
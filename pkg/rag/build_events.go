@@ -0,0 +1,65 @@
+package rag
+
+import (
+	"context"
+	"time"
+)
+
+// Phases a BuildEvent can report, in the order a build moves through them.
+const (
+	PhaseWalking   = "walking"
+	PhaseChunking  = "chunking"
+	PhaseEmbedding = "embedding"
+	PhaseFlushing  = "flushing"
+	PhaseDone      = "done"
+)
+
+// BuildEvent is one structured progress update from an index build, so
+// UIs can render a real progress bar instead of tailing log lines.
+type BuildEvent struct {
+	Phase     string `json:"phase"`
+	Documents int    `json:"documents,omitempty"`
+	Chunks    int    `json:"chunks,omitempty"`
+	// Completed and Total track within-phase progress where the phase
+	// knows it (e.g. embedded chunks out of all chunks).
+	Completed int `json:"completed,omitempty"`
+	Total     int `json:"total,omitempty"`
+}
+
+// BuildResult is the single value delivered on the result channel of
+// BuildIndexWithEvents.
+type BuildResult struct {
+	Info *IndexInfo
+	Err  error
+}
+
+// progressBuilder is implemented by providers that can report progress
+// from inside their Build (embedding batches, flushing). Providers
+// without it get a single flushing event from the service instead.
+type progressBuilder interface {
+	BuildWithProgress(ctx context.Context, chunks []IndexedChunk, info IndexInfo, progress func(BuildEvent)) error
+}
+
+// BuildIndexWithEvents runs BuildIndex asynchronously and streams typed
+// progress events. Both channels are closed when the build finishes and
+// the result channel receives exactly one value. Events are dropped
+// rather than blocking the build when the consumer lags.
+func (s *Service) BuildIndexWithEvents(ctx context.Context) (<-chan BuildEvent, <-chan BuildResult) {
+	events := make(chan BuildEvent, 16)
+	results := make(chan BuildResult, 1)
+
+	go func() {
+		defer close(results)
+		defer close(events)
+		emit := func(ev BuildEvent) {
+			select {
+			case events <- ev:
+			default:
+			}
+		}
+		info, err := s.buildIndex(ctx, emit, time.Time{})
+		results <- BuildResult{Info: info, Err: err}
+	}()
+
+	return events, results
+}
@@ -0,0 +1,38 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBuildIndexWithEvents(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "alpha content"),
+		"b.md": doc("B", "2026-01-02", "beta content"),
+	})
+
+	events, results := svc.BuildIndexWithEvents(context.Background())
+
+	seen := make(map[string]bool)
+	var last BuildEvent
+	for ev := range events {
+		seen[ev.Phase] = true
+		last = ev
+	}
+	res := <-results
+
+	if res.Err != nil {
+		t.Fatalf("build failed: %v", res.Err)
+	}
+	if res.Info == nil || res.Info.TotalDocuments != 2 {
+		t.Fatalf("unexpected info: %+v", res.Info)
+	}
+	for _, phase := range []string{PhaseWalking, PhaseChunking, PhaseFlushing, PhaseDone} {
+		if !seen[phase] {
+			t.Errorf("phase %s never emitted", phase)
+		}
+	}
+	if last.Phase != PhaseDone || last.Documents != 2 {
+		t.Errorf("final event = %+v, want done with 2 documents", last)
+	}
+}
@@ -0,0 +1,686 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// TestStreamChunkDocumentMatchesInMemory pins the streaming chunker to
+// the in-memory path: identical chunks, metadata, paragraph ids and
+// document version for the same file.
+func TestStreamChunkDocumentMatchesInMemory(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("# Overview\n\nIntro paragraph.\n\n")
+	body.WriteString("## Details\n\n")
+	body.WriteString("```go\n# not a heading inside the fence\nfunc main() {}\n```\n\n")
+	body.WriteString(strings.Repeat("A long sentence padding the paragraph well past limits. ", 80))
+	body.WriteString("\n\n### Wrap-up\n\nFinal words.\n")
+	content := "---\ntitle: Parity Doc\ndate: 2026-02-01\ndoc_type: note\ntags: [a, b]\nteam: platform\n---\n\n" + body.String()
+
+	workspace := t.TempDir()
+	svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple"})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+
+	path := filepath.Join(workspace, "doc.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inMemory, _ := svc.chunkDocument("doc.md", content)
+	streamed, _, err := svc.streamChunkDocument(path, "doc.md")
+	if err != nil {
+		t.Fatalf("streamChunkDocument: %v", err)
+	}
+
+	if len(streamed) != len(inMemory) {
+		t.Fatalf("chunk count: streamed %d, in-memory %d", len(streamed), len(inMemory))
+	}
+	for i := range inMemory {
+		if !reflect.DeepEqual(streamed[i], inMemory[i]) {
+			t.Errorf("chunk %d differs:\nstreamed:  %+v\nin-memory: %+v", i, streamed[i], inMemory[i])
+		}
+	}
+}
+
+func TestComputeCorpusStats(t *testing.T) {
+	chunks := []IndexedChunk{
+		{Text: "alpha beta gamma", DocType: "note"},
+		{Text: "alpha beta", DocType: "Note", Confidentiality: "internal"},
+		{Text: "delta epsilon zeta eta theta", DocType: "policy"},
+	}
+	stats := computeCorpusStats(chunks)
+	if stats == nil {
+		t.Fatal("expected stats for non-empty corpus")
+	}
+	if stats.VocabSize != 8 {
+		t.Errorf("vocab size = %d, want 8", stats.VocabSize)
+	}
+	if stats.MedianChunkRunes != runeLen("alpha beta gamma") {
+		t.Errorf("median = %d", stats.MedianChunkRunes)
+	}
+	if stats.DocTypes["note"] != 2 || stats.DocTypes["policy"] != 1 {
+		t.Errorf("doc type counts = %v", stats.DocTypes)
+	}
+	if stats.Confidentiality[""] != 2 || stats.Confidentiality["internal"] != 1 {
+		t.Errorf("confidentiality counts = %v", stats.Confidentiality)
+	}
+
+	if computeCorpusStats(nil) != nil {
+		t.Error("empty corpus should have no stats")
+	}
+}
+
+// TestChunkDocumentParentID pins the section-identity contract: chunks
+// split out of the same heading section share a ParentID, chunks under
+// different sections do not.
+func TestChunkDocumentParentID(t *testing.T) {
+	s := &Service{cfg: config.RAGToolsConfig{ChunkSoftLimit: 30}}
+	content := "## Alpha\n\nFirst paragraph under alpha section.\n\nSecond paragraph under alpha section.\n\n## Beta\n\nParagraph under beta section.\n"
+
+	chunks, _ := s.chunkDocument("doc.md", content)
+	byText := func(substr string) *IndexedChunk {
+		for i := range chunks {
+			if strings.Contains(chunks[i].Text, substr) {
+				return &chunks[i]
+			}
+		}
+		t.Fatalf("no chunk contains %q", substr)
+		return nil
+	}
+
+	first := byText("First paragraph")
+	second := byText("Second paragraph")
+	beta := byText("beta section")
+	if first == second {
+		t.Fatal("fixture did not split the alpha section into separate chunks")
+	}
+	if first.ParentID == "" || first.ParentID != second.ParentID {
+		t.Errorf("alpha chunks should share a parent: %q vs %q", first.ParentID, second.ParentID)
+	}
+	if beta.ParentID == first.ParentID {
+		t.Error("beta chunk shares the alpha parent id")
+	}
+
+	other, _ := s.chunkDocument("other.md", content)
+	if other[0].ParentID == chunks[0].ParentID {
+		t.Error("parent ids should be scoped to the document")
+	}
+}
+
+// TestChunkDocumentBOMAndCRLF covers files authored on Windows: a UTF-8
+// BOM and CRLF delimiter lines must not hide the frontmatter.
+func TestChunkDocumentBOMAndCRLF(t *testing.T) {
+	s := &Service{cfg: config.RAGToolsConfig{}}
+	content := "\ufeff---\r\ntitle: Windows Doc\r\ndate: 2026-04-01\r\ntags: [ops, windows]\r\n---\r\n\r\nBody paragraph written with CRLF endings.\r\n"
+
+	chunks, warnings := s.chunkDocument("win.md", content)
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if len(chunks) != 1 {
+		t.Fatalf("got %d chunks, want 1", len(chunks))
+	}
+	c := chunks[0]
+	if c.Title != "Windows Doc" || c.Date != "2026-04-01" {
+		t.Errorf("metadata lost: title %q, date %q", c.Title, c.Date)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"ops", "windows"}) {
+		t.Errorf("tags = %v, want [ops windows]", c.Tags)
+	}
+	if strings.Contains(c.Text, "\ufeff") || strings.Contains(c.Text, "---") {
+		t.Errorf("chunk text kept the BOM or frontmatter: %q", c.Text)
+	}
+
+	// The streaming path sees the same file line-by-line and must agree
+	// on the metadata.
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "win.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	streamed, warnings, err := s.streamChunkDocument(path, "win.md")
+	if err != nil {
+		t.Fatalf("streamChunkDocument: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("streaming warnings: %v", warnings)
+	}
+	if len(streamed) != 1 || streamed[0].Title != "Windows Doc" || !reflect.DeepEqual(streamed[0].Tags, c.Tags) {
+		t.Errorf("streaming path disagrees: %+v", streamed)
+	}
+}
+
+func TestReindexFile(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "alpha content about rollouts"),
+		"b.md": doc("B", "2026-01-02", "beta content about consumers"),
+	})
+	ctx := context.Background()
+
+	// Edit one file on disk and reindex just that document.
+	full := filepath.Join(svc.kbRoots[0].path, "a.md")
+	if err := os.WriteFile(full, []byte(doc("A", "2026-01-03", "alpha content now mentions zeppelins")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ReindexFile(ctx, "a.md"); err != nil {
+		t.Fatalf("ReindexFile: %v", err)
+	}
+
+	result, err := svc.Search(ctx, SearchRequest{Query: "zeppelins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Ref.SourcePath != "a.md" {
+		t.Fatalf("edited content not searchable after ReindexFile: %v", rankingOf(result))
+	}
+
+	// The untouched document must survive the splice.
+	result, err = svc.Search(ctx, SearchRequest{Query: "beta consumers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Ref.SourcePath != "b.md" {
+		t.Errorf("b.md lost after reindexing a.md: %v", rankingOf(result))
+	}
+
+	// Deleting the file removes the document from the index.
+	if err := os.Remove(full); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ReindexFile(ctx, "a.md"); err != nil {
+		t.Fatalf("ReindexFile after delete: %v", err)
+	}
+	result, err = svc.Search(ctx, SearchRequest{Query: "zeppelins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 0 {
+		t.Errorf("deleted document still searchable: %v", rankingOf(result))
+	}
+
+	info, err := svc.IndexInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalDocuments != 1 {
+		t.Errorf("TotalDocuments = %d after removal, want 1", info.TotalDocuments)
+	}
+
+	if err := svc.ReindexFile(ctx, "../outside.md"); err == nil {
+		t.Error("expected error for path outside the KB root")
+	}
+}
+
+func TestSpliceDocumentChunks(t *testing.T) {
+	all := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1},
+		{SourcePath: "b.md", ChunkOrdinal: 1},
+		{SourcePath: "b.md", ChunkOrdinal: 2},
+		{SourcePath: "c.md", ChunkOrdinal: 1},
+	}
+	repl := []IndexedChunk{
+		{SourcePath: "b.md", ChunkOrdinal: 1},
+	}
+	out := spliceDocumentChunks(all, "b.md", repl)
+	want := []string{"a.md", "b.md", "c.md"}
+	if len(out) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(out))
+	}
+	for i, w := range want {
+		if out[i].SourcePath != w {
+			t.Errorf("out[%d] = %s, want %s", i, out[i].SourcePath, w)
+		}
+	}
+
+	// Removal leaves the remaining order intact.
+	out = spliceDocumentChunks(all, "a.md", nil)
+	if len(out) != 3 || out[0].SourcePath != "b.md" {
+		t.Errorf("removal produced %v", out)
+	}
+	// A brand-new document lands at its sorted position.
+	out = spliceDocumentChunks(all, "ba.md", []IndexedChunk{{SourcePath: "ba.md", ChunkOrdinal: 1}})
+	if out[3].SourcePath != "ba.md" || out[4].SourcePath != "c.md" {
+		t.Errorf("insert position wrong: %v", out)
+	}
+}
+
+func TestBuildIndexExtensions(t *testing.T) {
+	workspace := t.TempDir()
+	files := map[string]string{
+		"plain.md":        doc("Plain", "2026-02-01", "The aardvark lives in plain markdown."),
+		"legacy.markdown": doc("Legacy", "2026-02-02", "The zebra lives in legacy markdown."),
+		"component.mdx": "---\ntitle: Component\ndate: 2026-02-03\ndoc_type: note\n---\n\n" +
+			"import {Callout} from \"@theme/Callout\"\n\n" +
+			"<Callout type=\"warn\">\n\nThe okapi hides between JSX tags.\n\n</Callout>\n",
+		"notes.txt": "The pangolin is not markdown at all.",
+	}
+	for path, content := range files {
+		full := filepath.Join(workspace, "knowledge", path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc, err := NewService(workspace, config.RAGToolsConfig{
+		IndexProvider:   "simple",
+		IndexExtensions: []string{".md", "mdx", ".markdown"},
+	})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+
+	info, err := svc.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	if info.TotalDocuments != 3 {
+		t.Errorf("TotalDocuments = %d, want 3 (.txt must stay excluded)", info.TotalDocuments)
+	}
+
+	for query, want := range map[string]string{
+		"aardvark": "plain.md",
+		"zebra":    "legacy.markdown",
+		"okapi":    "component.mdx",
+	} {
+		hits, err := svc.provider.Search(context.Background(), query, ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) == 0 || hits[0].Chunk.SourcePath != want {
+			t.Errorf("query %q did not resolve to %s: %+v", query, want, hits)
+		}
+	}
+}
+
+func TestStripMDX(t *testing.T) {
+	s := &Service{}
+	content := "import {Callout} from \"@theme/Callout\"\n\n" +
+		"<Callout type=\"warn\">\n\nProse survives, including a < b comparisons.\n\n</Callout>\n\n" +
+		"<Figure src=\"diagram.png\" />\n"
+
+	chunks, _ := s.chunkDocument("guide.mdx", content)
+	if len(chunks) == 0 {
+		t.Fatal("no chunks produced")
+	}
+	for _, c := range chunks {
+		if strings.Contains(c.Text, "import ") || strings.Contains(c.Text, "<Callout") ||
+			strings.Contains(c.Text, "</Callout>") || strings.Contains(c.Text, "<Figure") {
+			t.Errorf("JSX scaffolding leaked into chunk: %q", c.Text)
+		}
+	}
+	if !strings.Contains(chunks[0].Text, "a < b comparisons") {
+		t.Errorf("prose with an inline < was altered: %q", chunks[0].Text)
+	}
+
+	// .md files keep the same lines verbatim.
+	mdChunks, _ := s.chunkDocument("guide.md", content)
+	if len(mdChunks) == 0 || !strings.Contains(mdChunks[0].Text, "import {Callout}") {
+		t.Error("non-MDX document should not be preprocessed")
+	}
+}
+
+func TestDedupeChunks(t *testing.T) {
+	shared := "## Disclaimer\n\nStandard disclaimer paragraph copied into every document verbatim."
+	files := map[string]string{
+		"a.md": doc("A", "2026-01-01", shared+"\n\n## Alpha\n\nUnique alpha content about aardvarks."),
+		"b.md": doc("B", "2026-01-02", shared+"\n\n## Beta\n\nUnique beta content about zebras."),
+	}
+
+	build := func(dedupe bool) (*Service, *IndexInfo) {
+		t.Helper()
+		workspace := t.TempDir()
+		for path, content := range files {
+			full := filepath.Join(workspace, "knowledge", path)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple", DedupeChunks: dedupe})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		info, err := svc.BuildIndex(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svc, info
+	}
+
+	_, plain := build(false)
+	svc, deduped := build(true)
+	if deduped.TotalChunks >= plain.TotalChunks {
+		t.Errorf("deduped build has %d chunks, plain %d; want fewer", deduped.TotalChunks, plain.TotalChunks)
+	}
+
+	found := false
+	for _, w := range deduped.Warnings {
+		if strings.HasPrefix(w, "dedupe_skipped:b.md") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no dedupe_skipped warning for b.md: %v", deduped.Warnings)
+	}
+
+	// The first occurrence survives and is still searchable.
+	hits, err := svc.provider.Search(context.Background(), "standard disclaimer", ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Chunk.SourcePath != "a.md" {
+		t.Errorf("disclaimer hits = %+v, want one from a.md", hits)
+	}
+	// Unique content in the deduped document is unaffected.
+	hits, err = svc.provider.Search(context.Background(), "zebras", ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Chunk.SourcePath != "b.md" {
+		t.Errorf("unique-content hits = %+v, want one from b.md", hits)
+	}
+}
+
+// TestBuildChunksAndInfoConcurrency pins the worker-pool build to the
+// serial one: for the same corpus, chunk order, paragraph ids, warnings
+// and counts must be identical regardless of Concurrency.
+func TestBuildChunksAndInfoConcurrency(t *testing.T) {
+	files := map[string]string{
+		"zebra.md": "# Zebra\n\nStriped grazers of the savanna.\n",
+		"sub/a.md": "---\ntitle: Alpha\ndate: 2026-01-05\ndoc_type: note\n---\n\n# Alpha\n\nFirst document body.\n",
+		"sub/b.md": "# Beta\n\nSecond document body.\n\n## Detail\n\nMore prose here.\n",
+		"deep/nested/c.md": "# Gamma\n\n" +
+			strings.Repeat("A sentence padding the paragraph toward the soft limit. ", 40) + "\n",
+		"notes.txt": "not indexed\n",
+	}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("gen/doc-%02d.md", i)] = fmt.Sprintf("# Doc %d\n\nBody of generated document %d.\n", i, i)
+	}
+
+	build := func(concurrency int) ([]IndexedChunk, IndexInfo) {
+		t.Helper()
+		workspace := t.TempDir()
+		for path, content := range files {
+			full := filepath.Join(workspace, "knowledge", filepath.FromSlash(path))
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple", Concurrency: concurrency})
+		if err != nil {
+			t.Fatalf("NewService: %v", err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		chunks, info, err := svc.buildChunksAndInfo(func(BuildEvent) {}, time.Time{}, nil)
+		if err != nil {
+			t.Fatalf("buildChunksAndInfo: %v", err)
+		}
+		return chunks, info
+	}
+
+	serialChunks, serialInfo := build(1)
+	if serialInfo.TotalDocuments != 24 {
+		t.Fatalf("serial build indexed %d documents, want 24", serialInfo.TotalDocuments)
+	}
+	// A couple of rounds to give scheduling nondeterminism a chance to
+	// show up.
+	for round := 0; round < 3; round++ {
+		chunks, info := build(8)
+		if !reflect.DeepEqual(chunks, serialChunks) {
+			t.Fatalf("round %d: concurrent chunk list differs from serial", round)
+		}
+		if !reflect.DeepEqual(info.Warnings, serialInfo.Warnings) {
+			t.Errorf("round %d: warnings = %v, want %v", round, info.Warnings, serialInfo.Warnings)
+		}
+		if info.TotalDocuments != serialInfo.TotalDocuments || info.TotalChunks != serialInfo.TotalChunks {
+			t.Errorf("round %d: counts = %d/%d, want %d/%d", round,
+				info.TotalDocuments, info.TotalChunks, serialInfo.TotalDocuments, serialInfo.TotalChunks)
+		}
+	}
+}
+
+func BenchmarkBuildChunksAndInfo(b *testing.B) {
+	workspace := b.TempDir()
+	for i := 0; i < 50; i++ {
+		body := fmt.Sprintf("# Doc %d\n\n", i) +
+			strings.Repeat("A benchmark paragraph with enough prose to chunk realistically. ", 30)
+		full := filepath.Join(workspace, "knowledge", fmt.Sprintf("doc-%02d.md", i))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(body), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple"})
+	if err != nil {
+		b.Fatalf("NewService: %v", err)
+	}
+	defer svc.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := svc.buildChunksAndInfo(func(BuildEvent) {}, time.Time{}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestBuildDeterministicOrdering pins the cross-build reproducibility
+// contract eval depends on: two builds of the same corpus yield the same
+// chunking hash, the same chunk (and thus index ID) order, and the same
+// hit ordering — including for paths where lexical walk order and sorted
+// path order disagree.
+func TestBuildDeterministicOrdering(t *testing.T) {
+	files := map[string]string{
+		// "a.c" walks after directory "a" but sorts before "a/".
+		"a.c/x.md": "# AC\n\nShared topic paragraph about rollout ordering.\n",
+		"a/x.md":   "# A\n\nShared topic paragraph about rollout ordering too.\n",
+		"b.md":     "# B\n\nShared topic paragraph about rollout ordering as well.\n",
+	}
+
+	build := func() (*Service, []IndexedChunk, IndexInfo) {
+		t.Helper()
+		workspace := t.TempDir()
+		for path, content := range files {
+			full := filepath.Join(workspace, "knowledge", filepath.FromSlash(path))
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple"})
+		if err != nil {
+			t.Fatalf("NewService: %v", err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		chunks, info, err := svc.buildChunksAndInfo(func(BuildEvent) {}, time.Time{}, nil)
+		if err != nil {
+			t.Fatalf("buildChunksAndInfo: %v", err)
+		}
+		if _, err := svc.BuildIndex(context.Background()); err != nil {
+			t.Fatalf("BuildIndex: %v", err)
+		}
+		return svc, chunks, info
+	}
+
+	svc1, chunks1, info1 := build()
+	svc2, chunks2, info2 := build()
+
+	var order []string
+	for _, c := range chunks1 {
+		order = append(order, c.SourcePath)
+	}
+	if !sort.StringsAreSorted(order) {
+		t.Errorf("chunk list not in sorted source-path order: %v", order)
+	}
+	if !reflect.DeepEqual(chunks1, chunks2) {
+		t.Error("two builds of the same corpus produced different chunk lists")
+	}
+	if info1.ChunkingHash != info2.ChunkingHash {
+		t.Errorf("chunking hashes differ: %s vs %s", info1.ChunkingHash, info2.ChunkingHash)
+	}
+
+	req := SearchRequest{Query: "rollout ordering"}
+	r1, err := svc1.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := svc2.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalRanking(rankingOf(r1), rankingOf(r2)) {
+		t.Errorf("hit orderings differ: %v vs %v", rankingOf(r1), rankingOf(r2))
+	}
+	if r1.IndexVersion != r2.IndexVersion {
+		t.Errorf("index versions differ: %s vs %s", r1.IndexVersion, r2.IndexVersion)
+	}
+}
+
+func TestClassifyWarnings(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Warning
+	}{
+		{"walk_error:/kb/broken", Warning{Code: "walk_error", Path: "/kb/broken", Severity: SeverityWarn}},
+		{"security_path_blocked:evil.md", Warning{Code: "security_path_blocked", Path: "evil.md", Severity: SeveritySecurity}},
+		{"doc_hard_limit:huge.md", Warning{Code: "doc_hard_limit", Path: "huge.md", Severity: SeverityWarn}},
+		{"read_error:gone.md", Warning{Code: "read_error", Path: "gone.md", Severity: SeverityWarn}},
+		{"frontmatter_parse_error:bad.md", Warning{Code: "frontmatter_parse_error", Path: "bad.md", Severity: SeverityWarn}},
+		// Counted codes drop the trailing count from the path.
+		{"boilerplate_stripped:notes/a.md:3", Warning{Code: "boilerplate_stripped", Path: "notes/a.md", Severity: SeverityInfo}},
+		{"dedupe_skipped:b.md:2", Warning{Code: "dedupe_skipped", Path: "b.md", Severity: SeverityInfo}},
+		// Codes from a newer build than this binary default to warn.
+		{"mystery_code:c.md", Warning{Code: "mystery_code", Path: "c.md", Severity: SeverityWarn}},
+	}
+	for _, tc := range cases {
+		if got := ClassifyWarning(tc.in); got != tc.want {
+			t.Errorf("ClassifyWarning(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+
+	if got := ClassifyWarnings(nil); got != nil {
+		t.Errorf("ClassifyWarnings(nil) = %v, want nil", got)
+	}
+}
+
+func TestBuildPopulatesStructuredWarnings(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"good.md": doc("Good", "2026-01-05", "The aardvark budget was approved."),
+		"bad.md":  "---\ntitle: [broken\n---\n\nBody under a malformed header.\n",
+	})
+	info, err := svc.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.StructuredWarnings, ClassifyWarnings(info.Warnings)) {
+		t.Errorf("structured warnings %+v do not match flat list %v", info.StructuredWarnings, info.Warnings)
+	}
+	found := false
+	for _, w := range info.StructuredWarnings {
+		if w.Code == "frontmatter_parse_error" && w.Path == "bad.md" && w.Severity == SeverityWarn {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no structured frontmatter warning for bad.md: %+v", info.StructuredWarnings)
+	}
+}
+
+// TestBuildIndexSince pins the reuse rules of a time-bounded rebuild:
+// old unchanged documents keep their stored chunks without re-chunking,
+// newer files always re-chunk, and an old mtime never hides a content
+// change — the version comparison catches it.
+func TestBuildIndexSince(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("Alpha", "2026-01-01", "The aardvark budget was approved."),
+		"b.md": doc("Beta", "2026-01-02", "The beta rollout starts on Monday."),
+	})
+	ctx := context.Background()
+	root := svc.kbRoots[0].path
+	past := time.Now().Add(-48 * time.Hour)
+	cutoff := time.Now().Add(-time.Hour)
+
+	// Age a.md past the cutoff and plant a sentinel in its stored chunk:
+	// if the incremental build reuses the chunk the sentinel survives, if
+	// it re-chunks the file the sentinel is lost.
+	if err := os.Chtimes(filepath.Join(root, "a.md"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	sp := svc.provider.(*simpleProvider)
+	for i := range sp.store.Chunks {
+		if sp.store.Chunks[i].SourcePath == "a.md" {
+			sp.store.Chunks[i].Text = "sentinel: reused from the stored index"
+		}
+	}
+	// b.md changes now, so its mtime is after the cutoff.
+	if err := os.WriteFile(filepath.Join(root, "b.md"),
+		[]byte(doc("Beta", "2026-01-02", "The refreshed beta rollout slipped to Tuesday.")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := svc.BuildIndexSince(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("BuildIndexSince: %v", err)
+	}
+	if info.TotalDocuments != 2 {
+		t.Fatalf("incremental build covers %d documents, want the whole KB (2)", info.TotalDocuments)
+	}
+	text := func(source string) string {
+		t.Helper()
+		for _, c := range sp.store.Chunks {
+			if c.SourcePath == source {
+				return c.Text
+			}
+		}
+		t.Fatalf("no chunk for %s after incremental build", source)
+		return ""
+	}
+	if got := text("a.md"); !strings.Contains(got, "sentinel") {
+		t.Errorf("old unchanged a.md was re-chunked: %q", got)
+	}
+	if got := text("b.md"); !strings.Contains(got, "refreshed") {
+		t.Errorf("modified b.md was not re-chunked: %q", got)
+	}
+
+	// A content change behind a backdated mtime must still re-chunk: the
+	// stored version no longer matches.
+	if err := os.WriteFile(filepath.Join(root, "a.md"),
+		[]byte(doc("Alpha", "2026-01-01", "The amended aardvark budget doubled.")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "a.md"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.BuildIndexSince(ctx, cutoff); err != nil {
+		t.Fatalf("BuildIndexSince: %v", err)
+	}
+	if got := text("a.md"); !strings.Contains(got, "amended") {
+		t.Errorf("stale mtime hid a content change: %q", got)
+	}
+}
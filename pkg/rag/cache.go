@@ -0,0 +1,91 @@
+package rag
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultCache is a TTL'd LRU over complete search results, shared by
+// every entry point of one Service. Keys include the index version, so a
+// rebuild naturally invalidates all cached entries without bookkeeping.
+type resultCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List // front = most recently used
+	byKey map[string]*list.Element
+}
+
+type resultCacheEntry struct {
+	key      string
+	result   *SearchResult
+	storedAt time.Time
+}
+
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	return &resultCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		byKey: make(map[string]*list.Element, size),
+	}
+}
+
+// resultCacheKey derives the cache key from the normalized request and
+// the current index version. The query is token-normalized so trivial
+// whitespace/case variants share an entry.
+func resultCacheKey(req SearchRequest, indexVersion string) string {
+	req.Query = strings.Join(tokenize(req.Query), " ")
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	return indexVersion + "\x00" + string(data)
+}
+
+// get returns the cached result for key, refreshing its recency. Expired
+// entries are dropped and reported as misses.
+func (c *resultCache) get(key string) (*SearchResult, bool) {
+	if key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*resultCacheEntry)
+	if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+		c.ll.Remove(el)
+		delete(c.byKey, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.result, true
+}
+
+// put stores a result under key, evicting the least recently used entry
+// when the cache is full.
+func (c *resultCache) put(key string, result *SearchResult) {
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.byKey[key]; ok {
+		el.Value.(*resultCacheEntry).result = result
+		el.Value.(*resultCacheEntry).storedAt = time.Now()
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.byKey[key] = c.ll.PushFront(&resultCacheEntry{key: key, result: result, storedAt: time.Now()})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.byKey, last.Value.(*resultCacheEntry).key)
+	}
+}
@@ -0,0 +1,105 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestResultCacheLRU(t *testing.T) {
+	c := newResultCache(2, 0)
+	a, b, d := &SearchResult{Query: "a"}, &SearchResult{Query: "b"}, &SearchResult{Query: "d"}
+
+	c.put("a", a)
+	c.put("b", b)
+	if got, ok := c.get("a"); !ok || got != a {
+		t.Fatal("expected hit for a")
+	}
+	// b is now least recently used and must be evicted by d.
+	c.put("d", d)
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("a should have survived eviction")
+	}
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	c := newResultCache(4, 10*time.Millisecond)
+	c.put("k", &SearchResult{})
+	if _, ok := c.get("k"); !ok {
+		t.Fatal("expected hit before expiry")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.get("k"); ok {
+		t.Error("expected miss after TTL expiry")
+	}
+}
+
+func TestSearchResultCache(t *testing.T) {
+	workspace := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		full := filepath.Join(workspace, "knowledge", path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("deploy.md", doc("Deploy", "2026-01-01", "deployment checklist and rollback"))
+
+	svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple"},
+		WithResultCache(8, time.Minute))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	first, err := svc.Search(context.Background(), SearchRequest{Query: "deployment checklist"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	second, err := svc.Search(context.Background(), SearchRequest{Query: "deployment checklist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("expected the second identical search to be served from cache")
+	}
+
+	// Whitespace/case variants normalize to the same entry.
+	variant, err := svc.Search(context.Background(), SearchRequest{Query: "  Deployment   CHECKLIST "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variant != first {
+		t.Error("normalized query variant should hit the same cache entry")
+	}
+
+	// A rebuild changes the index version, invalidating every entry.
+	write("extra.md", doc("Extra", "2026-01-02", "another deployment checklist document"))
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := svc.Search(context.Background(), SearchRequest{Query: "deployment checklist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == first {
+		t.Error("cache should not survive an index rebuild")
+	}
+	if len(rebuilt.Items) <= len(first.Items) {
+		t.Errorf("post-rebuild search should see the new document: %d vs %d items",
+			len(rebuilt.Items), len(first.Items))
+	}
+}
@@ -0,0 +1,249 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// defaultChunkSoftLimit is the target chunk size in runes; paragraphs
+	// are merged up to this size.
+	defaultChunkSoftLimit = 1200
+	// defaultChunkHardLimit is the absolute maximum chunk size in runes;
+	// oversized paragraphs are split at this boundary.
+	defaultChunkHardLimit = 2000
+)
+
+// chunkerConfig carries the chunk sizing knobs, resolved from config with
+// the package defaults applied.
+type chunkerConfig struct {
+	SoftLimit int
+	HardLimit int
+	// AttachHeadings carries heading lines into the following content
+	// chunk instead of emitting heading-only chunks, so sparse documents
+	// with many headings do not produce spurious heading-word matches.
+	AttachHeadings bool
+}
+
+func (c chunkerConfig) withDefaults() chunkerConfig {
+	if c.SoftLimit <= 0 {
+		c.SoftLimit = defaultChunkSoftLimit
+	}
+	if c.HardLimit <= 0 {
+		c.HardLimit = defaultChunkHardLimit
+	}
+	if c.HardLimit < c.SoftLimit {
+		c.HardLimit = c.SoftLimit
+	}
+	return c
+}
+
+// RawChunk is a chunk before metadata/security annotation: the text, the
+// heading path leading to it and its line range within the document body.
+type RawChunk struct {
+	Text        string
+	HeadingPath []string
+	StartLine   int
+	EndLine     int
+}
+
+// Chunker splits a markdown body (frontmatter already removed) into raw
+// chunks. Implementations that call out to an embedder take a context
+// and may fail; the plain markdown splitter never does.
+type Chunker interface {
+	Chunk(ctx context.Context, body string) ([]RawChunk, error)
+}
+
+// MarkdownChunker is the default heading/paragraph splitter behind the
+// Chunker interface, for callers that select chunkers dynamically.
+type MarkdownChunker struct {
+	cfg chunkerConfig
+}
+
+// NewMarkdownChunker constructs the default splitter; zero limits take
+// the package defaults.
+func NewMarkdownChunker(softLimit, hardLimit int, attachHeadings bool) *MarkdownChunker {
+	return &MarkdownChunker{cfg: chunkerConfig{SoftLimit: softLimit, HardLimit: hardLimit, AttachHeadings: attachHeadings}}
+}
+
+func (m *MarkdownChunker) Chunk(_ context.Context, body string) ([]RawChunk, error) {
+	return splitMarkdownChunks(body, m.cfg), nil
+}
+
+// splitMarkdownChunks splits a markdown body (frontmatter already removed)
+// into chunks. The splitter tracks the ATX heading path, flushes the
+// current buffer on each heading, accumulates paragraphs up to the soft
+// limit and hard-splits any single paragraph exceeding the hard limit.
+// The output is deterministic for identical input and configuration.
+func splitMarkdownChunks(content string, cfg chunkerConfig) []RawChunk {
+	var chunks []RawChunk
+	lc := newLineChunker(cfg, func(c RawChunk) { chunks = append(chunks, c) })
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		lc.feed(line)
+	}
+	lc.finish()
+	return chunks
+}
+
+// lineChunker is the incremental form of splitMarkdownChunks: callers
+// feed body lines one at a time and completed chunks are delivered
+// through emit, so a large document never has to be resident as one
+// string. splitMarkdownChunks is a thin wrapper over it.
+type lineChunker struct {
+	cfg  chunkerConfig
+	emit func(RawChunk)
+
+	buf      []string
+	bufLen   int // runes in buf including joining newlines
+	bufStart int
+	hasBody  bool // buf contains non-heading content
+	headings []string
+	inFence  bool
+	line     int // index of the next line to feed
+}
+
+func newLineChunker(cfg chunkerConfig, emit func(RawChunk)) *lineChunker {
+	return &lineChunker{cfg: cfg.withDefaults(), emit: emit}
+}
+
+// feed consumes the next body line. Line endings must already be
+// stripped.
+func (lc *lineChunker) feed(line string) {
+	i := lc.line
+	lc.line++
+
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") {
+		lc.inFence = !lc.inFence
+	}
+
+	if !lc.inFence && strings.HasPrefix(trimmed, "#") {
+		if level, title := parseHeading(trimmed); level > 0 {
+			// With AttachHeadings, a buffer holding only heading lines is
+			// carried forward instead of flushed, so consecutive headings
+			// end up in the next content chunk rather than standing alone.
+			carry := lc.cfg.AttachHeadings && !lc.hasBody && len(lc.buf) > 0
+			if !carry {
+				lc.flush(i)
+			}
+			if level <= len(lc.headings) {
+				lc.headings = lc.headings[:level-1]
+			}
+			lc.headings = append(lc.headings, title)
+			lc.append(trimmed)
+			if !carry {
+				lc.bufStart = i + 1
+			}
+			return
+		}
+	}
+
+	if trimmed == "" && !lc.inFence {
+		// Paragraph boundary: flush once the buffer is past the soft limit.
+		if lc.bufLen >= lc.cfg.SoftLimit {
+			lc.flush(i)
+			lc.bufStart = i + 1
+		} else if len(lc.buf) > 0 {
+			lc.append("")
+		}
+		return
+	}
+
+	if len(lc.buf) == 0 {
+		lc.bufStart = i + 1
+	}
+	lc.append(line)
+	if trimmed != "" {
+		lc.hasBody = true
+	}
+}
+
+// finish flushes the trailing buffer; the chunker must not be fed again.
+func (lc *lineChunker) finish() {
+	lc.flush(lc.line)
+}
+
+func (lc *lineChunker) append(line string) {
+	if len(lc.buf) > 0 {
+		lc.bufLen++
+	}
+	lc.buf = append(lc.buf, line)
+	lc.bufLen += runeLen(line)
+}
+
+func (lc *lineChunker) flush(endLine int) {
+	text := strings.TrimSpace(strings.Join(lc.buf, "\n"))
+	hadBody := lc.hasBody
+	lc.buf = lc.buf[:0]
+	lc.bufLen = 0
+	lc.hasBody = false
+	if text == "" {
+		return
+	}
+	if lc.cfg.AttachHeadings && !hadBody {
+		// Heading-only buffer at a flush point (e.g. end of document):
+		// not a useful searchable unit on its own.
+		return
+	}
+	for _, part := range hardSplit(text, lc.cfg.HardLimit) {
+		lc.emit(RawChunk{
+			Text:        part,
+			HeadingPath: append([]string(nil), lc.headings...),
+			StartLine:   lc.bufStart,
+			EndLine:     endLine,
+		})
+	}
+}
+
+// parseHeading returns the ATX heading level (1-6) and title, or 0 if the
+// line is not a heading.
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(line[level:])
+}
+
+// hardSplit splits text at the hard rune limit, preferring line breaks
+// near the boundary so we do not cut mid-sentence when avoidable.
+func hardSplit(text string, hardLimit int) []string {
+	if runeLen(text) <= hardLimit {
+		return []string{text}
+	}
+	var parts []string
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if len(runes) <= hardLimit {
+			parts = append(parts, string(runes))
+			break
+		}
+		cut := hardLimit
+		// Look backwards for a newline to break on.
+		for i := hardLimit - 1; i > hardLimit/2; i-- {
+			if runes[i] == '\n' {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, strings.TrimSpace(string(runes[:cut])))
+		runes = runes[cut:]
+		for len(runes) > 0 && (runes[0] == '\n' || runes[0] == ' ') {
+			runes = runes[1:]
+		}
+	}
+	return parts
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
+
+// chunkLoc formats the chunk's line range for citations.
+func chunkLoc(c RawChunk) string {
+	return fmt.Sprintf("line:%d-%d", c.StartLine, c.EndLine)
+}
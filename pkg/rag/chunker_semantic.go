@@ -0,0 +1,197 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultSemanticThreshold is where a topic break is assumed: adjacent
+// sentences less similar than this start a new chunk.
+const defaultSemanticThreshold = 0.6
+
+// SemanticChunker splits a markdown body on sentence boundaries, embeds
+// each sentence and groups adjacent sentences while their cosine
+// similarity stays above a threshold. Long sections covering several
+// topics under one heading thus break into topically coherent chunks
+// instead of one oversized block. Soft and hard size limits still apply.
+type SemanticChunker struct {
+	cfg       chunkerConfig
+	embedder  Embedder
+	threshold float64
+}
+
+// NewSemanticChunker constructs a semantic chunker; zero limits and a
+// zero threshold take the package defaults.
+func NewSemanticChunker(softLimit, hardLimit int, threshold float64, embedder Embedder) *SemanticChunker {
+	if threshold <= 0 {
+		threshold = defaultSemanticThreshold
+	}
+	return &SemanticChunker{
+		cfg:       chunkerConfig{SoftLimit: softLimit, HardLimit: hardLimit},
+		embedder:  embedder,
+		threshold: threshold,
+	}
+}
+
+// sentence is one unit of the semantic split, carrying the heading path
+// and line range of the paragraph it came from.
+type sentence struct {
+	Text        string
+	HeadingPath []string
+	StartLine   int
+	EndLine     int
+}
+
+// Chunk splits, embeds and regroups the body. Heading boundaries always
+// break a chunk: grouping only ever merges sentences within a section.
+func (sc *SemanticChunker) Chunk(ctx context.Context, body string) ([]RawChunk, error) {
+	if sc.embedder == nil {
+		return nil, fmt.Errorf("semantic chunker requires an embedder")
+	}
+	cfg := sc.cfg.withDefaults()
+
+	sentences := splitSentences(body)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(sentences))
+	for i, s := range sentences {
+		texts[i] = s.Text
+	}
+	vectors, err := sc.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding sentences: %w", err)
+	}
+	if len(vectors) != len(sentences) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d sentences", len(vectors), len(sentences))
+	}
+
+	var chunks []RawChunk
+	flush := func(group []sentence) {
+		if len(group) == 0 {
+			return
+		}
+		parts := make([]string, len(group))
+		for i, s := range group {
+			parts[i] = s.Text
+		}
+		for _, part := range hardSplit(strings.Join(parts, " "), cfg.HardLimit) {
+			chunks = append(chunks, RawChunk{
+				Text:        part,
+				HeadingPath: group[0].HeadingPath,
+				StartLine:   group[0].StartLine,
+				EndLine:     group[len(group)-1].EndLine,
+			})
+		}
+	}
+
+	group := []sentence{sentences[0]}
+	groupLen := runeLen(sentences[0].Text)
+	for i := 1; i < len(sentences); i++ {
+		s := sentences[i]
+		sameSection := equalHeadings(group[0].HeadingPath, s.HeadingPath)
+		similar := cosineSimilarity(vectors[i-1], vectors[i]) >= sc.threshold
+		fits := groupLen+1+runeLen(s.Text) <= cfg.SoftLimit
+		if sameSection && similar && fits {
+			group = append(group, s)
+			groupLen += 1 + runeLen(s.Text)
+			continue
+		}
+		flush(group)
+		group = []sentence{s}
+		groupLen = runeLen(s.Text)
+	}
+	flush(group)
+	return chunks, nil
+}
+
+// splitSentences walks the body line by line, tracking the ATX heading
+// path like the markdown chunker, and splits paragraph text into
+// sentences. Sentences inherit their paragraph's line range.
+func splitSentences(body string) []sentence {
+	var (
+		out      []sentence
+		headings []string
+		para     []string
+		paraAt   int
+		inFence  bool
+	)
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	flushPara := func(endLine int) {
+		text := strings.TrimSpace(strings.Join(para, " "))
+		para = para[:0]
+		if text == "" {
+			return
+		}
+		hp := append([]string(nil), headings...)
+		for _, st := range splitSentenceText(text) {
+			out = append(out, sentence{Text: st, HeadingPath: hp, StartLine: paraAt, EndLine: endLine})
+		}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			if level, title := parseHeading(trimmed); level > 0 {
+				flushPara(i)
+				if level <= len(headings) {
+					headings = headings[:level-1]
+				}
+				headings = append(headings, title)
+				continue
+			}
+		}
+		if trimmed == "" && !inFence {
+			flushPara(i)
+			continue
+		}
+		if len(para) == 0 {
+			paraAt = i + 1
+		}
+		para = append(para, trimmed)
+	}
+	flushPara(len(lines))
+	return out
+}
+
+// splitSentenceText cuts a paragraph after sentence-final punctuation
+// followed by whitespace. It is deliberately naive: embeddings, not the
+// splitter, decide where chunks break.
+func splitSentenceText(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '.' && runes[i] != '!' && runes[i] != '?' {
+			continue
+		}
+		if i+1 < len(runes) && runes[i+1] != ' ' && runes[i+1] != '\n' {
+			continue
+		}
+		if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = i + 1
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// equalHeadings compares two heading paths.
+func equalHeadings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// topicEmbedder maps sentences onto fixed topic axes by keyword so tests
+// control exactly where similarity drops.
+type topicEmbedder struct{}
+
+func (topicEmbedder) vector(text string) []float32 {
+	switch {
+	case strings.Contains(strings.ToLower(text), "cat"):
+		return []float32{1, 0}
+	case strings.Contains(strings.ToLower(text), "dog"):
+		return []float32{0, 1}
+	default:
+		return []float32{0.7, 0.7}
+	}
+}
+
+func (e topicEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = e.vector(t)
+	}
+	return out, nil
+}
+
+func (e topicEmbedder) EmbedQuery(_ context.Context, text string) ([]float32, error) {
+	return e.vector(text), nil
+}
+
+func (topicEmbedder) ModelID() string { return "test/topic" }
+func (topicEmbedder) Dims() int       { return 2 }
+
+func TestSemanticChunkerGroupsByTopic(t *testing.T) {
+	body := "Cats purr loudly. Cats nap all day. Dogs bark at night. Dogs chase balls.\n"
+	sc := NewSemanticChunker(0, 0, 0.5, topicEmbedder{})
+	chunks, err := sc.Chunk(context.Background(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %+v", len(chunks), chunks)
+	}
+	if !strings.Contains(chunks[0].Text, "purr") || strings.Contains(chunks[0].Text, "bark") {
+		t.Errorf("first chunk mixes topics: %q", chunks[0].Text)
+	}
+	if !strings.Contains(chunks[1].Text, "bark") {
+		t.Errorf("second chunk = %q", chunks[1].Text)
+	}
+}
+
+func TestSemanticChunkerHeadingBoundary(t *testing.T) {
+	// Same topic on both sides of a heading must still split.
+	body := "# One\n\nCats purr.\n\n# Two\n\nCats nap.\n"
+	sc := NewSemanticChunker(0, 0, 0.5, topicEmbedder{})
+	chunks, err := sc.Chunk(context.Background(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %+v", len(chunks), chunks)
+	}
+	if len(chunks[1].HeadingPath) != 1 || chunks[1].HeadingPath[0] != "Two" {
+		t.Errorf("second chunk heading path = %v", chunks[1].HeadingPath)
+	}
+}
+
+func TestSemanticChunkerSoftLimit(t *testing.T) {
+	// One topic, but more text than the soft limit allows per chunk.
+	body := strings.Repeat("Cats purr and cats nap and cats stretch. ", 10)
+	sc := NewSemanticChunker(80, 100, 0.5, topicEmbedder{})
+	chunks, err := sc.Chunk(context.Background(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("soft limit ignored: %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if runeLen(c.Text) > 100 {
+			t.Errorf("chunk exceeds hard limit: %d runes", runeLen(c.Text))
+		}
+	}
+}
+
+func TestSemanticChunkerNeedsEmbedder(t *testing.T) {
+	sc := NewSemanticChunker(0, 0, 0, nil)
+	if _, err := sc.Chunk(context.Background(), "Anything."); err == nil {
+		t.Error("expected an error without an embedder")
+	}
+}
+
+func TestSplitSentenceText(t *testing.T) {
+	got := splitSentenceText("First one. Second, with v1.2 inside! Third?")
+	want := []string{"First one.", "Second, with v1.2 inside!", "Third?"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sentence %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitMarkdownChunksHeadingPath(t *testing.T) {
+	content := "# Title\n\nIntro paragraph.\n\n## Section A\n\nContent under A.\n\n## Section B\n\nContent under B.\n"
+	chunks := splitMarkdownChunks(content, chunkerConfig{})
+
+	if len(chunks) < 3 {
+		t.Fatalf("expected at least 3 chunks, got %d", len(chunks))
+	}
+
+	var foundA bool
+	for _, c := range chunks {
+		if strings.Contains(c.Text, "Content under A") {
+			foundA = true
+			if len(c.HeadingPath) != 2 || c.HeadingPath[1] != "Section A" {
+				t.Errorf("chunk under Section A has heading path %v", c.HeadingPath)
+			}
+		}
+	}
+	if !foundA {
+		t.Error("no chunk contains Section A content")
+	}
+}
+
+func TestSplitMarkdownChunksHardLimit(t *testing.T) {
+	long := strings.Repeat("word ", 1000) // ~5000 runes, no newlines
+	chunks := splitMarkdownChunks(long, chunkerConfig{SoftLimit: 100, HardLimit: 200})
+	if len(chunks) < 2 {
+		t.Fatalf("expected oversized paragraph to be split, got %d chunks", len(chunks))
+	}
+	for i, c := range chunks {
+		if runeLen(c.Text) > 200 {
+			t.Errorf("chunk %d has %d runes, exceeds hard limit", i, runeLen(c.Text))
+		}
+	}
+}
+
+func TestSplitMarkdownChunksDeterministic(t *testing.T) {
+	content := "# H\n\nOne.\n\nTwo.\n\n## S\n\nThree.\n"
+	a := splitMarkdownChunks(content, chunkerConfig{})
+	b := splitMarkdownChunks(content, chunkerConfig{})
+	if len(a) != len(b) {
+		t.Fatalf("chunk counts differ: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text {
+			t.Errorf("chunk %d text differs between runs", i)
+		}
+	}
+}
+
+func TestSplitMarkdownChunksAttachHeadings(t *testing.T) {
+	content := "# Title\n\n## Empty Section\n\n### Deeper\n\nActual content here.\n\n# Trailing Heading\n"
+
+	// Default behavior: consecutive headings produce heading-only chunks.
+	plain := splitMarkdownChunks(content, chunkerConfig{})
+	var headingOnly bool
+	for _, c := range plain {
+		if c.Text == "# Title" {
+			headingOnly = true
+		}
+	}
+	if !headingOnly {
+		t.Fatal("fixture no longer produces a heading-only chunk by default")
+	}
+
+	attached := splitMarkdownChunks(content, chunkerConfig{AttachHeadings: true})
+	if len(attached) != 1 {
+		t.Fatalf("expected 1 chunk with attached headings, got %d: %+v", len(attached), attached)
+	}
+	c := attached[0]
+	if !strings.Contains(c.Text, "# Title") || !strings.Contains(c.Text, "Actual content here.") {
+		t.Errorf("headings not carried into content chunk: %q", c.Text)
+	}
+	want := []string{"Title", "Empty Section", "Deeper"}
+	if len(c.HeadingPath) != len(want) {
+		t.Fatalf("heading path = %v, want %v", c.HeadingPath, want)
+	}
+	for i := range want {
+		if c.HeadingPath[i] != want[i] {
+			t.Errorf("heading path = %v, want %v", c.HeadingPath, want)
+			break
+		}
+	}
+}
+
+func TestParseFrontmatter(t *testing.T) {
+	content := "---\ntitle: Test Doc\ndate: 2026-01-15\ndoc_type: note\ntags: [infra, deploy]\n---\n\nBody text.\n"
+	meta, body, err := parseFrontmatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Title != "Test Doc" {
+		t.Errorf("title = %q", meta.Title)
+	}
+	if meta.Date != "2026-01-15" {
+		t.Errorf("date = %q", meta.Date)
+	}
+	if meta.DocType != "note" {
+		t.Errorf("doc_type = %q", meta.DocType)
+	}
+	if len(meta.Tags) != 2 || meta.Tags[0] != "infra" {
+		t.Errorf("tags = %v", meta.Tags)
+	}
+	if !strings.HasPrefix(body, "Body text.") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestParseFrontmatterAbsent(t *testing.T) {
+	content := "Just a body, no frontmatter.\n"
+	meta, body, err := parseFrontmatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "" || body != content {
+		t.Errorf("content without frontmatter should pass through unchanged")
+	}
+}
+
+func TestParseDateGranular(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		gran dateGranularity
+		ok   bool
+	}{
+		{"2026-02-14", "2026-02-14", granularityDay, true},
+		{"2026-02", "2026-02-01", granularityMonth, true},
+		{"2026", "2026-01-01", granularityYear, true},
+		{" 2026 ", "2026-01-01", granularityYear, true},
+		{"Feb 2026", "", granularityDay, false},
+		{"", "", granularityDay, false},
+	}
+	for _, c := range cases {
+		got, gran, ok := parseDateGranular(c.in)
+		if ok != c.ok || gran != c.gran {
+			t.Errorf("parseDateGranular(%q) = gran %d, ok %v; want gran %d, ok %v", c.in, gran, ok, c.gran, c.ok)
+			continue
+		}
+		if ok && got.Format("2006-01-02") != c.want {
+			t.Errorf("parseDateGranular(%q) = %s, want %s", c.in, got.Format("2006-01-02"), c.want)
+		}
+	}
+}
+
+func TestFreshnessPartialDates(t *testing.T) {
+	ref := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	yearOnly := freshnessNorm("2026", ref, 30)
+	if yearOnly <= 0 {
+		t.Fatalf("year-only date scored %v, want > 0", yearOnly)
+	}
+	monthOnly := freshnessNorm("2026-02", ref, 30)
+	if monthOnly <= 0 {
+		t.Fatalf("year-month date scored %v, want > 0", monthOnly)
+	}
+
+	// A year-only date ages from Dec 31, which is still in the future at
+	// the reference time, so it scores as brand new.
+	if yearOnly != 1 {
+		t.Errorf("year-only freshness = %v, want 1 (end of period not yet reached)", yearOnly)
+	}
+	// 2026-02 ages from Feb 28: one day before the reference.
+	exact := freshnessNorm("2026-02-28", ref, 30)
+	if monthOnly != exact {
+		t.Errorf("year-month freshness = %v, want the end-of-month value %v", monthOnly, exact)
+	}
+	if garbage := freshnessNorm("sometime", ref, 30); garbage != 0 {
+		t.Errorf("unparseable date scored %v, want 0", garbage)
+	}
+}
+
+func TestParseFrontmatterYAML(t *testing.T) {
+	content := "---\n" +
+		"title: \"Quoted: Title\"\n" +
+		"tags:\n  - Infra\n  - Deploy\n" +
+		"review:\n  status: Approved\n  by: alice\n" +
+		"summary: >\n  wraps across\n  two lines\n" +
+		"severity: 3\n" +
+		"---\n\nBody.\n"
+	meta, body, err := parseFrontmatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Quoted: Title" {
+		t.Errorf("title = %q", meta.Title)
+	}
+	if len(meta.Tags) != 2 || meta.Tags[0] != "infra" || meta.Tags[1] != "deploy" {
+		t.Errorf("tags = %v", meta.Tags)
+	}
+	if meta.Extra["review.status"] != "Approved" || meta.Extra["review.by"] != "alice" {
+		t.Errorf("nested keys not flattened: %v", meta.Extra)
+	}
+	if !strings.Contains(meta.Extra["summary"], "wraps across two lines") {
+		t.Errorf("multiline string lost: %q", meta.Extra["summary"])
+	}
+	if meta.Extra["severity"] != "3" {
+		t.Errorf("scalar not stringified: %q", meta.Extra["severity"])
+	}
+	if !strings.HasPrefix(body, "Body.") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestParseFrontmatterMalformed(t *testing.T) {
+	content := "---\ntitle: [unclosed\n---\n\nBody.\n"
+	_, body, err := parseFrontmatter(content)
+	if err == nil {
+		t.Fatal("expected a YAML error")
+	}
+	if !strings.HasPrefix(body, "Body.") {
+		t.Errorf("body should still be extracted, got %q", body)
+	}
+}
@@ -0,0 +1,670 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// embedBatchSize is how many chunk texts are sent per embedding request
+// during a build.
+const embedBatchSize = 64
+
+// cometProvider is the hybrid index provider: BM25 over an in-memory
+// inverted index plus cosine similarity over stored vectors, both backed
+// by the bbolt Store. It is the default choice for real deployments.
+type cometProvider struct {
+	store    *Store
+	embedder Embedder
+	// pcaDims, when positive, reduces stored vectors to this many
+	// dimensions via a PCA projection fitted at build time.
+	pcaDims int
+	// bm25K1/bm25B are the configured BM25 tuning parameters; zero means
+	// the standard defaults.
+	bm25K1 float64
+	bm25B  float64
+	// tokenizer feeds the BM25 index so lexical scoring tokenizes exactly
+	// like the simple provider and the service's query guard.
+	tokenizer func(string) []string
+	// log receives structured events; replaced via setLogger when the
+	// owning service has an injected RAGLogger.
+	log RAGLogger
+
+	mu      sync.RWMutex
+	loaded  bool
+	bm25    *BM25SearchIndex
+	chunks  []IndexedChunk // id-ordered, mirrors the store
+	vectors [][]float32    // id-ordered; nil when built without embeddings
+	vecDims int
+	// proj is the fitted PCA projection, nil for full-dimensional indexes.
+	// Query vectors must pass through the same transform as the stored
+	// ones.
+	proj *pcaProjection
+}
+
+func newCometProvider(stateDir string, cfg config.RAGToolsConfig, embedder Embedder) (*cometProvider, error) {
+	store, err := OpenStore(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return &cometProvider{
+		store:     store,
+		embedder:  embedder,
+		pcaDims:   cfg.PCADims,
+		bm25K1:    cfg.BM25K1,
+		bm25B:     cfg.BM25B,
+		tokenizer: tokenizerFor(cfg.CJKBigrams),
+		log:       stdRAGLogger{},
+	}, nil
+}
+
+func (p *cometProvider) setLogger(l RAGLogger) {
+	p.log = l
+}
+
+func (p *cometProvider) Name() string {
+	return "comet"
+}
+
+func (p *cometProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Lexical: true, Semantic: p.embedder != nil}
+}
+
+// Build embeds (when an embedder is configured) and persists the chunks,
+// then rebuilds the in-memory indexes.
+func (p *cometProvider) Build(ctx context.Context, chunks []IndexedChunk, info IndexInfo) error {
+	return p.BuildWithProgress(ctx, chunks, info, nil)
+}
+
+// BuildWithProgress is Build with per-phase progress reporting for the
+// embedding batches and the final flush.
+func (p *cometProvider) BuildWithProgress(ctx context.Context, chunks []IndexedChunk, info IndexInfo, progress func(BuildEvent)) error {
+	if progress == nil {
+		progress = func(BuildEvent) {}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	vectors, err := p.embedAndBuild(ctx, chunks, progress)
+	if err != nil {
+		return err
+	}
+
+	// Optional PCA reduction: fit on the fresh corpus embeddings and
+	// store only the reduced vectors plus the projection itself.
+	var proj *pcaProjection
+	if p.pcaDims > 0 && vectors != nil {
+		if proj = fitPCA(vectors, p.pcaDims); proj != nil {
+			vectors = applyPCA(proj, vectors)
+			p.log.Info("Reduced embedding dimensionality",
+				map[string]any{"dims": proj.dims(), "original": len(proj.Mean)})
+		}
+	}
+	if vectors != nil {
+		info.VectorDims = len(vectors[0])
+	}
+
+	progress(BuildEvent{Phase: PhaseFlushing, Chunks: len(chunks)})
+	if err := p.store.SaveIndex(chunks, info); err != nil {
+		return err
+	}
+	if err := p.store.SaveVectors(p.modelID(), vectors); err != nil {
+		return err
+	}
+	if err := p.store.SaveProjection(p.modelID(), proj); err != nil {
+		return err
+	}
+	if err := p.store.Flush(); err != nil {
+		return err
+	}
+
+	p.chunks = chunks
+	p.vectors = vectors
+	p.proj = proj
+	if vectors != nil {
+		p.vecDims = len(vectors[0])
+	} else {
+		p.vecDims = 0
+	}
+	p.rebuildBM25()
+	p.loaded = true
+	return nil
+}
+
+// embedAndBuild embeds all chunk texts in batches, bounded by the input
+// cap and the provider's token budget when it advertises one. It returns
+// nil vectors when no embedder is configured.
+func (p *cometProvider) embedAndBuild(ctx context.Context, chunks []IndexedChunk, progress func(BuildEvent)) ([][]float32, error) {
+	if p.embedder == nil || len(chunks) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	var maxTokens int
+	if l, ok := p.embedder.(interface{ MaxBatchTokens() int }); ok {
+		maxTokens = l.MaxBatchTokens()
+	}
+	vectors := make([][]float32, 0, len(chunks))
+	for _, b := range splitEmbedBatches(texts, embedBatchSize, maxTokens) {
+		start, end := b[0], b[1]
+		batch, err := p.embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("embedding batch %d-%d: %w", start, end, err)
+		}
+		vectors = append(vectors, batch...)
+		progress(BuildEvent{Phase: PhaseEmbedding, Completed: end, Total: len(chunks)})
+		p.log.Debug("Embedded chunk batch",
+			map[string]any{"from": start, "to": end, "total": len(chunks)})
+	}
+	return vectors, nil
+}
+
+// modelID names the active embedding model for per-model vector files;
+// empty when no embedder is configured.
+func (p *cometProvider) modelID() string {
+	if p.embedder == nil {
+		return ""
+	}
+	return p.embedder.ModelID()
+}
+
+// rebuildBM25 reconstructs the inverted index from p.chunks. Callers must
+// hold the write lock.
+func (p *cometProvider) rebuildBM25() {
+	p.bm25 = NewBM25SearchIndexParams(p.bm25K1, p.bm25B)
+	p.bm25.SetTokenizer(p.tokenizer)
+	for i := range p.chunks {
+		p.bm25.Add(i, p.chunks[i].Text)
+	}
+}
+
+// ensureLoaded loads chunks and vectors from the store on first use and
+// verifies the vector file matches the active embedder.
+func (p *cometProvider) ensureLoaded() error {
+	p.mu.RLock()
+	loaded := p.loaded
+	p.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded {
+		return nil
+	}
+
+	if err := p.store.Verify(p.modelID()); err != nil && !errors.Is(err, ErrNoIndex) {
+		return err
+	}
+	chunks, err := p.store.LoadChunks()
+	if err != nil {
+		return err
+	}
+	vectors, dims, err := p.store.LoadVectors(p.modelID())
+	if err != nil {
+		return err
+	}
+	proj, err := p.store.LoadProjection(p.modelID())
+	if err != nil {
+		return err
+	}
+	if vectors != nil {
+		if len(vectors) != len(chunks) {
+			return fmt.Errorf("vector count %d does not match chunk count %d (rebuild required)",
+				len(vectors), len(chunks))
+		}
+		// With a projection, stored vectors live in the reduced space and
+		// must match the projection's output; the embedder's native dims
+		// must match the projection's input instead.
+		switch {
+		case proj != nil:
+			if dims != proj.dims() {
+				return fmt.Errorf("stored vectors have %d dims but projection produces %d (rebuild required)",
+					dims, proj.dims())
+			}
+			if p.embedder != nil && len(proj.Mean) != p.embedder.Dims() {
+				return fmt.Errorf("projection expects %d-dim input but embedder %s produces %d (rebuild required)",
+					len(proj.Mean), p.embedder.ModelID(), p.embedder.Dims())
+			}
+		case p.embedder != nil && dims != p.embedder.Dims():
+			return fmt.Errorf("stored vectors have %d dims but embedder %s produces %d (rebuild required)",
+				dims, p.embedder.ModelID(), p.embedder.Dims())
+		}
+	}
+
+	p.chunks = chunks
+	p.vectors = vectors
+	p.vecDims = dims
+	p.proj = proj
+	p.rebuildBM25()
+	p.loaded = true
+	return nil
+}
+
+func (p *cometProvider) Search(ctx context.Context, query string, opts ProviderSearchOptions) ([]Candidate, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.searchHybrid(ctx, query, opts)
+}
+
+// mergedHit accumulates the per-signal scores for one chunk id while the
+// lexical and semantic result lists are merged.
+type mergedHit struct {
+	bm25      float64
+	cosine    float64
+	hasCosine bool
+}
+
+// searchHybrid runs the configured signals and merges their hits by chunk
+// id. Callers must hold at least the read lock.
+func (p *cometProvider) searchHybrid(ctx context.Context, query string, opts ProviderSearchOptions) ([]Candidate, error) {
+	topN := opts.TopN
+	if topN <= 0 {
+		topN = maxProviderCandidates
+	}
+
+	merged := make(map[int]*mergedHit)
+
+	if opts.Mode != "semantic" {
+		for _, h := range p.bm25.Search(query, topN) {
+			merged[h.id] = &mergedHit{bm25: h.score}
+		}
+	}
+
+	if opts.Mode != "keyword" && p.vectors != nil && (p.embedder != nil || opts.QueryVector != nil) {
+		qv := opts.QueryVector
+		if qv == nil {
+			embedded, err := p.embedder.EmbedQuery(ctx, query)
+			if err != nil {
+				// Degrade to keyword-only rather than failing the search; the
+				// service notes the degradation from the missing cosine signal.
+				p.log.Warn("Query embedding failed; falling back to keyword-only",
+					map[string]any{"error": err.Error()})
+			} else {
+				qv = embedded
+				if p.proj != nil {
+					qv = p.proj.apply(qv)
+				}
+			}
+		}
+		if qv != nil {
+			type vecHit struct {
+				id  int
+				cos float64
+			}
+			hits := make([]vecHit, 0, len(p.vectors))
+			for id, v := range p.vectors {
+				if cos := cosineSimilarity(qv, v); cos > 0 {
+					hits = append(hits, vecHit{id: id, cos: cos})
+				}
+			}
+			sort.Slice(hits, func(i, j int) bool {
+				if hits[i].cos != hits[j].cos {
+					return hits[i].cos > hits[j].cos
+				}
+				return hits[i].id < hits[j].id
+			})
+			if len(hits) > topN {
+				hits = hits[:topN]
+			}
+			for _, h := range hits {
+				m := merged[h.id]
+				if m == nil {
+					m = &mergedHit{}
+					merged[h.id] = m
+				}
+				m.cosine = h.cos
+				m.hasCosine = true
+			}
+		}
+	}
+
+	return p.resolveHits(merged, topN)
+}
+
+// resolveHits materializes merged hits into candidates in a deterministic
+// order: combined raw score descending, ties by ascending chunk id. Map
+// iteration order must never leak into the hit list — the service
+// re-sorts by final score, but tie ordering follows its input.
+func (p *cometProvider) resolveHits(merged map[int]*mergedHit, topN int) ([]Candidate, error) {
+	ids := make([]int, 0, len(merged))
+	for id := range merged {
+		if id < 0 || id >= len(p.chunks) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	hits := make([]Candidate, 0, len(ids))
+	for _, id := range ids {
+		m := merged[id]
+		c := Candidate{
+			Chunk:     p.chunks[id],
+			RawBM25:   m.bm25,
+			RawCosine: m.cosine,
+			HasCosine: m.hasCosine,
+		}
+		if p.vectors != nil {
+			c.Vector = p.vectors[id]
+		}
+		hits = append(hits, c)
+	}
+	// The stable sort over the id-ordered slice keeps ties in ascending
+	// id order.
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].RawBM25+hits[i].RawCosine > hits[j].RawBM25+hits[j].RawCosine
+	})
+	if len(hits) > topN {
+		hits = hits[:topN]
+	}
+	return hits, nil
+}
+
+// SimilarToDocument ranks all other chunks by cosine similarity to the
+// centroid of the document's stored vectors, so more-like-this needs no
+// re-embedding. Returns (nil, nil) when the index has no vectors.
+func (p *cometProvider) SimilarToDocument(ctx context.Context, sourcePath string, topN int) ([]Candidate, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.vectors == nil {
+		return nil, nil
+	}
+	if topN <= 0 {
+		topN = maxProviderCandidates
+	}
+
+	centroid := make([]float32, p.vecDims)
+	count := 0
+	own := make(map[int]bool)
+	for id := range p.chunks {
+		if p.chunks[id].SourcePath != sourcePath {
+			continue
+		}
+		own[id] = true
+		for j, f := range p.vectors[id] {
+			centroid[j] += f
+		}
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("document %s: %w", sourcePath, ErrChunkNotFound)
+	}
+	for j := range centroid {
+		centroid[j] /= float32(count)
+	}
+
+	merged := make(map[int]*mergedHit)
+	for id, v := range p.vectors {
+		if own[id] {
+			continue
+		}
+		if cos := cosineSimilarity(centroid, v); cos > 0 {
+			merged[id] = &mergedHit{cosine: cos, hasCosine: true}
+		}
+	}
+	return p.resolveHits(merged, topN)
+}
+
+func (p *cometProvider) FetchChunk(ctx context.Context, sourcePath string, ordinal int) (*IndexedChunk, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return p.store.LoadChunkBySourceAndOrdinal(sourcePath, ordinal)
+}
+
+// QueryVector embeds a query into the stored-vector space (PCA
+// projection applied), so feedback adjustments combine with stored
+// vectors consistently.
+func (p *cometProvider) QueryVector(ctx context.Context, query string) ([]float32, error) {
+	if p.embedder == nil {
+		return nil, fmt.Errorf("no embedder configured")
+	}
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	qv, err := p.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.proj != nil {
+		qv = p.proj.apply(qv)
+	}
+	return qv, nil
+}
+
+// VectorsForRefs resolves the stored vectors for specific chunk refs.
+// Refs without a stored vector are omitted; an index built without
+// embeddings yields none.
+func (p *cometProvider) VectorsForRefs(ctx context.Context, refs []ChunkRef) ([][]float32, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.vectors == nil {
+		return nil, nil
+	}
+	byRef := make(map[ChunkRef]int, len(p.chunks))
+	for id := range p.chunks {
+		byRef[ChunkRef{SourcePath: p.chunks[id].SourcePath, ChunkOrdinal: p.chunks[id].ChunkOrdinal}] = id
+	}
+	var out [][]float32
+	for _, ref := range refs {
+		if id, ok := byRef[ref]; ok && p.vectors[id] != nil {
+			out = append(out, p.vectors[id])
+		}
+	}
+	return out, nil
+}
+
+// FetchChunkRange serves FetchChunkWithContext with one store scan
+// instead of a per-ordinal lookup each.
+func (p *cometProvider) FetchChunkRange(ctx context.Context, sourcePath string, fromOrdinal, toOrdinal int) ([]IndexedChunk, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return p.store.LoadChunkRange(sourcePath, fromOrdinal, toOrdinal)
+}
+
+func (p *cometProvider) FetchChunkByParagraphID(ctx context.Context, paragraphID string) (*IndexedChunk, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for id := range p.chunks {
+		if p.chunks[id].ParagraphID == paragraphID {
+			out := p.chunks[id]
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("paragraph %s: %w", paragraphID, ErrChunkNotFound)
+}
+
+func (p *cometProvider) LoadIndexInfo(ctx context.Context) (*IndexInfo, error) {
+	return p.store.LoadIndexInfo()
+}
+
+// ForEachChunk streams the stored chunks in id order, straight from the
+// store so an export never has to fit in memory twice.
+func (p *cometProvider) ForEachChunk(fn func(id int, c IndexedChunk) error) error {
+	return p.store.ForEachChunk(fn)
+}
+
+// LoadChunkVectors returns the id-ordered stored vectors, nil when the
+// index was built without embeddings.
+func (p *cometProvider) LoadChunkVectors() ([][]float32, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.vectors, nil
+}
+
+// Compact reclaims disk space in the backing store. Loaded in-memory
+// state stays valid: compaction rewrites pages, not contents.
+func (p *cometProvider) Compact() (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	before, after, err := p.store.Compact()
+	if err != nil {
+		return 0, err
+	}
+	p.log.Info("Compacted index.db",
+		map[string]any{"before_bytes": before, "after_bytes": after})
+	return before - after, nil
+}
+
+// StoreStatus reports comet-specific health for Service.Status: pending
+// unflushed writes and whether stored vectors exist for the active
+// embedding model.
+func (p *cometProvider) StoreStatus() (dirty, hasVectors bool, vectorDims int) {
+	dirty = p.store.IsDirty()
+	if count, dims, err := p.store.vectorFileHeader(p.modelID()); err == nil && count >= 0 {
+		hasVectors = true
+		vectorDims = dims
+	}
+	return dirty, hasVectors, vectorDims
+}
+
+// ReplaceDocument surgically swaps one document's chunks: the in-memory
+// chunk list and vectors are spliced, the BM25 index rebuilt over the
+// result, and everything persisted — no KB walk, no re-embedding of
+// unchanged documents.
+func (p *cometProvider) ReplaceDocument(ctx context.Context, sourcePath string, chunks []IndexedChunk) error {
+	if err := p.ensureLoaded(); err != nil {
+		return err
+	}
+
+	// Embed the replacement chunks before taking the write lock; only
+	// needed when the index carries vectors at all.
+	var newVecs [][]float32
+	if p.vectors != nil && p.embedder != nil && len(chunks) > 0 {
+		texts := make([]string, 0, len(chunks))
+		for _, c := range chunks {
+			texts = append(texts, c.Text)
+		}
+		var err error
+		newVecs, err = p.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding replacement chunks for %s: %w", sourcePath, err)
+		}
+		if p.proj != nil {
+			newVecs = applyPCA(p.proj, newVecs)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keptChunks := make([]IndexedChunk, 0, len(p.chunks))
+	var keptVecs [][]float32
+	if p.vectors != nil {
+		keptVecs = make([][]float32, 0, len(p.vectors))
+	}
+	// spliceAt/spliceRemoved describe the edit against the old id order —
+	// the document's chunks are contiguous there — so the vector store can
+	// persist just this splice instead of rewriting the monolith.
+	spliceAt, spliceRemoved := -1, 0
+	inserted := false
+	insert := func(id int) {
+		keptChunks = append(keptChunks, chunks...)
+		keptVecs = append(keptVecs, newVecs...)
+		if spliceAt < 0 {
+			spliceAt = id
+		}
+		inserted = true
+	}
+	for id := range p.chunks {
+		if p.chunks[id].SourcePath == sourcePath {
+			if spliceAt < 0 {
+				spliceAt = id
+			}
+			spliceRemoved++
+			continue
+		}
+		if !inserted && p.chunks[id].SourcePath > sourcePath {
+			insert(id)
+		}
+		keptChunks = append(keptChunks, p.chunks[id])
+		if p.vectors != nil {
+			keptVecs = append(keptVecs, p.vectors[id])
+		}
+	}
+	if !inserted {
+		insert(len(p.chunks))
+	}
+
+	info, err := p.store.LoadIndexInfo()
+	if err != nil {
+		return err
+	}
+	refreshIndexInfo(info, keptChunks)
+
+	if err := p.store.SaveIndex(keptChunks, *info); err != nil {
+		return err
+	}
+	if p.vectors != nil {
+		if err := p.store.SpliceVectors(p.modelID(), spliceAt, spliceRemoved, newVecs, p.vecDims); err != nil {
+			return err
+		}
+		// Fold the patch sidecar back into the monolith once it stops
+		// saving write volume; keptVecs is already the full set.
+		if p.store.vectorPatchOversized(p.modelID()) {
+			if err := p.store.SaveVectors(p.modelID(), keptVecs); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.store.Flush(); err != nil {
+		return err
+	}
+
+	p.chunks = keptChunks
+	if p.vectors != nil {
+		p.vectors = keptVecs
+	}
+	p.rebuildBM25()
+	p.log.Debug("Replaced document in index",
+		map[string]any{"source": sourcePath, "chunks": len(chunks)})
+	return nil
+}
+
+// RemoveDocument drops one document's chunks. Removing an unindexed
+// document is a no-op so deletions are idempotent.
+func (p *cometProvider) RemoveDocument(ctx context.Context, sourcePath string) error {
+	return p.ReplaceDocument(ctx, sourcePath, nil)
+}
+
+// Flush syncs buffered store writes to disk when dirty, so shutdown
+// cannot leave a torn index behind the NoSync database.
+func (p *cometProvider) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.store.IsDirty() {
+		return nil
+	}
+	return p.store.Flush()
+}
+
+func (p *cometProvider) Close() error {
+	return p.store.Close()
+}
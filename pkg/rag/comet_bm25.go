@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"math"
+	"sort"
+)
+
+// BM25SearchIndex is comet's in-memory lexical index: a classic inverted
+// index scored with BM25. It is rebuilt from the store on load, which for
+// the corpus sizes we target is cheaper than persisting postings.
+type BM25SearchIndex struct {
+	k1 float64
+	b  float64
+	// tokenizer produces the terms behind the postings; tokenize unless
+	// SetTokenizer installed another one.
+	tokenizer func(string) []string
+
+	postings map[string]map[int]int // term -> doc id -> term frequency
+	docLen   map[int]int
+	totalLen int
+}
+
+// Standard BM25 parameters, used when no tuning is configured.
+const (
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+)
+
+// NewBM25SearchIndex creates an empty index with standard parameters.
+func NewBM25SearchIndex() *BM25SearchIndex {
+	return NewBM25SearchIndexParams(0, 0)
+}
+
+// NewBM25SearchIndexParams creates an empty index with explicit k1
+// (term-frequency saturation) and b (length normalization) parameters;
+// non-positive values take the standard defaults.
+func NewBM25SearchIndexParams(k1, b float64) *BM25SearchIndex {
+	if k1 <= 0 {
+		k1 = defaultBM25K1
+	}
+	if b <= 0 {
+		b = defaultBM25B
+	}
+	return &BM25SearchIndex{
+		k1:        k1,
+		b:         b,
+		tokenizer: tokenize,
+		postings:  make(map[string]map[int]int),
+		docLen:    make(map[int]int),
+	}
+}
+
+// SetTokenizer replaces the tokenizer so lexical scoring matches the
+// rest of the pipeline (tokenizerFor); nil is ignored. Call it before
+// Add: postings built with a different tokenizer would never match the
+// query terms.
+func (x *BM25SearchIndex) SetTokenizer(fn func(string) []string) {
+	if fn != nil {
+		x.tokenizer = fn
+	}
+}
+
+// Add indexes one document's text under id.
+func (x *BM25SearchIndex) Add(id int, text string) {
+	toks := x.tokenizer(text)
+	x.docLen[id] = len(toks)
+	x.totalLen += len(toks)
+	for _, t := range toks {
+		m := x.postings[t]
+		if m == nil {
+			m = make(map[int]int)
+			x.postings[t] = m
+		}
+		m[id]++
+	}
+}
+
+// bm25Hit is one scored document id.
+type bm25Hit struct {
+	id    int
+	score float64
+}
+
+// Search scores documents against the query and returns up to topN hits
+// sorted by descending score (ties broken by ascending id).
+func (x *BM25SearchIndex) Search(query string, topN int) []bm25Hit {
+	queryTokens := x.tokenizer(query)
+	if len(queryTokens) == 0 || len(x.docLen) == 0 {
+		return nil
+	}
+	n := float64(len(x.docLen))
+	avgLen := x.totalLen / len(x.docLen)
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	scores := make(map[int]float64)
+	for _, q := range queryTokens {
+		m := x.postings[q]
+		if len(m) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(m))+0.5)/(float64(len(m))+0.5))
+		for id, tf := range m {
+			tfF := float64(tf)
+			norm := tfF * (x.k1 + 1) / (tfF + x.k1*(1-x.b+x.b*float64(x.docLen[id])/float64(avgLen)))
+			scores[id] += idf * norm
+		}
+	}
+
+	hits := make([]bm25Hit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, bm25Hit{id: id, score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+	if topN > 0 && len(hits) > topN {
+		hits = hits[:topN]
+	}
+	return hits
+}
@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestBM25ParamsDefaults(t *testing.T) {
+	x := NewBM25SearchIndex()
+	if x.k1 != defaultBM25K1 || x.b != defaultBM25B {
+		t.Errorf("defaults = %g/%g, want %g/%g", x.k1, x.b, defaultBM25K1, defaultBM25B)
+	}
+	x = NewBM25SearchIndexParams(0, -1)
+	if x.k1 != defaultBM25K1 || x.b != defaultBM25B {
+		t.Errorf("non-positive params = %g/%g, want defaults", x.k1, x.b)
+	}
+	x = NewBM25SearchIndexParams(1.5, 0.4)
+	if x.k1 != 1.5 || x.b != 0.4 {
+		t.Errorf("explicit params = %g/%g, want 1.5/0.4", x.k1, x.b)
+	}
+}
+
+// TestBM25ParamsChangeRanking pins that the knobs actually matter: with
+// strong length normalization a short document with one match outranks a
+// long one with several, and weakening b flips the order.
+func TestBM25ParamsChangeRanking(t *testing.T) {
+	var long strings.Builder
+	long.WriteString("alpha alpha alpha")
+	for i := 0; i < 27; i++ {
+		fmt.Fprintf(&long, " filler%d", i)
+	}
+
+	index := func(x *BM25SearchIndex) {
+		x.Add(1, long.String())
+		x.Add(2, "alpha beta")
+	}
+
+	std := NewBM25SearchIndex()
+	index(std)
+	hits := std.Search("alpha", 2)
+	if len(hits) != 2 || hits[0].id != 2 {
+		t.Fatalf("standard params ranked %v, want the short document first", hits)
+	}
+
+	tuned := NewBM25SearchIndexParams(1.5, 0.05)
+	index(tuned)
+	hits = tuned.Search("alpha", 2)
+	if len(hits) != 2 || hits[0].id != 1 {
+		t.Fatalf("low-b params ranked %v, want the high-tf document first", hits)
+	}
+}
+
+func TestBM25SetTokenizer(t *testing.T) {
+	x := NewBM25SearchIndex()
+	x.SetTokenizer(nil)
+	if x.tokenizer == nil {
+		t.Fatal("nil tokenizer should be ignored")
+	}
+	x.SetTokenizer(tokenizeCJK)
+	x.Add(1, "数据库迁移计划")
+	if hits := x.Search("数据库", 1); len(hits) != 1 {
+		t.Errorf("bigram tokenizer: sub-phrase query found %d hits, want 1", len(hits))
+	}
+}
+
+// TestCometKeywordRankingMatchesSimple pins that comet and simple
+// tokenize identically: the same corpus and keyword-only queries must
+// produce the same document ranking under both providers, including CJK
+// bigram mode, which comet used to ignore.
+func TestCometKeywordRankingMatchesSimple(t *testing.T) {
+	files := map[string]string{
+		"deploy.md": doc("Deploy", "2026-01-01", "The deployment checklist covers rollback, canary and deployment windows."),
+		"notes.md":  doc("Notes", "2026-01-02", "Meeting notes mention the deployment once among other topics and sandwiches."),
+		"zh.md":     doc("迁移计划", "2026-01-03", "数据库迁移计划需要先备份所有数据，然后逐步切换流量。"),
+	}
+	newSvc := func(provider string) *Service {
+		t.Helper()
+		workspace := t.TempDir()
+		for path, content := range files {
+			full := filepath.Join(workspace, "knowledge", path)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: provider, CJKBigrams: true})
+		if err != nil {
+			t.Fatalf("NewService(%s): %v", provider, err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		if _, err := svc.BuildIndex(context.Background()); err != nil {
+			t.Fatalf("BuildIndex(%s): %v", provider, err)
+		}
+		return svc
+	}
+
+	simple := newSvc("simple")
+	comet := newSvc("comet")
+	for _, query := range []string{"deployment checklist rollback", "数据库迁移"} {
+		rank := func(svc *Service) []string {
+			t.Helper()
+			result, err := svc.Search(context.Background(), SearchRequest{Query: query, Mode: "keyword", TopK: 5})
+			if err != nil {
+				t.Fatalf("Search(%q): %v", query, err)
+			}
+			return rankingOf(result)
+		}
+		simpleRank, cometRank := rank(simple), rank(comet)
+		if len(simpleRank) == 0 {
+			t.Errorf("%q: simple provider found nothing", query)
+		}
+		if !reflect.DeepEqual(simpleRank, cometRank) {
+			t.Errorf("%q: rankings diverge: simple %v, comet %v", query, simpleRank, cometRank)
+		}
+	}
+}
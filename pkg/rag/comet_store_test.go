@@ -0,0 +1,546 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestVectorsPerModel(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	vecsA := [][]float32{{1, 0}, {0, 1}}
+	vecsB := [][]float32{{1, 2, 3}}
+	if err := store.SaveVectors("model-a", vecsA); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVectors("model-b", vecsB); err != nil {
+		t.Fatal(err)
+	}
+
+	gotA, dimsA, err := store.LoadVectors("model-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotA) != 2 || dimsA != 2 {
+		t.Errorf("model-a vectors = %d x %d, want 2 x 2", len(gotA), dimsA)
+	}
+	gotB, dimsB, err := store.LoadVectors("model-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotB) != 1 || dimsB != 3 {
+		t.Errorf("model-b vectors = %d x %d, want 1 x 3", len(gotB), dimsB)
+	}
+
+	// A model that never built gets no vectors, not an error.
+	if got, _, err := store.LoadVectors("model-c"); err != nil || got != nil {
+		t.Errorf("unknown model: got %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestLoadVectorsLegacyFallback(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Pre-namespacing stores have a bare vectors.bin.
+	if err := store.SaveVectors("", [][]float32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	got, dims, err := store.LoadVectors("model-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || dims != 2 {
+		t.Errorf("legacy fallback returned %d x %d vectors", len(got), dims)
+	}
+}
+
+// TestSpliceVectorsRoundTrip pins the patch sidecar semantics: appended
+// splice segments replay in order on load, a full SaveVectors supersedes
+// them, and a flipped byte in any segment fails the per-segment CRC.
+func TestSpliceVectorsRoundTrip(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	base := [][]float32{{1, 0}, {2, 0}, {3, 0}, {4, 0}}
+	if err := store.SaveVectors("m", base); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace rows 1-2, then append one row, then remove the first.
+	if err := store.SpliceVectors("m", 1, 2, [][]float32{{9, 9}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SpliceVectors("m", 3, 0, [][]float32{{5, 0}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SpliceVectors("m", 0, 1, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	got, dims, err := store.LoadVectors("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float32{{9, 9}, {4, 0}, {5, 0}}
+	if dims != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("after splices: got %v x %d, want %v x 2", got, dims, want)
+	}
+
+	// A full rewrite folds the sidecar away.
+	if err := store.SaveVectors("m", want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.vectorsPatchPath("m")); !os.IsNotExist(err) {
+		t.Errorf("patch sidecar survived SaveVectors: %v", err)
+	}
+	if got, _, err := store.LoadVectors("m"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("after fold: got %v, %v", got, err)
+	}
+
+	// Corruption inside a segment payload must fail the load.
+	if err := store.SpliceVectors("m", 0, 0, [][]float32{{7, 7}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	path := store.vectorsPatchPath("m")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-6] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.LoadVectors("m"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("corrupt patch segment: got %v, want checksum mismatch", err)
+	}
+}
+
+func TestVerifyCountsPatchedVectors(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha"},
+		{SourcePath: "b.md", ChunkOrdinal: 1, Text: "beta"},
+	}
+	if err := store.SaveIndex(chunks, IndexInfo{TotalChunks: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVectors("m", [][]float32{{1}, {2}}); err != nil {
+		t.Fatal(err)
+	}
+	// A splice that grows the row count: the monolith header alone now
+	// disagrees with TotalChunks, but header plus patches must not.
+	if err := store.SpliceVectors("m", 1, 1, [][]float32{{3}, {4}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify("m"); !errors.Is(err, ErrIndexCorrupt) {
+		t.Fatalf("patched count 3 vs 2 chunks: got %v, want ErrIndexCorrupt", err)
+	}
+	if err := store.SaveIndex(append(chunks, IndexedChunk{SourcePath: "c.md", ChunkOrdinal: 1, Text: "gamma"}), IndexInfo{TotalChunks: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify("m"); err != nil {
+		t.Fatalf("Verify with patch-adjusted count: %v", err)
+	}
+}
+
+// BenchmarkVectorSingleDocUpdate measures the write volume of updating
+// one document's vectors in a 2000-chunk index: a patch splice versus
+// the full monolith rewrite it replaces.
+func BenchmarkVectorSingleDocUpdate(b *testing.B) {
+	const (
+		total = 2000
+		dims  = 256
+		doc   = 4 // chunks per document
+	)
+	vectors := make([][]float32, total)
+	for i := range vectors {
+		vectors[i] = make([]float32, dims)
+	}
+	replacement := vectors[:doc]
+
+	run := func(b *testing.B, splice bool) {
+		store, err := OpenStore(b.TempDir())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer store.Close()
+		if err := store.SaveVectors("m", vectors); err != nil {
+			b.Fatal(err)
+		}
+		bytesBefore := dirSize(b, store.dir)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if splice {
+				err = store.SpliceVectors("m", 100, doc, replacement, dims)
+			} else {
+				err = store.SaveVectors("m", vectors)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		if splice {
+			// Appends accumulate; report per-iteration growth.
+			b.ReportMetric(float64(dirSize(b, store.dir)-bytesBefore)/float64(b.N), "written-bytes/op")
+		} else {
+			b.ReportMetric(float64(dirSize(b, store.dir)), "written-bytes/op")
+		}
+	}
+	b.Run("full-rewrite", func(b *testing.B) { run(b, false) })
+	b.Run("patch-splice", func(b *testing.B) { run(b, true) })
+}
+
+func dirSize(tb testing.TB, dir string) int64 {
+	tb.Helper()
+	var size int64
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		size += info.Size()
+	}
+	return size
+}
+
+// TestCometReplaceDocumentPatchesVectors pins that a single-document
+// replace goes through the patch sidecar — the monolith stays untouched —
+// and that a fresh provider replays it correctly.
+func TestCometReplaceDocumentPatchesVectors(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := newCometProvider(dir, config.RAGToolsConfig{}, axisEmbedder{dims: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := make([]IndexedChunk, 0, 6)
+	for i := 0; i < 6; i++ {
+		chunks = append(chunks, IndexedChunk{
+			SourcePath:   fmt.Sprintf("doc%d.md", i),
+			ChunkOrdinal: 1,
+			ParagraphID:  fmt.Sprintf("p%d", i),
+			Text:         fmt.Sprintf("%ctext body of document %d", 'a'+i, i),
+		})
+	}
+	ctx := context.Background()
+	if err := provider.Build(ctx, chunks, IndexInfo{TotalChunks: len(chunks)}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	monolith, err := os.Stat(provider.store.vectorsPath(provider.modelID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repl := []IndexedChunk{{
+		SourcePath:   "doc2.md",
+		ChunkOrdinal: 1,
+		ParagraphID:  "p2b",
+		Text:         "ztext replacement body",
+	}}
+	if err := provider.ReplaceDocument(ctx, "doc2.md", repl); err != nil {
+		t.Fatalf("ReplaceDocument: %v", err)
+	}
+	if len(provider.vectors) != len(provider.chunks) {
+		t.Fatalf("vectors out of lockstep after replace: %d vs %d",
+			len(provider.vectors), len(provider.chunks))
+	}
+	if _, err := os.Stat(provider.store.vectorsPatchPath(provider.modelID())); err != nil {
+		t.Errorf("no patch sidecar after replace: %v", err)
+	}
+	after, err := os.Stat(provider.store.vectorsPath(provider.modelID()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(monolith.ModTime()) || after.Size() != monolith.Size() {
+		t.Error("monolith rewritten for a single-document replace")
+	}
+	if err := provider.store.Verify(provider.modelID()); err != nil {
+		t.Errorf("Verify after patched replace: %v", err)
+	}
+
+	// A fresh provider replays the patch and finds the new text.
+	if err := provider.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := newCometProvider(dir, config.RAGToolsConfig{}, axisEmbedder{dims: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	hits, err := reopened.Search(ctx, "ztext replacement", ProviderSearchOptions{TopN: 6, Mode: "semantic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, h := range hits {
+		if h.Chunk.SourcePath == "doc2.md" {
+			found = h.Chunk.ParagraphID == "p2b" && h.RawCosine > 0.99
+		}
+	}
+	if !found {
+		t.Errorf("replacement vector not replayed after reopen: %+v", hits)
+	}
+}
+
+func TestPruneVectors(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.SaveVectors("model-a", [][]float32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVectors("model-b", [][]float32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveProjection("model-b", &pcaProjection{Mean: []float32{0}, Components: [][]float32{{1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.PruneVectors([]string{"model-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d files, want 2", removed)
+	}
+	if got, _, err := store.LoadVectors("model-a"); err != nil || len(got) != 1 {
+		t.Errorf("kept model lost its vectors: %v, %v", got, err)
+	}
+	if got, _, _ := store.LoadVectors("model-b"); got != nil {
+		t.Error("pruned model still has vectors")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "projection*.json"))
+	if len(matches) != 0 {
+		t.Errorf("pruned projection still present: %v", matches)
+	}
+
+	// Prune must not touch the database itself.
+	if _, err := os.Stat(filepath.Join(dir, "index.db")); err != nil {
+		t.Errorf("index.db gone after prune: %v", err)
+	}
+}
+
+func TestStoreCompactRefusesDirty(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := []IndexedChunk{{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha"}}
+	if err := store.SaveIndex(chunks, IndexInfo{IndexVersion: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsDirty() {
+		t.Fatal("store should be dirty after SaveIndex")
+	}
+	if _, _, err := store.Compact(); err == nil {
+		t.Fatal("Compact must refuse a dirty store")
+	}
+
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	before, after, err := store.Compact()
+	if err != nil {
+		t.Fatalf("Compact after flush: %v", err)
+	}
+	if before <= 0 || after <= 0 {
+		t.Errorf("sizes = %d -> %d, want both positive", before, after)
+	}
+
+	// The database must still be usable after the swap.
+	loaded, err := store.LoadChunks()
+	if err != nil || len(loaded) != 1 {
+		t.Fatalf("LoadChunks after compact: %v (%d chunks)", err, len(loaded))
+	}
+}
+
+func TestVerifyCorruptIndex(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha"},
+		{SourcePath: "b.md", ChunkOrdinal: 1, Text: "beta"},
+	}
+	if err := store.SaveIndex(chunks, IndexInfo{IndexVersion: "v1", TotalChunks: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(""); err != nil {
+		t.Fatalf("Verify on intact index: %v", err)
+	}
+
+	// A half-written record — the motivating failure under NoSync — must
+	// surface as the typed corruption error, not a raw unmarshal error.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChunks).Put(chunkKey(1), []byte(`{"source_path": "b.md", "tex`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(""); !errors.Is(err, ErrIndexCorrupt) {
+		t.Fatalf("truncated chunk record: got %v, want ErrIndexCorrupt", err)
+	}
+
+	// A count mismatch between the bucket and the info record is also
+	// corruption.
+	if err := store.SaveIndex(chunks, IndexInfo{IndexVersion: "v2", TotalChunks: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(""); !errors.Is(err, ErrIndexCorrupt) {
+		t.Fatalf("count mismatch: got %v, want ErrIndexCorrupt", err)
+	}
+}
+
+func TestVerifyVectorCountMismatch(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha"},
+		{SourcePath: "b.md", ChunkOrdinal: 1, Text: "beta"},
+	}
+	if err := store.SaveIndex(chunks, IndexInfo{TotalChunks: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVectors("m", [][]float32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify("m"); !errors.Is(err, ErrIndexCorrupt) {
+		t.Fatalf("vector count mismatch: got %v, want ErrIndexCorrupt", err)
+	}
+}
+
+// TestStoreCompressionRoundTrip pins the value encoding: compressed and
+// uncompressed stores round-trip identical chunks through every read
+// path, and a database written without compression keeps loading after
+// reopening with it on (and vice versa).
+func TestStoreCompressionRoundTrip(t *testing.T) {
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, Text: strings.Repeat("alpha prose repeated for compressibility. ", 20)},
+		{SourcePath: "b.md", ChunkOrdinal: 1, Text: "beta"},
+		{SourcePath: "b.md", ChunkOrdinal: 2, Text: "gamma delta"},
+	}
+	info := IndexInfo{IndexVersion: "v1", TotalChunks: 3}
+
+	for _, compress := range []bool{false, true} {
+		var opts []StoreOption
+		if compress {
+			opts = append(opts, WithCompression())
+		}
+		store, err := OpenStore(t.TempDir(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		if err := store.SaveIndex(chunks, info); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := store.LoadChunks()
+		if err != nil {
+			t.Fatalf("compress=%t LoadChunks: %v", compress, err)
+		}
+		if !reflect.DeepEqual(loaded, chunks) {
+			t.Errorf("compress=%t: loaded chunks differ from saved", compress)
+		}
+		byID, err := store.LoadChunksByIndexes([]int{0, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(byID[0], chunks[0]) || !reflect.DeepEqual(byID[2], chunks[2]) {
+			t.Errorf("compress=%t: LoadChunksByIndexes mismatch", compress)
+		}
+		got, err := store.LoadChunkBySourceAndOrdinal("b.md", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Text != "gamma delta" {
+			t.Errorf("compress=%t: by-ordinal text = %q", compress, got.Text)
+		}
+		if err := store.Verify(""); err != nil {
+			t.Errorf("compress=%t: Verify: %v", compress, err)
+		}
+	}
+}
+
+// TestStoreCompressionLegacyLoad verifies the per-value header keeps
+// encodings interchangeable: records written by one mode load when the
+// store reopens in the other.
+func TestStoreCompressionLegacyLoad(t *testing.T) {
+	chunks := []IndexedChunk{{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha"}}
+	for _, firstCompressed := range []bool{false, true} {
+		dir := t.TempDir()
+		var first, second []StoreOption
+		if firstCompressed {
+			first = append(first, WithCompression())
+		} else {
+			second = append(second, WithCompression())
+		}
+
+		store, err := OpenStore(dir, first...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveIndex(chunks, IndexInfo{TotalChunks: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		store, err = OpenStore(dir, second...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := store.LoadChunks()
+		if err != nil {
+			t.Fatalf("firstCompressed=%t: LoadChunks after reopen: %v", firstCompressed, err)
+		}
+		if !reflect.DeepEqual(loaded, chunks) {
+			t.Errorf("firstCompressed=%t: chunks differ after reopen", firstCompressed)
+		}
+		store.Close()
+	}
+}
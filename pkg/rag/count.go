@@ -0,0 +1,116 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CountResult is the outcome of a filtered count: how many chunks match,
+// broken down by doc type and confidentiality. Breakdown keys are
+// lowercased; the empty key counts chunks without a value, matching
+// CorpusStats.
+type CountResult struct {
+	Total             int            `json:"total"`
+	ByDocType         map[string]int `json:"by_doc_type"`
+	ByConfidentiality map[string]int `json:"by_confidentiality"`
+}
+
+// filterCounter is implemented by providers that can count matching
+// chunks inside the engine instead of scanning stored chunks. It only
+// receives the pushdown-eligible filter subset; excludeConfidentiality
+// lists levels the count must leave out entirely (the restricted gate).
+type filterCounter interface {
+	CountChunks(ctx context.Context, filters SearchFilters, excludeConfidentiality []string) (*CountResult, error)
+}
+
+// Count reports how many indexed chunks match the filters, without
+// retrieving content — the backing for UI facets like "347 chunks tagged
+// infra". Providers that can stream their chunks are scanned with the
+// same passesFilters the search path uses, so every filter field is
+// honored; engine-counting providers (bleve) answer via facet queries
+// and support only the pushdown-eligible fields. Counting takes a queue
+// slot like Search.
+func (s *Service) Count(ctx context.Context, filters SearchFilters) (*CountResult, error) {
+	release, err := s.beginQueued()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	refTime := s.searchRefTime(ctx)
+	filters = resolveWithinDays(filters, refTime)
+
+	if it, ok := s.provider.(chunkIterator); ok {
+		res := newCountResult()
+		err := it.ForEachChunk(func(_ int, c IndexedChunk) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if s.passesFilters(&c, filters, refTime) {
+				res.add(c.DocType, c.Confidentiality, 1)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	fc, ok := s.provider.(filterCounter)
+	if !ok {
+		return nil, fmt.Errorf("index provider %s does not support counting", s.provider.Name())
+	}
+	pushed, remaining := splitPushdownFilters(filters, s.confRank)
+	if name := firstUncountableFilter(remaining); name != "" {
+		return nil, fmt.Errorf("index provider %s cannot count with the %s filter", s.provider.Name(), name)
+	}
+	// The restricted hard gate has no pushdown form, so it becomes an
+	// explicit exclusion: without AllowRestricted the top level never
+	// counts, mirroring passesFilters.
+	var exclude []string
+	if !filters.AllowRestricted && len(s.confRank.levels) > 0 {
+		exclude = []string{s.confRank.levels[len(s.confRank.levels)-1]}
+	}
+	return fc.CountChunks(ctx, pushed, exclude)
+}
+
+// firstUncountableFilter names the first filter field an engine-counting
+// provider cannot apply, or "" when the remaining set is fully covered.
+// WithinDays is fine here: resolveWithinDays already rewrote it into a
+// pushed date bound, and the engine's date field skips undated chunks
+// just as max_age does.
+func firstUncountableFilter(remaining SearchFilters) string {
+	switch {
+	case len(remaining.Tags) > 0:
+		return "tags"
+	case remaining.MaxConfidentiality != "":
+		return "max_confidentiality"
+	case remaining.DateFrom != "" || remaining.DateTo != "":
+		return "date_range"
+	case remaining.SourcePathPrefix != "":
+		return "source_path_prefix"
+	case len(remaining.SourcePaths) > 0:
+		return "source_paths"
+	case len(remaining.MetadataEquals) > 0 || len(remaining.Metadata) > 0:
+		return "metadata"
+	case len(remaining.ExcludeFlags) > 0:
+		return "exclude_flags"
+	}
+	return ""
+}
+
+func newCountResult() *CountResult {
+	return &CountResult{
+		ByDocType:         make(map[string]int),
+		ByConfidentiality: make(map[string]int),
+	}
+}
+
+// add records n matching chunks under the (lowercased) breakdown keys.
+func (r *CountResult) add(docType, confidentiality string, n int) {
+	r.Total += n
+	r.ByDocType[strings.ToLower(docType)] += n
+	r.ByConfidentiality[strings.ToLower(confidentiality)] += n
+}
@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// countFixture is a small corpus with varied doc types and
+// confidentiality levels, shared by the scan and facet count tests.
+var countFixture = map[string]string{
+	"note-a.md": "---\ntitle: Note A\ndate: 2026-01-10\ndoc_type: Note\nconfidentiality: internal\n---\n\nFirst note about rollouts.\n",
+	"note-b.md": "---\ntitle: Note B\ndate: 2026-01-11\ndoc_type: note\n---\n\nSecond note about billing.\n",
+	"policy.md": "---\ntitle: Policy\ndate: 2026-01-12\ndoc_type: policy\nconfidentiality: public\n---\n\nThe rollout policy applies to everyone.\n",
+	"secret.md": "---\ntitle: Secret\ndate: 2026-01-13\ndoc_type: note\nconfidentiality: restricted\n---\n\nThe acquisition is confidential.\n",
+}
+
+// checkCounts runs the assertions both provider flavors must agree on.
+func checkCounts(t *testing.T, svc *Service) {
+	t.Helper()
+	ctx := context.Background()
+
+	count := func(f SearchFilters) *CountResult {
+		t.Helper()
+		res, err := svc.Count(ctx, f)
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		return res
+	}
+
+	// Unfiltered: the restricted hard gate still applies, so secret.md
+	// never counts.
+	res := count(SearchFilters{})
+	if res.Total != 3 {
+		t.Errorf("unfiltered total = %d, want 3 (restricted excluded)", res.Total)
+	}
+	if res.ByDocType["note"] != 2 || res.ByDocType["policy"] != 1 {
+		t.Errorf("doc type breakdown = %v", res.ByDocType)
+	}
+	if res.ByConfidentiality["internal"] != 1 || res.ByConfidentiality["public"] != 1 || res.ByConfidentiality[""] != 1 {
+		t.Errorf("confidentiality breakdown = %v", res.ByConfidentiality)
+	}
+	if res.ByConfidentiality["restricted"] != 0 {
+		t.Errorf("restricted chunks counted without AllowRestricted: %v", res.ByConfidentiality)
+	}
+
+	// AllowRestricted opens the gate.
+	res = count(SearchFilters{AllowRestricted: true})
+	if res.Total != 4 || res.ByConfidentiality["restricted"] != 1 {
+		t.Errorf("AllowRestricted count = %+v, want the restricted chunk included", res)
+	}
+
+	// Doc-type filtering is case-insensitive like the search path.
+	res = count(SearchFilters{DocTypes: []string{"NOTE"}})
+	if res.Total != 2 || res.ByDocType["policy"] != 0 {
+		t.Errorf("doc_type-filtered count = %+v, want only the 2 unrestricted notes", res)
+	}
+
+	// A date bound narrows the count.
+	res = count(SearchFilters{DateFrom: "2026-01-12"})
+	if res.Total != 1 || res.ByDocType["policy"] != 1 {
+		t.Errorf("dated count = %+v, want only policy.md", res)
+	}
+}
+
+func TestCountSimpleProvider(t *testing.T) {
+	checkCounts(t, newTestService(t, countFixture))
+}
+
+func TestCountBleveFacets(t *testing.T) {
+	svc := newBleveTestService(t, countFixture)
+	checkCounts(t, svc)
+
+	// Filters without a pushdown form are refused rather than silently
+	// ignored.
+	_, err := svc.Count(context.Background(), SearchFilters{Tags: []string{"infra"}})
+	if err == nil || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("expected a tags-filter error from the facet path, got %v", err)
+	}
+}
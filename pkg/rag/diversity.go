@@ -0,0 +1,122 @@
+package rag
+
+// Maximal Marginal Relevance re-ranking. When a profile sets
+// DiversityLambda, the scored candidate list is greedily re-ordered to
+// balance relevance against similarity to already-selected items, so
+// near-duplicate chunks stop crowding out distinct evidence.
+
+// mmrCandidate pairs one scored item with the material needed to compute
+// its similarity to other candidates.
+type mmrCandidate struct {
+	item   EvidenceItemFull
+	vector []float32
+	// tokens is the chunk's token set, used for the Jaccard fallback when
+	// no vectors are stored. Built lazily only when needed.
+	tokens map[string]bool
+}
+
+// applyMMR re-orders items greedily: at each step it picks the remaining
+// item maximizing (1-lambda)*relevance - lambda*maxSimilarity to the
+// items already picked. Candidates supply the vectors and text; items
+// missing from candidates (which should not happen) keep zero similarity.
+func applyMMR(items []EvidenceItemFull, candidates []Candidate, lambda float64) []EvidenceItemFull {
+	if len(items) < 2 {
+		return items
+	}
+
+	byRef := make(map[ChunkRef]*Candidate, len(candidates))
+	for i := range candidates {
+		byRef[candidates[i].Chunk.Ref()] = &candidates[i]
+	}
+	useVectors := true
+	pool := make([]mmrCandidate, 0, len(items))
+	for _, item := range items {
+		mc := mmrCandidate{item: item}
+		if c := byRef[item.Ref]; c != nil {
+			mc.vector = c.Vector
+			if c.Vector == nil {
+				useVectors = false
+			}
+		} else {
+			useVectors = false
+		}
+		pool = append(pool, mc)
+	}
+	if !useVectors {
+		for i := range pool {
+			pool[i].tokens = tokenSet(byRef, pool[i].item.Ref)
+		}
+	}
+
+	out := make([]EvidenceItemFull, 0, len(pool))
+	// maxSim tracks each remaining candidate's highest similarity to any
+	// selected item, updated incrementally as items are picked.
+	maxSim := make([]float64, len(pool))
+	picked := make([]bool, len(pool))
+
+	for len(out) < len(pool) {
+		best := -1
+		bestScore := 0.0
+		for i := range pool {
+			if picked[i] {
+				continue
+			}
+			score := (1-lambda)*pool[i].item.Breakdown.FinalScore - lambda*maxSim[i]
+			// Strict comparison keeps the earlier (already tie-broken)
+			// ordering deterministic on equal MMR scores.
+			if best < 0 || score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+		picked[best] = true
+		out = append(out, pool[best].item)
+		for i := range pool {
+			if picked[i] {
+				continue
+			}
+			if sim := candidateSimilarity(&pool[best], &pool[i], useVectors); sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+	}
+	return out
+}
+
+// candidateSimilarity is cosine over stored vectors when every candidate
+// has one, else Jaccard over token sets.
+func candidateSimilarity(a, b *mmrCandidate, useVectors bool) float64 {
+	if useVectors {
+		return cosineSimilarity(a.vector, b.vector)
+	}
+	return jaccard(a.tokens, b.tokens)
+}
+
+// tokenSet builds the token set for one candidate's chunk text.
+func tokenSet(byRef map[ChunkRef]*Candidate, ref ChunkRef) map[string]bool {
+	set := make(map[string]bool)
+	if c := byRef[ref]; c != nil {
+		for _, t := range tokenize(c.Chunk.Text) {
+			set[t] = true
+		}
+	}
+	return set
+}
+
+// jaccard is |a∩b| / |a∪b| over two token sets; empty sets score 0.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	inter := 0
+	for t := range a {
+		if b[t] {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	return float64(inter) / float64(union)
+}
@@ -0,0 +1,105 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func mmrItem(path string, score float64) EvidenceItemFull {
+	return EvidenceItemFull{
+		Ref:       ChunkRef{SourcePath: path, ChunkOrdinal: 1},
+		Breakdown: ScoreBreakdown{FinalScore: score},
+	}
+}
+
+func mmrCand(path, text string) Candidate {
+	return Candidate{Chunk: IndexedChunk{
+		SourcePath:   path,
+		ChunkOrdinal: 1,
+		Text:         text,
+	}}
+}
+
+func TestApplyMMRJaccardFallback(t *testing.T) {
+	// Two near-duplicates rank first on relevance; with diversity on, the
+	// distinct chunk should displace the second duplicate.
+	items := []EvidenceItemFull{
+		mmrItem("dup1.md", 0.90),
+		mmrItem("dup2.md", 0.88),
+		mmrItem("other.md", 0.60),
+	}
+	candidates := []Candidate{
+		mmrCand("dup1.md", "postgres connection pool sizing guidance"),
+		mmrCand("dup2.md", "postgres connection pool sizing guidance notes"),
+		mmrCand("other.md", "kafka consumer rebalancing strategy"),
+	}
+
+	reranked := applyMMR(items, candidates, 0.5)
+	if got := reranked[0].Ref.SourcePath; got != "dup1.md" {
+		t.Errorf("first pick = %s, want dup1.md (highest relevance)", got)
+	}
+	if got := reranked[1].Ref.SourcePath; got != "other.md" {
+		t.Errorf("second pick = %s, want other.md (diverse)", got)
+	}
+
+	// Lambda 0 must preserve pure relevance order.
+	relevance := applyMMR(items, candidates, 0)
+	for i := range items {
+		if relevance[i].Ref != items[i].Ref {
+			t.Fatalf("lambda 0 changed ordering at %d: got %v", i, relevance[i].Ref)
+		}
+	}
+}
+
+func TestApplyMMRVectors(t *testing.T) {
+	items := []EvidenceItemFull{
+		mmrItem("a.md", 0.9),
+		mmrItem("b.md", 0.85),
+		mmrItem("c.md", 0.5),
+	}
+	candidates := []Candidate{
+		{Chunk: IndexedChunk{SourcePath: "a.md", ChunkOrdinal: 1}, Vector: []float32{1, 0}},
+		{Chunk: IndexedChunk{SourcePath: "b.md", ChunkOrdinal: 1}, Vector: []float32{0.99, 0.1}},
+		{Chunk: IndexedChunk{SourcePath: "c.md", ChunkOrdinal: 1}, Vector: []float32{0, 1}},
+	}
+	reranked := applyMMR(items, candidates, 0.6)
+	if got := reranked[1].Ref.SourcePath; got != "c.md" {
+		t.Errorf("second pick = %s, want c.md (orthogonal vector)", got)
+	}
+}
+
+func TestRunProfileDiversityNote(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "alpha beta gamma"),
+		"b.md": doc("B", "2026-01-02", "alpha beta delta"),
+	})
+	profile, err := resolveProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.DiversityLambda = 0.3
+	_, _, notes, err := svc.runProfile(context.Background(), SearchRequest{Query: "alpha beta"}, profile, 5, nil)
+	if err != nil {
+		t.Fatalf("runProfile: %v", err)
+	}
+	found := false
+	for _, n := range notes {
+		if n == "diversity re-ranking applied (lambda 0.30)" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing diversity note, got %v", notes)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := map[string]bool{"x": true, "y": true}
+	b := map[string]bool{"y": true, "z": true}
+	if got := jaccard(a, b); got != 1.0/3.0 {
+		t.Errorf("jaccard = %v, want 1/3", got)
+	}
+	if got := jaccard(a, nil); got != 0 {
+		t.Errorf("jaccard with empty set = %v, want 0", got)
+	}
+}
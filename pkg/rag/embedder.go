@@ -0,0 +1,871 @@
+package rag
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Embedder turns texts into dense vectors. Implementations must return
+// one vector per input, in order.
+type Embedder interface {
+	// Embed embeds document texts for indexing.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbedQuery embeds one search query. Providers that distinguish
+	// query from document embeddings (Voyage's input_type) use the
+	// distinction here; others just delegate to Embed.
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+	// ModelID identifies the provider+model so indexes can detect
+	// mismatched embedding spaces.
+	ModelID() string
+	// Dims is the vector dimensionality.
+	Dims() int
+}
+
+// embeddingProviderInfo describes a known embedding API endpoint.
+type embeddingProviderInfo struct {
+	BaseURL      string
+	DefaultModel string
+	Dims         int
+	NeedsKey     bool
+	// MaxBatchTokens is the provider's per-request token budget across
+	// all inputs (estimated, see estimateTokens); 0 means unbounded.
+	MaxBatchTokens int
+}
+
+// embeddingProviders registers the embedding endpoints newEmbedder can
+// construct. Most entries speak the OpenAI-compatible /embeddings
+// schema; providers with their own wire formats (cohere, gemini) bring
+// their own codec.
+var embeddingProviders = map[string]embeddingProviderInfo{
+	"openai": {
+		BaseURL:        "https://api.openai.com/v1",
+		DefaultModel:   "text-embedding-3-small",
+		Dims:           1536,
+		NeedsKey:       true,
+		MaxBatchTokens: 64000,
+	},
+	"ollama": {
+		BaseURL:      "http://localhost:11434/v1",
+		DefaultModel: "nomic-embed-text",
+		Dims:         768,
+		NeedsKey:     false,
+	},
+	"voyage": {
+		BaseURL:        "https://api.voyageai.com/v1",
+		DefaultModel:   "voyage-3",
+		Dims:           1024,
+		NeedsKey:       true,
+		MaxBatchTokens: 32000,
+	},
+	"cohere": {
+		BaseURL:        "https://api.cohere.com/v1",
+		DefaultModel:   "embed-english-v3.0",
+		Dims:           1024,
+		NeedsKey:       true,
+		MaxBatchTokens: 8000,
+	},
+	"gemini": {
+		BaseURL:      "https://generativelanguage.googleapis.com/v1beta",
+		DefaultModel: "text-embedding-004",
+		Dims:         768,
+		NeedsKey:     true,
+	},
+	"jina": {
+		BaseURL:      "https://api.jina.ai/v1",
+		DefaultModel: "jina-embeddings-v3",
+		Dims:         1024,
+		NeedsKey:     true,
+	},
+}
+
+// newEmbedder builds the configured embedder, or returns (nil, nil) when
+// no embedding provider is configured — the service then runs
+// keyword-only.
+func newEmbedder(cfg config.RAGToolsConfig) (Embedder, error) {
+	if cfg.EmbeddingProvider == "" {
+		return nil, nil
+	}
+	// Bedrock signs with SigV4 instead of a Bearer token and so has its
+	// own embedder type.
+	if cfg.EmbeddingProvider == "bedrock" {
+		return newBedrockEmbedder(cfg)
+	}
+	info, ok := embeddingProviders[cfg.EmbeddingProvider]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.EmbeddingProvider)
+	}
+	baseURL := info.BaseURL
+	if cfg.EmbeddingAPIBase != "" {
+		baseURL = cfg.EmbeddingAPIBase
+	}
+	model := info.DefaultModel
+	if cfg.EmbeddingModel != "" {
+		model = cfg.EmbeddingModel
+	}
+	if info.NeedsKey && cfg.EmbeddingAPIKey == "" {
+		return nil, fmt.Errorf("embedding provider %q requires an API key", cfg.EmbeddingProvider)
+	}
+	var codec wireCodec = openaiCodec{sendInputType: cfg.EmbeddingProvider == "voyage"}
+	dims := info.Dims
+	switch cfg.EmbeddingProvider {
+	case "cohere":
+		codec = cohereCodec{}
+	case "gemini":
+		codec = geminiCodec{}
+	case "jina":
+		codec = jinaCodec{dimensions: cfg.EmbeddingDims}
+		if cfg.EmbeddingDims > 0 {
+			dims = cfg.EmbeddingDims
+		}
+	}
+	// Matryoshka truncation: ask the API where a dimensions parameter
+	// exists, and enforce client-side regardless so the reported Dims
+	// always matches the stored vectors.
+	truncate := 0
+	if cfg.TruncateDims > 0 && cfg.TruncateDims < dims {
+		truncate = cfg.TruncateDims
+		dims = truncate
+		switch cfg.EmbeddingProvider {
+		case "openai":
+			codec = openaiCodec{dimensions: truncate}
+		case "jina":
+			codec = jinaCodec{dimensions: truncate}
+		}
+	}
+	e := &httpEmbedder{
+		provider:       cfg.EmbeddingProvider,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		apiKey:         cfg.EmbeddingAPIKey,
+		model:          model,
+		dims:           dims,
+		codec:          codec,
+		client:         &http.Client{Timeout: 60 * time.Second},
+		maxAttempts:    cfg.EmbeddingMaxAttempts,
+		maxBatchTokens: info.MaxBatchTokens,
+		truncateDims:   truncate,
+		log:            stdRAGLogger{},
+	}
+	if cfg.EmbeddingProvider == "ollama" {
+		// Prefer Ollama's native batched endpoint; the /v1 shim stays as
+		// the fallback for daemons that predate it.
+		native := *e
+		native.baseURL = strings.TrimSuffix(e.baseURL, "/v1")
+		native.codec = ollamaCodec{}
+		return &ollamaEmbedder{native: &native, shim: e}, nil
+	}
+	return e, nil
+}
+
+// defaultQueryEmbedCacheSize is the query-embedding LRU capacity when
+// QueryEmbedCacheSize is unset.
+const defaultQueryEmbedCacheSize = 128
+
+// queryCacheEmbedder wraps an Embedder with an LRU over EmbedQuery:
+// agent loops often re-issue the same query within a session (retries,
+// refinement), and re-embedding it is a pure duplicate API call. Keys
+// include the model ID, so a different model never serves stale vectors.
+// Document embedding (Embed) is deliberately uncached — build-time texts
+// rarely repeat and would just churn the cache.
+type queryCacheEmbedder struct {
+	next Embedder
+
+	mu    sync.Mutex
+	size  int
+	ll    *list.List // front = most recently used
+	byKey map[string]*list.Element
+}
+
+type queryCacheEntry struct {
+	key string
+	vec []float32
+}
+
+// wrapQueryCache wraps embedder with a query-embedding cache of the
+// configured capacity. Zero size selects the default; negative disables
+// caching and returns the embedder unchanged, as does a nil embedder.
+func wrapQueryCache(embedder Embedder, size int) Embedder {
+	if embedder == nil || size < 0 {
+		return embedder
+	}
+	if size == 0 {
+		size = defaultQueryEmbedCacheSize
+	}
+	return &queryCacheEmbedder{
+		next:  embedder,
+		size:  size,
+		ll:    list.New(),
+		byKey: make(map[string]*list.Element, size),
+	}
+}
+
+func (e *queryCacheEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.next.Embed(ctx, texts)
+}
+
+func (e *queryCacheEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	key := e.next.ModelID() + "\x00" + text
+	e.mu.Lock()
+	if el, ok := e.byKey[key]; ok {
+		e.ll.MoveToFront(el)
+		vec := el.Value.(*queryCacheEntry).vec
+		e.mu.Unlock()
+		return vec, nil
+	}
+	e.mu.Unlock()
+
+	vec, err := e.next.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if _, ok := e.byKey[key]; !ok {
+		e.byKey[key] = e.ll.PushFront(&queryCacheEntry{key: key, vec: vec})
+		for e.ll.Len() > e.size {
+			last := e.ll.Back()
+			e.ll.Remove(last)
+			delete(e.byKey, last.Value.(*queryCacheEntry).key)
+		}
+	}
+	e.mu.Unlock()
+	return vec, nil
+}
+
+func (e *queryCacheEmbedder) ModelID() string {
+	return e.next.ModelID()
+}
+
+func (e *queryCacheEmbedder) Dims() int {
+	return e.next.Dims()
+}
+
+// MaxBatchTokens forwards the inner embedder's batch budget so wrapping
+// does not hide it from build-time batching; 0 means unbounded either
+// way.
+func (e *queryCacheEmbedder) MaxBatchTokens() int {
+	if l, ok := e.next.(interface{ MaxBatchTokens() int }); ok {
+		return l.MaxBatchTokens()
+	}
+	return 0
+}
+
+func (e *queryCacheEmbedder) setLogger(l RAGLogger) {
+	if s, ok := e.next.(interface{ setLogger(RAGLogger) }); ok {
+		s.setLogger(l)
+	}
+}
+
+// truncateVector cuts v to dims dimensions and L2-renormalizes it, so
+// cosine similarities over truncated vectors stay well-scaled. Vectors
+// already at or below dims pass through unchanged (the API honored the
+// dimensions parameter).
+func truncateVector(v []float32, dims int) []float32 {
+	if dims <= 0 || len(v) <= dims {
+		return v
+	}
+	var sum float64
+	for _, x := range v[:dims] {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v[:dims]
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, dims)
+	for i, x := range v[:dims] {
+		out[i] = x / norm
+	}
+	return out
+}
+
+// estimateTokens approximates a text's token count with the usual ~4
+// characters per token heuristic; good enough to stay under provider
+// batch budgets without shipping a tokenizer.
+func estimateTokens(text string) int {
+	n := len(text) / 4
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// splitEmbedBatches partitions texts into contiguous [start, end) batches
+// of at most maxInputs texts and, when maxTokens > 0, at most maxTokens
+// estimated tokens. A single text over the budget still forms its own
+// batch so the split always makes progress.
+func splitEmbedBatches(texts []string, maxInputs, maxTokens int) [][2]int {
+	var bounds [][2]int
+	start, tokens := 0, 0
+	for i, t := range texts {
+		tk := estimateTokens(t)
+		if i > start && (i-start >= maxInputs || (maxTokens > 0 && tokens+tk > maxTokens)) {
+			bounds = append(bounds, [2]int{start, i})
+			start, tokens = i, 0
+		}
+		tokens += tk
+	}
+	if start < len(texts) {
+		bounds = append(bounds, [2]int{start, len(texts)})
+	}
+	return bounds
+}
+
+// httpEmbedder calls an HTTP embeddings endpoint; the codec handles the
+// provider's request/response schema.
+type httpEmbedder struct {
+	provider string
+	baseURL  string
+	apiKey   string
+	model    string
+	dims     int
+	codec    wireCodec
+	client   *http.Client
+	// maxAttempts bounds tries per call for rate-limited responses;
+	// <= 0 means defaultEmbedAttempts.
+	maxAttempts int
+	// retryBase overrides embedRetryBaseDelay in tests; 0 means the
+	// default.
+	retryBase time.Duration
+	// maxBatchTokens mirrors the provider's per-request token budget;
+	// 0 means unbounded.
+	maxBatchTokens int
+	// truncateDims, when positive, cuts returned vectors to this many
+	// dimensions (Matryoshka truncation) with L2 renormalization. Applied
+	// after decoding so it also covers providers whose API ignores a
+	// dimensions parameter.
+	truncateDims int
+	// log receives structured events; replaced via setLogger when the
+	// owning service has an injected RAGLogger.
+	log RAGLogger
+}
+
+func (e *httpEmbedder) setLogger(l RAGLogger) {
+	e.log = l
+}
+
+// MaxBatchTokens reports the provider's estimated per-request token
+// budget, consumed by build-time batching; 0 means unbounded.
+func (e *httpEmbedder) MaxBatchTokens() int {
+	return e.maxBatchTokens
+}
+
+func (e *httpEmbedder) ModelID() string {
+	return e.provider + "/" + e.model
+}
+
+func (e *httpEmbedder) Dims() int {
+	return e.dims
+}
+
+// Embedding kinds passed through to providers with asymmetric models.
+const (
+	embedKindDocument = "document"
+	embedKindQuery    = "query"
+)
+
+// wireCodec encodes embedding requests and decodes responses for one
+// provider's API schema, so httpEmbedder stays schema-agnostic.
+type wireCodec interface {
+	// path is the endpoint path appended to the base URL. Providers with
+	// per-model endpoints (Gemini) build it from the model name.
+	path(model string) string
+	// encodeRequest builds the request body for the given texts; kind is
+	// embedKindDocument or embedKindQuery.
+	encodeRequest(model string, texts []string, kind string) ([]byte, error)
+	// decodeResponse parses the response body into one vector per input.
+	decodeResponse(r io.Reader, inputs int) ([][]float32, error)
+}
+
+// openaiCodec speaks the OpenAI-compatible /embeddings schema, used by
+// openai, ollama and voyage. Voyage additionally takes input_type.
+type openaiCodec struct {
+	sendInputType bool
+	// dimensions, when positive, is sent as the dimensions parameter
+	// (OpenAI v3 Matryoshka truncation).
+	dimensions int
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+	// InputType distinguishes query from document embeddings for
+	// providers that train asymmetric models (Voyage). Omitted elsewhere.
+	InputType  string `json:"input_type,omitempty"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (c openaiCodec) path(string) string {
+	return "/embeddings"
+}
+
+func (c openaiCodec) encodeRequest(model string, texts []string, kind string) ([]byte, error) {
+	req := embeddingRequest{Model: model, Input: texts, Dimensions: c.dimensions}
+	if c.sendInputType {
+		req.InputType = kind
+	}
+	return json.Marshal(req)
+}
+
+func (c openaiCodec) decodeResponse(r io.Reader, inputs int) ([][]float32, error) {
+	var parsed embeddingResponse
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	if len(parsed.Data) != inputs {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(parsed.Data), inputs)
+	}
+	vectors := make([][]float32, inputs)
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding response index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// jinaCodec speaks Jina's /embeddings schema: OpenAI-compatible plus a
+// task adapter selector (retrieval.passage for documents,
+// retrieval.query for queries) and an optional Matryoshka truncation
+// dimension.
+type jinaCodec struct {
+	// dimensions, when positive, asks the API to truncate vectors to this
+	// many dimensions; 0 keeps the model's native size.
+	dimensions int
+}
+
+type jinaEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Task       string   `json:"task"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+func (c jinaCodec) path(string) string {
+	return "/embeddings"
+}
+
+func (c jinaCodec) encodeRequest(model string, texts []string, kind string) ([]byte, error) {
+	task := "retrieval.passage"
+	if kind == embedKindQuery {
+		task = "retrieval.query"
+	}
+	return json.Marshal(jinaEmbedRequest{Model: model, Input: texts, Task: task, Dimensions: c.dimensions})
+}
+
+func (c jinaCodec) decodeResponse(r io.Reader, inputs int) ([][]float32, error) {
+	// The response is the standard OpenAI data-array shape.
+	return openaiCodec{}.decodeResponse(r, inputs)
+}
+
+// ollamaCodec speaks Ollama's native /api/embed schema, which accepts a
+// batched input array. The /v1 shim on older daemons embeds one text per
+// request, so the native path saves a round-trip per chunk on large
+// builds. nomic-embed-text is symmetric; the kind is not sent.
+type ollamaCodec struct{}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+func (c ollamaCodec) path(string) string {
+	return "/api/embed"
+}
+
+func (c ollamaCodec) encodeRequest(model string, texts []string, kind string) ([]byte, error) {
+	return json.Marshal(ollamaEmbedRequest{Model: model, Input: texts})
+}
+
+func (c ollamaCodec) decodeResponse(r io.Reader, inputs int) ([][]float32, error) {
+	var parsed ollamaEmbedResponse
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	if len(parsed.Embeddings) != inputs {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(parsed.Embeddings), inputs)
+	}
+	return parsed.Embeddings, nil
+}
+
+// cohereCodec speaks Cohere's native /embed schema. embed-v3 models
+// require input_type (search_document vs search_query); using the wrong
+// one measurably drops recall, which is why the kind is threaded through
+// instead of defaulted.
+type cohereCodec struct{}
+
+type cohereEmbedRequest struct {
+	Texts     []string `json:"texts"`
+	Model     string   `json:"model"`
+	InputType string   `json:"input_type"`
+}
+
+type cohereEmbedResponse struct {
+	Embeddings struct {
+		Float [][]float32 `json:"float"`
+	} `json:"embeddings"`
+}
+
+func (c cohereCodec) path(string) string {
+	return "/embed"
+}
+
+func (c cohereCodec) encodeRequest(model string, texts []string, kind string) ([]byte, error) {
+	inputType := "search_document"
+	if kind == embedKindQuery {
+		inputType = "search_query"
+	}
+	return json.Marshal(cohereEmbedRequest{Texts: texts, Model: model, InputType: inputType})
+}
+
+func (c cohereCodec) decodeResponse(r io.Reader, inputs int) ([][]float32, error) {
+	var parsed cohereEmbedResponse
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	if len(parsed.Embeddings.Float) != inputs {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs",
+			len(parsed.Embeddings.Float), inputs)
+	}
+	return parsed.Embeddings.Float, nil
+}
+
+// geminiCodec speaks Google's generativelanguage batchEmbedContents
+// schema. The endpoint is per-model and the API key travels as a query
+// parameter, not a Bearer header. text-embedding-004 is symmetric, so
+// the query/document kind is not sent.
+type geminiCodec struct{}
+
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContent `json:"requests"`
+}
+
+type geminiEmbedContent struct {
+	Model   string        `json:"model"`
+	Content geminiContent `json:"content"`
+}
+
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+func (c geminiCodec) path(model string) string {
+	return "/models/" + model + ":batchEmbedContents"
+}
+
+// keyQueryParam marks the codec's provider as taking the API key in the
+// URL instead of an Authorization header.
+func (c geminiCodec) keyQueryParam() string {
+	return "key"
+}
+
+func (c geminiCodec) encodeRequest(model string, texts []string, kind string) ([]byte, error) {
+	req := geminiEmbedRequest{Requests: make([]geminiEmbedContent, 0, len(texts))}
+	for _, t := range texts {
+		req.Requests = append(req.Requests, geminiEmbedContent{
+			Model:   "models/" + model,
+			Content: geminiContent{Parts: []geminiPart{{Text: t}}},
+		})
+	}
+	return json.Marshal(req)
+}
+
+func (c geminiCodec) decodeResponse(r io.Reader, inputs int) ([][]float32, error) {
+	var parsed geminiEmbedResponse
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding embedding response: %w", err)
+	}
+	if len(parsed.Embeddings) != inputs {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(parsed.Embeddings), inputs)
+	}
+	vectors := make([][]float32, inputs)
+	for i := range parsed.Embeddings {
+		vectors[i] = parsed.Embeddings[i].Values
+	}
+	return vectors, nil
+}
+
+func (e *httpEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embed(ctx, texts, embedKindDocument)
+}
+
+// EmbedQuery embeds one search query, flagging query intent for providers
+// whose models are asymmetric.
+func (e *httpEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.embed(ctx, []string{text}, embedKindQuery)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// Retry policy for rate-limited embedding calls: a long-running process
+// must ride out a transient 429/503 instead of permanently degrading to
+// keyword search. Only after the attempts are exhausted does the error
+// surface and trigger the keyword fallback.
+const (
+	defaultEmbedAttempts = 3
+	embedRetryBaseDelay  = 500 * time.Millisecond
+)
+
+func (e *httpEmbedder) embed(ctx context.Context, texts []string, kind string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := e.codec.encodeRequest(e.model, texts, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := e.maxAttempts
+	if attempts <= 0 {
+		attempts = defaultEmbedAttempts
+	}
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		vectors, retryAfter, retryable, err := e.doEmbedRequest(ctx, body, len(texts))
+		if err == nil {
+			if e.truncateDims > 0 {
+				for i, v := range vectors {
+					vectors[i] = truncateVector(v, e.truncateDims)
+				}
+			}
+			return vectors, nil
+		}
+		lastErr = err
+		if !retryable || attempt == attempts-1 {
+			break
+		}
+		base := e.retryBase
+		if base <= 0 {
+			base = embedRetryBaseDelay
+		}
+		delay := base << attempt
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		e.log.Warn("Embedding endpoint rate limited, retrying", map[string]any{
+			"attempt":  attempt + 1,
+			"delay_ms": delay.Milliseconds(),
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return nil, lastErr
+}
+
+// doEmbedRequest performs one HTTP attempt. retryable marks transient
+// statuses (429, 503); retryAfter carries the server's Retry-After delay
+// when it sent one in seconds form.
+func (e *httpEmbedder) doEmbedRequest(ctx context.Context, body []byte, inputs int) ([][]float32, time.Duration, bool, error) {
+	endpoint := e.baseURL + e.codec.path(e.model)
+	keyInQuery := false
+	if kq, ok := e.codec.(interface{ keyQueryParam() string }); ok && e.apiKey != "" {
+		endpoint += "?" + kq.keyQueryParam() + "=" + url.QueryEscape(e.apiKey)
+		keyInQuery = true
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" && !keyInQuery {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		err := &embedStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+		retryable := resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode == http.StatusServiceUnavailable
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), retryable, err
+	}
+
+	vectors, err := e.codec.decodeResponse(resp.Body, inputs)
+	return vectors, 0, false, err
+}
+
+// embedStatusError is a non-OK embedding response. It carries the HTTP
+// status so callers can tell a missing endpoint apart from a hard
+// failure (the Ollama native-path fallback keys off it).
+type embedStatusError struct {
+	status int
+	body   string
+}
+
+func (e *embedStatusError) Error() string {
+	return fmt.Sprintf("embedding request returned %d: %s", e.status, e.body)
+}
+
+// parseRetryAfter handles the delay-seconds form of the header; the
+// HTTP-date form falls back to the standard backoff.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// ollamaEmbedder prefers Ollama's native /api/embed endpoint and falls
+// back to the OpenAI-compatible /v1 shim when the daemon is too old to
+// serve it. The fallback sticks for the embedder's lifetime so a build
+// does not probe the missing endpoint once per batch.
+type ollamaEmbedder struct {
+	native *httpEmbedder
+	shim   *httpEmbedder
+
+	mu      sync.Mutex
+	useShim bool
+}
+
+func (e *ollamaEmbedder) setLogger(l RAGLogger) {
+	e.native.setLogger(l)
+	e.shim.setLogger(l)
+}
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embed(ctx, texts, embedKindDocument)
+}
+
+func (e *ollamaEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.embed(ctx, []string{text}, embedKindQuery)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (e *ollamaEmbedder) ModelID() string {
+	return e.native.ModelID()
+}
+
+func (e *ollamaEmbedder) Dims() int {
+	return e.native.Dims()
+}
+
+func (e *ollamaEmbedder) embed(ctx context.Context, texts []string, kind string) ([][]float32, error) {
+	e.mu.Lock()
+	useShim := e.useShim
+	e.mu.Unlock()
+	if !useShim {
+		vectors, err := e.native.embed(ctx, texts, kind)
+		if err == nil {
+			return vectors, nil
+		}
+		// Only an endpoint-shaped failure demotes to the shim; rate
+		// limits and genuine errors surface as usual.
+		var se *embedStatusError
+		if !errors.As(err, &se) || (se.status != http.StatusNotFound && se.status != http.StatusMethodNotAllowed) {
+			return nil, err
+		}
+		e.shim.log.Warn("Ollama native embed endpoint unavailable, falling back to /v1",
+			map[string]any{"status": se.status})
+		e.mu.Lock()
+		e.useShim = true
+		e.mu.Unlock()
+	}
+	return e.shim.embed(ctx, texts, kind)
+}
+
+// ollamaPullIfNeeded asks a local Ollama daemon to pull the embedding
+// model if it is not already present. Failures are logged, not fatal:
+// the first Embed call surfaces the real error if the model is missing.
+func ollamaPullIfNeeded(ctx context.Context, cfg config.RAGToolsConfig) {
+	if cfg.EmbeddingProvider != "ollama" {
+		return
+	}
+	info := embeddingProviders["ollama"]
+	base := strings.TrimSuffix(cfg.EmbeddingAPIBase, "/")
+	if base == "" {
+		base = "http://localhost:11434/v1"
+	}
+	base = strings.TrimSuffix(base, "/v1")
+	model := cfg.EmbeddingModel
+	if model == "" {
+		model = info.DefaultModel
+	}
+
+	body, _ := json.Marshal(map[string]any{"model": model})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.WarnCF("rag", "Ollama model pull failed", map[string]any{"model": model, "error": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		logger.WarnCF("rag", "Ollama model pull returned non-OK status",
+			map[string]any{"model": model, "status": resp.StatusCode})
+	}
+}
+
+// cosineSimilarity computes the cosine similarity of two vectors of equal
+// length; it returns 0 for mismatched or zero vectors.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,692 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// embedServer fakes an /embeddings endpoint and records the request
+// bodies it receives.
+func embedServer(t *testing.T, dims int) (*httptest.Server, *[]embeddingRequest) {
+	t.Helper()
+	var seen []embeddingRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]datum, len(req.Input))
+		for i := range req.Input {
+			data[i] = datum{Index: i, Embedding: make([]float32, dims)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &seen
+}
+
+func TestVoyageEmbedderInputType(t *testing.T) {
+	ts, seen := embedServer(t, 1024)
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "voyage",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "voyage/voyage-3" {
+		t.Errorf("ModelID = %q, want voyage/voyage-3", got)
+	}
+	if got := emb.Dims(); got != 1024 {
+		t.Errorf("Dims = %d, want 1024", got)
+	}
+
+	if _, err := emb.Embed(context.Background(), []string{"doc one", "doc two"}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "the query"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	if len(*seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(*seen))
+	}
+	if got := (*seen)[0].InputType; got != "document" {
+		t.Errorf("Embed input_type = %q, want document", got)
+	}
+	if got := (*seen)[1].InputType; got != "query" {
+		t.Errorf("EmbedQuery input_type = %q, want query", got)
+	}
+	if got := (*seen)[1].Input; len(got) != 1 || got[0] != "the query" {
+		t.Errorf("EmbedQuery input = %v", got)
+	}
+}
+
+func TestOpenAIEmbedderOmitsInputType(t *testing.T) {
+	ts, seen := embedServer(t, 4)
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "openai",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+	if got := (*seen)[0].InputType; got != "" {
+		t.Errorf("openai input_type = %q, want empty", got)
+	}
+}
+
+func TestVoyageEmbedderNeedsKey(t *testing.T) {
+	if _, err := newEmbedder(config.RAGToolsConfig{EmbeddingProvider: "voyage"}); err == nil {
+		t.Fatal("expected error for missing API key")
+	}
+}
+
+func TestCohereEmbedder(t *testing.T) {
+	var seen []cohereEmbedRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed" {
+			t.Errorf("path = %s, want /embed", r.URL.Path)
+		}
+		var req cohereEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		vectors := make([][]float32, len(req.Texts))
+		for i := range vectors {
+			vectors[i] = make([]float32, 1024)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": map[string]any{"float": vectors}})
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "cohere",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "cohere/embed-english-v3.0" {
+		t.Errorf("ModelID = %q", got)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 1024 {
+		t.Errorf("got %d vectors of %d dims", len(vectors), len(vectors[0]))
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(seen))
+	}
+	if got := seen[0].InputType; got != "search_document" {
+		t.Errorf("Embed input_type = %q, want search_document", got)
+	}
+	if got := seen[1].InputType; got != "search_query" {
+		t.Errorf("EmbedQuery input_type = %q, want search_query", got)
+	}
+}
+
+func TestEmbedRetriesRateLimit(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]datum, len(req.Input))
+		for i := range req.Input {
+			data[i] = datum{Index: i, Embedding: []float32{1, 2, 3}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer ts.Close()
+
+	emb := &httpEmbedder{
+		provider:  "openai",
+		baseURL:   ts.URL,
+		model:     "m",
+		dims:      3,
+		codec:     openaiCodec{},
+		client:    ts.Client(),
+		retryBase: time.Millisecond,
+		log:       stdRAGLogger{},
+	}
+	vectors, err := emb.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed after retries: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 3 {
+		t.Errorf("got %d vectors of %d dims", len(vectors), len(vectors[0]))
+	}
+	if calls != 3 {
+		t.Errorf("server saw %d calls, want 3 (2 rate limited + 1 ok)", calls)
+	}
+}
+
+func TestEmbedRetriesExhausted(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	emb := &httpEmbedder{
+		provider:    "openai",
+		baseURL:     ts.URL,
+		model:       "m",
+		dims:        3,
+		codec:       openaiCodec{},
+		client:      ts.Client(),
+		maxAttempts: 2,
+		retryBase:   time.Millisecond,
+		log:         stdRAGLogger{},
+	}
+	if _, err := emb.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+	if calls != 2 {
+		t.Errorf("server saw %d calls, want 2", calls)
+	}
+
+	// Client errors are not retryable.
+	calls = 0
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts2.Close()
+	emb.baseURL = ts2.URL
+	emb.client = ts2.Client()
+	if _, err := emb.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatal("expected an error for a 400 response")
+	}
+	if calls != 1 {
+		t.Errorf("server saw %d calls for a 400, want 1", calls)
+	}
+}
+
+func TestSplitEmbedBatches(t *testing.T) {
+	long := strings.Repeat("x", 400) // ~100 estimated tokens
+	texts := make([]string, 10)
+	for i := range texts {
+		texts[i] = long
+	}
+
+	// Budget of 250 tokens fits two long texts per batch.
+	bounds := splitEmbedBatches(texts, 64, 250)
+	total := 0
+	for _, b := range bounds {
+		if b[1] <= b[0] {
+			t.Fatalf("empty batch %v", b)
+		}
+		tokens := 0
+		for _, text := range texts[b[0]:b[1]] {
+			tokens += estimateTokens(text)
+		}
+		if b[1]-b[0] > 1 && tokens > 250 {
+			t.Errorf("batch %v estimated at %d tokens, over the 250 budget", b, tokens)
+		}
+		total += b[1] - b[0]
+	}
+	if total != len(texts) {
+		t.Errorf("batches cover %d texts, want %d", total, len(texts))
+	}
+	if len(bounds) != 5 {
+		t.Errorf("got %d batches, want 5 (two long texts each)", len(bounds))
+	}
+
+	// A single text over the whole budget still forms its own batch.
+	bounds = splitEmbedBatches([]string{strings.Repeat("y", 4000), "short"}, 64, 100)
+	if len(bounds) != 2 || bounds[0] != [2]int{0, 1} {
+		t.Errorf("oversized text batching = %v", bounds)
+	}
+
+	// Without a token budget the input cap alone applies.
+	bounds = splitEmbedBatches(texts, 4, 0)
+	if len(bounds) != 3 || bounds[0][1]-bounds[0][0] != 4 {
+		t.Errorf("input-cap batching = %v", bounds)
+	}
+}
+
+func TestGeminiEmbedder(t *testing.T) {
+	var seen []geminiEmbedRequest
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/models/text-embedding-004:batchEmbedContents"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Gemini request must not carry an Authorization header")
+		}
+		keys = append(keys, r.URL.Query().Get("key"))
+		var req geminiEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		embeddings := make([]map[string]any, len(req.Requests))
+		for i := range embeddings {
+			embeddings[i] = map[string]any{"values": make([]float32, 768)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "gemini",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "gemini/text-embedding-004" {
+		t.Errorf("ModelID = %q", got)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 768 {
+		t.Errorf("got %d vectors of %d dims", len(vectors), len(vectors[0]))
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(seen))
+	}
+	if got := len(seen[0].Requests); got != 2 {
+		t.Errorf("batch request carried %d entries, want 2", got)
+	}
+	if got := seen[0].Requests[0].Model; got != "models/text-embedding-004" {
+		t.Errorf("per-entry model = %q", got)
+	}
+	if got := seen[0].Requests[1].Content.Parts[0].Text; got != "b" {
+		t.Errorf("second entry text = %q", got)
+	}
+	for _, k := range keys {
+		if k != "test-key" {
+			t.Errorf("key query parameter = %q, want test-key", k)
+		}
+	}
+}
+
+func TestGeminiEmbedderNeedsKey(t *testing.T) {
+	if _, err := newEmbedder(config.RAGToolsConfig{EmbeddingProvider: "gemini"}); err == nil {
+		t.Fatal("expected error for missing API key")
+	}
+}
+
+func TestOllamaEmbedderNativePath(t *testing.T) {
+	var seen []ollamaEmbedRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("path = %s, want /api/embed", r.URL.Path)
+		}
+		var req ollamaEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		vectors := make([][]float32, len(req.Input))
+		for i := range vectors {
+			vectors[i] = make([]float32, 768)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": vectors})
+	}))
+	defer ts.Close()
+
+	// The configured base keeps the /v1 suffix; the native path strips it.
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "ollama",
+		EmbeddingAPIBase:  ts.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "ollama/nomic-embed-text" {
+		t.Errorf("ModelID = %q", got)
+	}
+	if got := emb.Dims(); got != 768 {
+		t.Errorf("Dims = %d, want 768", got)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 3 || len(vectors[0]) != 768 {
+		t.Errorf("got %d vectors of %d dims", len(vectors), len(vectors[0]))
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	// The whole batch travels in one native request.
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(seen))
+	}
+	if got := seen[0].Input; len(got) != 3 || got[2] != "c" {
+		t.Errorf("batch input = %v", got)
+	}
+	if got := seen[0].Model; got != "nomic-embed-text" {
+		t.Errorf("model = %q", got)
+	}
+}
+
+func TestOllamaEmbedderFallsBackToShim(t *testing.T) {
+	var nativeCalls, shimCalls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embed":
+			nativeCalls++
+			http.NotFound(w, r)
+		case "/v1/embeddings":
+			shimCalls++
+			var req embeddingRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("bad request body: %v", err)
+			}
+			type datum struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}
+			data := make([]datum, len(req.Input))
+			for i := range data {
+				data[i] = datum{Index: i, Embedding: make([]float32, 768)}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": data})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "ollama",
+		EmbeddingAPIBase:  ts.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 2 {
+		t.Errorf("got %d vectors, want 2", len(vectors))
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	// The missing endpoint is probed exactly once; later calls go straight
+	// to the shim.
+	if nativeCalls != 1 {
+		t.Errorf("native endpoint probed %d times, want 1", nativeCalls)
+	}
+	if shimCalls != 2 {
+		t.Errorf("shim served %d requests, want 2", shimCalls)
+	}
+}
+
+// countingEmbedder wraps axisEmbedder and counts query embeddings.
+type countingEmbedder struct {
+	axisEmbedder
+	queryCalls int
+}
+
+func (e *countingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	e.queryCalls++
+	return e.axisEmbedder.EmbedQuery(ctx, text)
+}
+
+func TestQueryEmbedCache(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{axisEmbedder: axisEmbedder{dims: 4}}
+	cached := wrapQueryCache(inner, 2)
+
+	v1, err := cached.EmbedQuery(ctx, "deploy runbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := cached.EmbedQuery(ctx, "deploy runbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.queryCalls != 1 {
+		t.Errorf("repeated query hit the embedder %d times, want 1", inner.queryCalls)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Error("cached vector differs from the original")
+	}
+
+	if _, err := cached.EmbedQuery(ctx, "incident timeline"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.queryCalls != 2 {
+		t.Errorf("distinct query should miss; embedder called %d times, want 2", inner.queryCalls)
+	}
+
+	// Capacity 2: a third distinct query evicts the least recently used
+	// entry, so re-asking the first query embeds again.
+	if _, err := cached.EmbedQuery(ctx, "oncall rotation"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.EmbedQuery(ctx, "deploy runbook"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.queryCalls != 4 {
+		t.Errorf("evicted query should miss; embedder called %d times, want 4", inner.queryCalls)
+	}
+
+	// The wrapper is transparent for everything else.
+	if cached.ModelID() != inner.ModelID() || cached.Dims() != inner.Dims() {
+		t.Error("wrapper does not forward ModelID/Dims")
+	}
+	if wrapQueryCache(nil, 0) != nil {
+		t.Error("wrapping a nil embedder should stay nil")
+	}
+	if wrapQueryCache(inner, -1) != Embedder(inner) {
+		t.Error("negative size should disable the cache")
+	}
+}
+
+func TestSearchReusesCachedQueryEmbedding(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{axisEmbedder: axisEmbedder{dims: 8}}
+	provider, err := newSimpleProvider(filepath.Join(t.TempDir(), "index.json"),
+		wrapQueryCache(inner, 0), config.RAGToolsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, Text: "alpha bravo charlie", Title: "A"},
+		{SourcePath: "b.md", ChunkOrdinal: 1, Text: "delta echo foxtrot", Title: "B"},
+	}
+	if err := provider.Build(ctx, chunks, IndexInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Search(ctx, "alpha bravo", ProviderSearchOptions{TopN: 5, Mode: "hybrid"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.queryCalls != 1 {
+		t.Errorf("three identical hybrid searches embedded the query %d times, want 1", inner.queryCalls)
+	}
+}
+
+func TestJinaEmbedderTaskTypes(t *testing.T) {
+	var seen []jinaEmbedRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("path = %s, want /embeddings", r.URL.Path)
+		}
+		var req jinaEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]datum, len(req.Input))
+		for i := range req.Input {
+			data[i] = datum{Index: i, Embedding: make([]float32, 256)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "jina",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+		EmbeddingDims:     256,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.ModelID(); got != "jina/jina-embeddings-v3" {
+		t.Errorf("ModelID = %q", got)
+	}
+	if got := emb.Dims(); got != 256 {
+		t.Errorf("Dims = %d, want the truncated 256", got)
+	}
+
+	if _, err := emb.Embed(context.Background(), []string{"doc one", "doc two"}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if _, err := emb.EmbedQuery(context.Background(), "the query"); err != nil {
+		t.Fatalf("EmbedQuery: %v", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(seen))
+	}
+	if got := seen[0].Task; got != "retrieval.passage" {
+		t.Errorf("Embed task = %q, want retrieval.passage", got)
+	}
+	if got := seen[1].Task; got != "retrieval.query" {
+		t.Errorf("EmbedQuery task = %q, want retrieval.query", got)
+	}
+	if got := seen[0].Dimensions; got != 256 {
+		t.Errorf("Embed dimensions = %d, want 256", got)
+	}
+}
+
+func TestJinaEmbedderNeedsKey(t *testing.T) {
+	if _, err := newEmbedder(config.RAGToolsConfig{EmbeddingProvider: "jina"}); err == nil {
+		t.Fatal("expected error for missing API key")
+	}
+}
+
+func TestTruncateDims(t *testing.T) {
+	var seen []embeddingRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		seen = append(seen, req)
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		// The server ignores the dimensions parameter and answers with
+		// full-width vectors, exercising the client-side fallback.
+		data := make([]datum, len(req.Input))
+		for i := range req.Input {
+			data[i] = datum{Index: i, Embedding: []float32{3, 4, 0, 0, 5, 6, 7, 8}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer ts.Close()
+
+	emb, err := newEmbedder(config.RAGToolsConfig{
+		EmbeddingProvider: "openai",
+		EmbeddingAPIKey:   "test-key",
+		EmbeddingAPIBase:  ts.URL,
+		TruncateDims:      4,
+	})
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if got := emb.Dims(); got != 4 {
+		t.Errorf("Dims = %d, want 4", got)
+	}
+
+	vectors, err := emb.Embed(context.Background(), []string{"a"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if got := seen[0].Dimensions; got != 4 {
+		t.Errorf("request dimensions = %d, want 4", got)
+	}
+	if len(vectors[0]) != 4 {
+		t.Fatalf("vector has %d dims, want 4", len(vectors[0]))
+	}
+	var norm float64
+	for _, x := range vectors[0] {
+		norm += float64(x) * float64(x)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("truncated vector norm^2 = %v, want 1", norm)
+	}
+	// [3 4 0 0] normalizes to [0.6 0.8 0 0].
+	if math.Abs(float64(vectors[0][0])-0.6) > 1e-5 || math.Abs(float64(vectors[0][1])-0.8) > 1e-5 {
+		t.Errorf("truncated vector = %v, want [0.6 0.8 0 0]", vectors[0])
+	}
+
+	// A window at or above the native size is a no-op.
+	if v := truncateVector([]float32{1, 2}, 4); len(v) != 2 {
+		t.Errorf("oversized truncation changed the vector: %v", v)
+	}
+}
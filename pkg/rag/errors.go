@@ -0,0 +1,28 @@
+package rag
+
+import "errors"
+
+var (
+	// ErrNoIndex indicates no index has been built yet for this workspace.
+	ErrNoIndex = errors.New("index not built")
+
+	// ErrChunkNotFound indicates a chunk reference did not resolve.
+	ErrChunkNotFound = errors.New("chunk not found")
+
+	// ErrQueueFull indicates the service's bounded search queue is at
+	// capacity; callers should retry after RetryAfterSeconds.
+	ErrQueueFull = errors.New("search queue full")
+
+	// ErrShuttingDown indicates the service no longer accepts requests
+	// because Shutdown has begun.
+	ErrShuttingDown = errors.New("service is shutting down")
+
+	// ErrReadOnly indicates a mutating operation was attempted on a
+	// service constructed with NewReadOnlyService.
+	ErrReadOnly = errors.New("service is read-only")
+
+	// ErrIndexCorrupt indicates the stored index failed verification
+	// (half-written records, mismatched counts); a full rebuild is the
+	// only recovery.
+	ErrIndexCorrupt = errors.New("index corrupt")
+)
@@ -0,0 +1,187 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dataset is a labeled retrieval benchmark: documents, queries and graded
+// relevance judgments keyed by their ids.
+type Dataset struct {
+	Name    string
+	Docs    map[string]string
+	Queries map[string]string
+	// Qrels maps query id -> doc id -> relevance grade.
+	Qrels map[string]map[string]float64
+}
+
+// beirRegistry holds the BEIR dataset names we consider supported. The
+// loader itself accepts any name whose directory follows the BEIR layout;
+// registration is what makes a dataset show up in listings and gives it a
+// published baseline to compare against.
+var beirRegistry = make(map[string]bool)
+
+// PublishedBaselines is the BM25 nDCG@10 reported in the BEIR paper
+// (Thakur et al., 2021) per dataset, rendered alongside our own numbers
+// in the comparison table.
+var PublishedBaselines = make(map[string]float64)
+
+// RegisterBEIR adds a dataset name to the registry, optionally with its
+// published BM25 baseline (0 means none known).
+func RegisterBEIR(name string, baseline float64) {
+	beirRegistry[name] = true
+	if baseline > 0 {
+		PublishedBaselines[name] = baseline
+	}
+}
+
+// RegisteredDatasets lists the registered dataset names, sorted.
+func RegisteredDatasets() []string {
+	names := make([]string, 0, len(beirRegistry))
+	for name := range beirRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterBEIR("scifact", 0.665)
+	RegisterBEIR("nfcorpus", 0.325)
+	RegisterBEIR("fiqa", 0.236)
+	RegisterBEIR("arguana", 0.315)
+	RegisterBEIR("trec-covid", 0.656)
+	RegisterBEIR("scidocs", 0.158)
+	RegisterBEIR("quora", 0.789)
+}
+
+// beirDoc is one corpus.jsonl line.
+type beirDoc struct {
+	ID    string `json:"_id"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// LoadBEIRDataset reads a dataset in the BEIR layout (corpus.jsonl,
+// queries.jsonl, qrels/*.tsv) from root/name. Qrels splits are tried in
+// test, dev, train order: some mirrors ship only one of them.
+func LoadBEIRDataset(root, name string) (*Dataset, error) {
+	dir := filepath.Join(root, name)
+	ds := &Dataset{
+		Name:    name,
+		Docs:    make(map[string]string),
+		Queries: make(map[string]string),
+		Qrels:   make(map[string]map[string]float64),
+	}
+
+	err := forEachJSONLine(filepath.Join(dir, "corpus.jsonl"), func(line []byte) error {
+		var d beirDoc
+		if err := json.Unmarshal(line, &d); err != nil {
+			return err
+		}
+		text := d.Text
+		if d.Title != "" {
+			text = d.Title + "\n\n" + d.Text
+		}
+		ds.Docs[d.ID] = text
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading %s corpus: %w", name, err)
+	}
+
+	err = forEachJSONLine(filepath.Join(dir, "queries.jsonl"), func(line []byte) error {
+		var q beirDoc
+		if err := json.Unmarshal(line, &q); err != nil {
+			return err
+		}
+		ds.Queries[q.ID] = q.Text
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading %s queries: %w", name, err)
+	}
+
+	if err := ds.loadQrels(dir); err != nil {
+		return nil, fmt.Errorf("loading %s qrels: %w", name, err)
+	}
+	return ds, nil
+}
+
+// loadQrels reads the first qrels split that exists.
+func (ds *Dataset) loadQrels(dir string) error {
+	var lastErr error
+	for _, split := range []string{"test.tsv", "dev.tsv", "train.tsv"} {
+		path := filepath.Join(dir, "qrels", split)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return ds.parseQrels(f)
+	}
+	return lastErr
+}
+
+// parseQrels reads the TSV `query-id<TAB>corpus-id<TAB>score` format,
+// skipping the header line if present.
+func (ds *Dataset) parseQrels(f *os.File) error {
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return fmt.Errorf("malformed qrels line %q", line)
+		}
+		if first {
+			first = false
+			if _, err := strconv.ParseFloat(fields[len(fields)-1], 64); err != nil {
+				continue // header
+			}
+		}
+		grade, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return fmt.Errorf("malformed qrels score in %q", line)
+		}
+		qid, did := fields[0], fields[1]
+		if ds.Qrels[qid] == nil {
+			ds.Qrels[qid] = make(map[string]float64)
+		}
+		ds.Qrels[qid][did] = grade
+	}
+	return sc.Err()
+}
+
+// forEachJSONLine streams a JSONL file line by line.
+func forEachJSONLine(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 1024*1024), 16*1024*1024)
+	for sc.Scan() {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		if err := fn(sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
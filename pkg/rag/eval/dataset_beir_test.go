@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBEIRRegistry(t *testing.T) {
+	for _, name := range []string{"scifact", "nfcorpus", "fiqa", "arguana", "trec-covid", "scidocs", "quora"} {
+		if !beirRegistry[name] {
+			t.Errorf("dataset %s not registered", name)
+		}
+		if PublishedBaselines[name] <= 0 {
+			t.Errorf("dataset %s has no published baseline", name)
+		}
+	}
+	names := RegisteredDatasets()
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("RegisteredDatasets not sorted: %v", names)
+		}
+	}
+}
+
+func writeBEIRFixture(t *testing.T, root, name, qrelsSplit string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "qrels"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	corpus := `{"_id": "d1", "title": "Doc One", "text": "first document"}` + "\n" +
+		`{"_id": "d2", "text": "second document"}` + "\n"
+	queries := `{"_id": "q1", "text": "first"}` + "\n"
+	qrels := "query-id\tcorpus-id\tscore\nq1\td1\t1\nq1\td2\t0\n"
+	if err := os.WriteFile(filepath.Join(dir, "corpus.jsonl"), []byte(corpus), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "queries.jsonl"), []byte(queries), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "qrels", qrelsSplit), []byte(qrels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBEIRDataset(t *testing.T) {
+	root := t.TempDir()
+	writeBEIRFixture(t, root, "tiny", "test.tsv")
+
+	ds, err := LoadBEIRDataset(root, "tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Docs) != 2 || len(ds.Queries) != 1 {
+		t.Fatalf("loaded %d docs, %d queries", len(ds.Docs), len(ds.Queries))
+	}
+	if ds.Docs["d1"] != "Doc One\n\nfirst document" {
+		t.Errorf("title not prefixed: %q", ds.Docs["d1"])
+	}
+	if ds.Qrels["q1"]["d1"] != 1 || ds.Qrels["q1"]["d2"] != 0 {
+		t.Errorf("qrels = %v", ds.Qrels["q1"])
+	}
+}
+
+func TestLoadBEIRDatasetQrelsFallback(t *testing.T) {
+	root := t.TempDir()
+	// Some mirrors ship only a dev or train split.
+	writeBEIRFixture(t, root, "devonly", "dev.tsv")
+
+	ds, err := LoadBEIRDataset(root, "devonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Qrels) != 1 {
+		t.Errorf("fallback split not loaded: %v", ds.Qrels)
+	}
+
+	writeBEIRFixture(t, root, "noqrels", "dev.tsv")
+	if err := os.Remove(filepath.Join(root, "noqrels", "qrels", "dev.tsv")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBEIRDataset(root, "noqrels"); err == nil {
+		t.Error("expected an error with no qrels split at all")
+	}
+}
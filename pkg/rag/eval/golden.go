@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+// GoldenDataset is a hand-curated retrieval benchmark over the live
+// knowledge base: queries with graded document judgments, keyed by
+// KB-relative source paths rather than BEIR doc ids. It is the format
+// `picoclaw rag golden-init` bootstraps and users then curate.
+type GoldenDataset struct {
+	Queries []GoldenQuery `yaml:"queries"`
+}
+
+// GoldenQuery is one judgment: the query and the documents judged
+// against it, graded (1 = relevant, higher = more so). A grade of 0 or
+// below marks the document as known irrelevant — it must not surface
+// for this query, and doing so counts against ForbiddenHitRate.
+type GoldenQuery struct {
+	Query    string             `yaml:"query"`
+	Relevant map[string]float64 `yaml:"relevant"`
+}
+
+// LoadGoldenDataset reads a golden YAML file.
+func LoadGoldenDataset(path string) (*GoldenDataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ds GoldenDataset
+	if err := yaml.Unmarshal(data, &ds); err != nil {
+		return nil, fmt.Errorf("parsing golden dataset %s: %w", path, err)
+	}
+	for i, q := range ds.Queries {
+		if q.Query == "" {
+			return nil, fmt.Errorf("golden dataset %s: query %d is empty", path, i+1)
+		}
+	}
+	return &ds, nil
+}
+
+// EvaluateGolden runs every judged golden query against the service and
+// scores the rankings. Judgments are keyed by KB-relative source path,
+// so hits map straight onto labels; PerSourceCap 1 keeps each hit a
+// distinct document, the granularity the judgments speak. Queries
+// without judgments are skipped, and per-query search errors are
+// recorded as failures rather than aborting the run.
+func EvaluateGolden(ctx context.Context, svc *rag.Service, ds *GoldenDataset, k int) (*Report, error) {
+	if k <= 0 {
+		k = 10
+	}
+	report := &Report{Dataset: "golden", K: k}
+	if st, err := svc.Status(ctx); err == nil {
+		report.Provider = st.Provider
+	}
+	for i, gq := range ds.Queries {
+		if len(gq.Relevant) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		qid := fmt.Sprintf("g%d", i+1)
+		result, err := svc.Search(ctx, rag.SearchRequest{Query: gq.Query, TopK: k, PerSourceCap: 1})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("query %q: %w", gq.Query, err)
+			}
+			report.Failures = append(report.Failures, QueryFailure{
+				QueryID: qid,
+				Query:   gq.Query,
+				Error:   err.Error(),
+			})
+			continue
+		}
+		ranked := make([]string, 0, len(result.Items))
+		for _, item := range result.Items {
+			ranked = append(ranked, item.Ref.SourcePath)
+		}
+		report.Queries = append(report.Queries, QueryResult{
+			QueryID: qid,
+			Query:   gq.Query,
+			Metrics: ComputeAll(ranked, gq.Relevant, k),
+		})
+	}
+	report.Finalize()
+	return report, nil
+}
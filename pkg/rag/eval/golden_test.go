@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+func TestLoadGoldenDataset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.yml")
+	content := `# Auto-generated by picoclaw rag golden-init.
+queries:
+  - query: database incident remediation
+    relevant:
+      runbooks/incident.md: 1
+      postmortems/db.md: 2
+  - query: onboarding checklist
+    relevant: {}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := LoadGoldenDataset(path)
+	if err != nil {
+		t.Fatalf("LoadGoldenDataset: %v", err)
+	}
+	if len(ds.Queries) != 2 {
+		t.Fatalf("got %d queries, want 2", len(ds.Queries))
+	}
+	if ds.Queries[0].Relevant["postmortems/db.md"] != 2 {
+		t.Errorf("grade = %v, want 2", ds.Queries[0].Relevant["postmortems/db.md"])
+	}
+	if len(ds.Queries[1].Relevant) != 0 {
+		t.Errorf("unjudged query carries judgments: %v", ds.Queries[1].Relevant)
+	}
+
+	// An empty query is a curation error worth failing loudly on.
+	if err := os.WriteFile(path, []byte("queries:\n  - query: \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGoldenDataset(path); err == nil {
+		t.Error("expected error for empty query")
+	}
+}
+
+func TestEvaluateGoldenForbiddenHits(t *testing.T) {
+	workspace := t.TempDir()
+	kb := filepath.Join(workspace, "knowledge")
+	if err := os.MkdirAll(kb, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]string{
+		"rollout.md": "The deployment rollout checklist covers canary and rollback steps.",
+		"billing.md": "Invoice reconciliation happens monthly in the billing service.",
+		"lunch.md":   "Sandwiches and coffee were discussed at length.",
+	}
+	for name, body := range docs {
+		if err := os.WriteFile(filepath.Join(kb, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc, err := rag.NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	ctx := context.Background()
+	if _, err := svc.BuildIndex(ctx); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	// The fixture goes through YAML so a literal 0 grade survives loading.
+	path := filepath.Join(t.TempDir(), "golden.yml")
+	content := `queries:
+  - query: deployment rollout checklist
+    relevant:
+      rollout.md: 1
+      billing.md: 0
+  - query: invoice reconciliation billing
+    relevant:
+      billing.md: 0
+  - query: never judged
+    relevant: {}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := LoadGoldenDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := EvaluateGolden(ctx, svc, ds, 10)
+	if err != nil {
+		t.Fatalf("EvaluateGolden: %v", err)
+	}
+	if len(report.Queries) != 2 {
+		t.Fatalf("evaluated %d queries, want 2 (unjudged ones are skipped): %+v", len(report.Queries), report.Queries)
+	}
+
+	// Query 1 ranks its relevant document and keeps the forbidden one out.
+	q1 := report.Queries[0].Metrics
+	if q1.MRR != 1 || q1.ForbiddenHitRate != 0 {
+		t.Errorf("judged query: MRR = %v, ForbiddenHitRate = %v, want 1 and 0", q1.MRR, q1.ForbiddenHitRate)
+	}
+	// Query 2's only labeled document is known irrelevant, yet it is the
+	// lexical top hit — the leak must register.
+	q2 := report.Queries[1].Metrics
+	if q2.ForbiddenHitRate != 1 {
+		t.Errorf("forbidden doc leaked silently: ForbiddenHitRate = %v, want 1", q2.ForbiddenHitRate)
+	}
+	if got := report.Summary.ForbiddenHitRate; !approx(got, 0.5) {
+		t.Errorf("summary ForbiddenHitRate = %v, want 0.5", got)
+	}
+}
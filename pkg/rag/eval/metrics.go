@@ -0,0 +1,201 @@
+// Package eval is the retrieval evaluation harness: standard ranking
+// metrics over labeled query sets, for comparing index configurations
+// against each other and against external baselines.
+package eval
+
+import (
+	"math"
+	"sort"
+)
+
+// MetricsSet is one query's (or one run's averaged) retrieval metrics.
+// Relevance labels are graded; metrics that need a binary notion of
+// relevant treat any grade > 0 as relevant. A label with grade <= 0
+// marks a document as known irrelevant — it never counts as a hit, and
+// ForbiddenHitRate reports how many such documents leaked into the
+// top k anyway.
+type MetricsSet struct {
+	Recall      float64 `json:"recall"`
+	NDCG        float64 `json:"ndcg"`
+	MRR         float64 `json:"mrr"`
+	MAP         float64 `json:"map"`
+	Precision10 float64 `json:"precision_10"`
+	// ForbiddenHitRate is the fraction of known-irrelevant documents
+	// (labeled with grade <= 0) that appear in the first k results.
+	// Queries without such labels score 0, so it only moves when a
+	// curator explicitly judged a document irrelevant and it surfaced
+	// regardless.
+	ForbiddenHitRate float64 `json:"forbidden_hit_rate"`
+}
+
+// RecallAtK is the fraction of relevant documents that appear in the
+// first k ranked results. Queries without relevant documents score 0.
+func RecallAtK(ranked []string, relevant map[string]float64, k int) float64 {
+	total := countRelevant(relevant)
+	if total == 0 {
+		return 0
+	}
+	found := 0
+	for _, id := range topK(ranked, k) {
+		if relevant[id] > 0 {
+			found++
+		}
+	}
+	return float64(found) / float64(total)
+}
+
+// NDCGAtK is the normalized discounted cumulative gain over the first k
+// results, using the graded labels directly.
+func NDCGAtK(ranked []string, relevant map[string]float64, k int) float64 {
+	dcg := 0.0
+	for i, id := range topK(ranked, k) {
+		if gain := relevant[id]; gain > 0 {
+			dcg += gain / math.Log2(float64(i)+2)
+		}
+	}
+
+	grades := make([]float64, 0, len(relevant))
+	for _, g := range relevant {
+		if g > 0 {
+			grades = append(grades, g)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(grades)))
+	ideal := 0.0
+	for i, g := range grades {
+		if i >= k && k > 0 {
+			break
+		}
+		ideal += g / math.Log2(float64(i)+2)
+	}
+	if ideal == 0 {
+		return 0
+	}
+	return dcg / ideal
+}
+
+// MRR is the reciprocal rank of the first relevant result, 0 when none
+// is ranked.
+func MRR(ranked []string, relevant map[string]float64) float64 {
+	for i, id := range ranked {
+		if relevant[id] > 0 {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// MAPAtK is the average precision over the first k results: precision is
+// sampled at each rank holding a relevant document and averaged over the
+// total number of relevant documents. Graded labels are binarized as
+// grade > 0.
+func MAPAtK(ranked []string, relevant map[string]float64, k int) float64 {
+	total := countRelevant(relevant)
+	if total == 0 {
+		return 0
+	}
+	sum := 0.0
+	found := 0
+	for i, id := range topK(ranked, k) {
+		if relevant[id] > 0 {
+			found++
+			sum += float64(found) / float64(i+1)
+		}
+	}
+	return sum / float64(total)
+}
+
+// PrecisionAtK is the fraction of the first k ranked results that are
+// relevant. The denominator is k even when fewer results were returned,
+// so truncated result lists are penalized.
+func PrecisionAtK(ranked []string, relevant map[string]float64, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+	found := 0
+	for _, id := range topK(ranked, k) {
+		if relevant[id] > 0 {
+			found++
+		}
+	}
+	return float64(found) / float64(k)
+}
+
+// ForbiddenHitRateAtK is the fraction of known-irrelevant documents —
+// labels present in the map with grade <= 0 — that appear in the first
+// k results. 0 when the query carries no such labels.
+func ForbiddenHitRateAtK(ranked []string, relevant map[string]float64, k int) float64 {
+	total := 0
+	for _, g := range relevant {
+		if g <= 0 {
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	leaked := 0
+	for _, id := range topK(ranked, k) {
+		if g, ok := relevant[id]; ok && g <= 0 {
+			leaked++
+		}
+	}
+	return float64(leaked) / float64(total)
+}
+
+// ComputeAll evaluates one query's ranking at cutoff k. Precision is
+// always reported at 10 to stay comparable across runs with different k.
+func ComputeAll(ranked []string, relevant map[string]float64, k int) MetricsSet {
+	return MetricsSet{
+		Recall:           RecallAtK(ranked, relevant, k),
+		NDCG:             NDCGAtK(ranked, relevant, k),
+		MRR:              MRR(ranked, relevant),
+		MAP:              MAPAtK(ranked, relevant, k),
+		Precision10:      PrecisionAtK(ranked, relevant, 10),
+		ForbiddenHitRate: ForbiddenHitRateAtK(ranked, relevant, k),
+	}
+}
+
+// AverageMetrics is the arithmetic mean of per-query metrics; the MAP
+// field of the result is thus mean average precision proper.
+func AverageMetrics(sets []MetricsSet) MetricsSet {
+	if len(sets) == 0 {
+		return MetricsSet{}
+	}
+	var avg MetricsSet
+	for _, m := range sets {
+		avg.Recall += m.Recall
+		avg.NDCG += m.NDCG
+		avg.MRR += m.MRR
+		avg.MAP += m.MAP
+		avg.Precision10 += m.Precision10
+		avg.ForbiddenHitRate += m.ForbiddenHitRate
+	}
+	n := float64(len(sets))
+	avg.Recall /= n
+	avg.NDCG /= n
+	avg.MRR /= n
+	avg.MAP /= n
+	avg.Precision10 /= n
+	avg.ForbiddenHitRate /= n
+	return avg
+}
+
+// topK returns the first k entries, or all of them when k <= 0.
+func topK(ranked []string, k int) []string {
+	if k > 0 && len(ranked) > k {
+		return ranked[:k]
+	}
+	return ranked
+}
+
+// countRelevant counts labels with grade > 0.
+func countRelevant(relevant map[string]float64) int {
+	n := 0
+	for _, g := range relevant {
+		if g > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRecallAtK(t *testing.T) {
+	relevant := map[string]float64{"a": 1, "b": 2, "c": 1}
+	cases := []struct {
+		name   string
+		ranked []string
+		k      int
+		want   float64
+	}{
+		{"all found", []string{"a", "b", "c"}, 3, 1},
+		{"partial", []string{"a", "x", "y"}, 3, 1.0 / 3},
+		{"cutoff", []string{"x", "a", "b"}, 2, 1.0 / 3},
+		{"none", []string{"x", "y"}, 5, 0},
+		{"empty ranking", nil, 5, 0},
+	}
+	for _, c := range cases {
+		if got := RecallAtK(c.ranked, relevant, c.k); !approx(got, c.want) {
+			t.Errorf("%s: RecallAtK = %v, want %v", c.name, got, c.want)
+		}
+	}
+	if got := RecallAtK([]string{"a"}, nil, 5); got != 0 {
+		t.Errorf("no labels: RecallAtK = %v, want 0", got)
+	}
+}
+
+func TestMAPAtK(t *testing.T) {
+	relevant := map[string]float64{"a": 1, "b": 3}
+	cases := []struct {
+		name   string
+		ranked []string
+		k      int
+		want   float64
+	}{
+		{"perfect", []string{"a", "b"}, 10, 1},
+		{"interleaved", []string{"a", "x", "b"}, 10, (1.0 + 2.0/3) / 2},
+		{"cutoff drops one", []string{"a", "x", "b"}, 2, 0.5},
+		{"none", []string{"x", "y"}, 10, 0},
+	}
+	for _, c := range cases {
+		if got := MAPAtK(c.ranked, relevant, c.k); !approx(got, c.want) {
+			t.Errorf("%s: MAPAtK = %v, want %v", c.name, got, c.want)
+		}
+	}
+	// Graded labels binarize: grade 0 is not relevant.
+	graded := map[string]float64{"a": 2, "z": 0}
+	if got := MAPAtK([]string{"z", "a"}, graded, 10); !approx(got, 0.5) {
+		t.Errorf("graded: MAPAtK = %v, want 0.5", got)
+	}
+}
+
+func TestPrecisionAtK(t *testing.T) {
+	relevant := map[string]float64{"a": 1, "b": 1}
+	cases := []struct {
+		name   string
+		ranked []string
+		k      int
+		want   float64
+	}{
+		{"half", []string{"a", "x"}, 2, 0.5},
+		{"short list penalized", []string{"a"}, 10, 0.1},
+		{"k zero", []string{"a"}, 0, 0},
+	}
+	for _, c := range cases {
+		if got := PrecisionAtK(c.ranked, relevant, c.k); !approx(got, c.want) {
+			t.Errorf("%s: PrecisionAtK = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestForbiddenHitRateAtK(t *testing.T) {
+	// b is judged relevant; z and w are known irrelevant.
+	relevant := map[string]float64{"b": 2, "z": 0, "w": -1}
+	cases := []struct {
+		name   string
+		ranked []string
+		k      int
+		want   float64
+	}{
+		{"no leak", []string{"b", "x", "y"}, 3, 0},
+		{"one leaks", []string{"b", "z"}, 3, 0.5},
+		{"both leak", []string{"z", "w"}, 3, 1},
+		{"cutoff hides the leak", []string{"b", "x", "z"}, 2, 0},
+		{"unlabeled docs are not forbidden", []string{"x", "y"}, 3, 0},
+	}
+	for _, c := range cases {
+		if got := ForbiddenHitRateAtK(c.ranked, relevant, c.k); !approx(got, c.want) {
+			t.Errorf("%s: ForbiddenHitRateAtK = %v, want %v", c.name, got, c.want)
+		}
+	}
+	// Queries without known-irrelevant labels always score 0.
+	if got := ForbiddenHitRateAtK([]string{"a"}, map[string]float64{"a": 1}, 3); got != 0 {
+		t.Errorf("no forbidden labels: ForbiddenHitRateAtK = %v, want 0", got)
+	}
+
+	// Grade 0 never counts toward the positive metrics either.
+	m := ComputeAll([]string{"z", "b"}, relevant, 10)
+	if !approx(m.MRR, 0.5) || !approx(m.ForbiddenHitRate, 0.5) {
+		t.Errorf("ComputeAll with a leaked forbidden doc: %+v", m)
+	}
+}
+
+func TestComputeAllAndAverage(t *testing.T) {
+	relevant := map[string]float64{"a": 1}
+	perfect := ComputeAll([]string{"a"}, relevant, 10)
+	if perfect.Recall != 1 || perfect.MRR != 1 || perfect.MAP != 1 {
+		t.Errorf("perfect query: %+v", perfect)
+	}
+	if !approx(perfect.Precision10, 0.1) {
+		t.Errorf("Precision10 = %v, want 0.1", perfect.Precision10)
+	}
+
+	zero := ComputeAll([]string{"x"}, relevant, 10)
+	avg := AverageMetrics([]MetricsSet{perfect, zero})
+	if !approx(avg.Recall, 0.5) || !approx(avg.MAP, 0.5) {
+		t.Errorf("average: %+v", avg)
+	}
+	if got := AverageMetrics(nil); got != (MetricsSet{}) {
+		t.Errorf("empty average = %+v", got)
+	}
+}
+
+func TestReportHTML(t *testing.T) {
+	r := &Report{
+		Dataset:  "smoke",
+		Provider: "simple",
+		K:        10,
+		Queries: []QueryResult{
+			{QueryID: "q1", Query: "first query", Metrics: ComputeAll([]string{"a"}, map[string]float64{"a": 1}, 10)},
+		},
+	}
+	r.Finalize()
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{"MAP@10", "P@10", "first query", "Retrieval evaluation: smoke"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// QueryResult is one evaluated query: the ranking the index produced and
+// the metrics computed against its labels.
+type QueryResult struct {
+	QueryID string     `json:"query_id"`
+	Query   string     `json:"query"`
+	Metrics MetricsSet `json:"metrics"`
+}
+
+// QueryFailure is one labeled query the run could not evaluate: its
+// search errored instead of producing a ranking.
+type QueryFailure struct {
+	QueryID string `json:"query_id"`
+	Query   string `json:"query"`
+	Error   string `json:"error"`
+}
+
+// Report is one evaluation run over a labeled query set. Failures are
+// kept separate from Queries: the summary averages only successful
+// queries, so a systematically failing query must stay visible rather
+// than silently inflating the metrics.
+type Report struct {
+	Dataset     string         `json:"dataset"`
+	Provider    string         `json:"provider"`
+	K           int            `json:"k"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Queries     []QueryResult  `json:"queries"`
+	Failures    []QueryFailure `json:"failures,omitempty"`
+	Summary     MetricsSet     `json:"summary"`
+}
+
+// Finalize computes the summary from the per-query metrics and stamps
+// the generation time.
+func (r *Report) Finalize() {
+	sets := make([]MetricsSet, len(r.Queries))
+	for i := range r.Queries {
+		sets[i] = r.Queries[i].Metrics
+	}
+	r.Summary = AverageMetrics(sets)
+	r.GeneratedAt = time.Now().UTC()
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Retrieval evaluation: {{.Dataset}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th { background: #f0f0f0; }
+td.q { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Retrieval evaluation: {{.Dataset}}</h1>
+<p>Provider {{.Provider}}, cutoff k={{.K}}, {{len .Queries}} queries, generated {{.GeneratedAt.Format "2006-01-02 15:04 UTC"}}.</p>
+<h2>Summary</h2>
+<table>
+<tr><th>Recall@{{.K}}</th><th>nDCG@{{.K}}</th><th>MRR</th><th>MAP@{{.K}}</th><th>P@10</th><th>Forbidden@{{.K}}</th></tr>
+<tr><td>{{printf "%.4f" .Summary.Recall}}</td><td>{{printf "%.4f" .Summary.NDCG}}</td><td>{{printf "%.4f" .Summary.MRR}}</td><td>{{printf "%.4f" .Summary.MAP}}</td><td>{{printf "%.4f" .Summary.Precision10}}</td><td>{{printf "%.4f" .Summary.ForbiddenHitRate}}</td></tr>
+</table>
+<h2>Per query</h2>
+<table>
+<tr><th>Query</th><th>Recall@{{.K}}</th><th>nDCG@{{.K}}</th><th>MRR</th><th>MAP@{{.K}}</th><th>P@10</th><th>Forbidden@{{.K}}</th></tr>
+{{range .Queries}}<tr><td class="q">{{.Query}}</td><td>{{printf "%.4f" .Metrics.Recall}}</td><td>{{printf "%.4f" .Metrics.NDCG}}</td><td>{{printf "%.4f" .Metrics.MRR}}</td><td>{{printf "%.4f" .Metrics.MAP}}</td><td>{{printf "%.4f" .Metrics.Precision10}}</td><td>{{printf "%.4f" .Metrics.ForbiddenHitRate}}</td></tr>
+{{end}}</table>
+{{if .Failures}}<h2>Failed queries ({{len .Failures}})</h2>
+<p>These queries errored and are excluded from the averages above.</p>
+<table>
+<tr><th>Query</th><th>Error</th></tr>
+{{range .Failures}}<tr><td class="q">{{.Query}}</td><td class="q">{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML renders the report as a standalone HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// WriteCSV emits one row per dataset × strategy result with the summary
+// metrics, for pivoting in a spreadsheet. encoding/csv handles quoting,
+// so dataset and strategy names may contain commas.
+func WriteCSV(w io.Writer, results []DatasetResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"dataset", "strategy", "queries", "sampled",
+		"recall", "ndcg", "mrr", "map", "precision_10", "forbidden_hit_rate",
+	}); err != nil {
+		return err
+	}
+	for _, res := range results {
+		s := res.Report.Summary
+		row := []string{
+			res.Dataset,
+			res.Strategy,
+			fmt.Sprintf("%d", len(res.Report.Queries)),
+			fmt.Sprintf("%d", res.SampledQueries),
+			fmt.Sprintf("%.4f", s.Recall),
+			fmt.Sprintf("%.4f", s.NDCG),
+			fmt.Sprintf("%.4f", s.MRR),
+			fmt.Sprintf("%.4f", s.MAP),
+			fmt.Sprintf("%.4f", s.Precision10),
+			fmt.Sprintf("%.4f", s.ForbiddenHitRate),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
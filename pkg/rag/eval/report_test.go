@@ -0,0 +1,46 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	results := []DatasetResult{
+		{
+			Dataset:  "scifact",
+			Strategy: "baseline",
+			Report: &Report{
+				Queries: make([]QueryResult, 3),
+				Summary: MetricsSet{Recall: 0.5, NDCG: 0.4321, MRR: 0.75, MAP: 0.3, Precision10: 0.1},
+			},
+		},
+		{
+			Dataset:        "name, with comma",
+			Strategy:       "cjk",
+			SampledQueries: 2,
+			Report: &Report{
+				Queries: make([]QueryResult, 2),
+			},
+		},
+	}
+
+	var buf strings.Builder
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want header + 2 rows:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "dataset,strategy,queries,sampled,recall,ndcg,mrr,map,precision_10,forbidden_hit_rate" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[1] != "scifact,baseline,3,0,0.5000,0.4321,0.7500,0.3000,0.1000,0.0000" {
+		t.Errorf("row = %q", lines[1])
+	}
+	// Fields containing commas are quoted.
+	if !strings.HasPrefix(lines[2], `"name, with comma",cjk,2,2,`) {
+		t.Errorf("quoted row = %q", lines[2])
+	}
+}
@@ -0,0 +1,327 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+// RunConfig parameterizes one evaluation run: which datasets and
+// strategies to sweep and how to build the indices.
+type RunConfig struct {
+	// DatasetRoot is the directory holding BEIR-layout datasets.
+	DatasetRoot string
+	Datasets    []string
+	Strategies  []Strategy
+	// Base is the service configuration each strategy overlays its knobs
+	// onto (provider selection, embedding settings, ...).
+	Base config.RAGToolsConfig
+	// K is the metric cutoff; 0 means 10.
+	K int
+	// WorkDir is where per-strategy workspaces and indices are built; a
+	// temp directory is created when empty.
+	WorkDir string
+	// SampleQueries, when positive, evaluates only that many labeled
+	// queries per dataset instead of the full set — the first N by query
+	// id, or a seeded random subset when SampleSeed is set. Results carry
+	// SampledQueries so reports cannot pass off sample numbers as
+	// full-dataset ones.
+	SampleQueries int
+	SampleSeed    int64
+	// Parallelism bounds how many strategies evaluate concurrently per
+	// dataset; each builds into its own workspace, so they are
+	// independent. Values below 2 keep the serial behavior.
+	Parallelism int
+	// Log receives progress lines; nil discards them.
+	Log func(format string, args ...any)
+}
+
+// DatasetResult is the outcome of evaluating one strategy on one dataset.
+type DatasetResult struct {
+	Dataset  string  `json:"dataset"`
+	Strategy string  `json:"strategy"`
+	Report   *Report `json:"report"`
+	// SampledQueries is how many queries were evaluated when only a
+	// sample ran; 0 means the full labeled set.
+	SampledQueries int `json:"sampled_queries,omitempty"`
+}
+
+// Runner executes dataset × strategy evaluations. Built services are
+// cached by workspace path, so evaluating several datasets against the
+// same prepared workspace reuses the index instead of rebuilding it.
+type Runner struct {
+	cfg RunConfig
+
+	mu       sync.Mutex
+	services map[string]*rag.Service
+}
+
+// NewRunner creates a runner; the zero K defaults to 10.
+func NewRunner(cfg RunConfig) *Runner {
+	if cfg.K <= 0 {
+		cfg.K = 10
+	}
+	return &Runner{cfg: cfg, services: make(map[string]*rag.Service)}
+}
+
+// Close releases every cached service.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var firstErr error
+	for _, svc := range r.services {
+		if err := svc.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.services = make(map[string]*rag.Service)
+	return firstErr
+}
+
+func (r *Runner) logf(format string, args ...any) {
+	if r.cfg.Log != nil {
+		r.cfg.Log(format, args...)
+	}
+}
+
+// Run evaluates every dataset × strategy combination and returns the
+// results in dataset-major, strategy order.
+func (r *Runner) Run(ctx context.Context) ([]DatasetResult, error) {
+	if r.cfg.WorkDir == "" {
+		dir, err := os.MkdirTemp("", "rageval-")
+		if err != nil {
+			return nil, err
+		}
+		r.cfg.WorkDir = dir
+	}
+	var out []DatasetResult
+	for _, name := range r.cfg.Datasets {
+		ds, err := LoadBEIRDataset(r.cfg.DatasetRoot, name)
+		if err != nil {
+			return nil, err
+		}
+		results, err := r.runStrategies(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, results...)
+	}
+	return out, nil
+}
+
+// runStrategies evaluates every strategy over one dataset, serially or on
+// a bounded worker pool. Each worker writes only its own result slot, so
+// the returned slice keeps the configured strategy order either way; the
+// first failure cancels in-flight runs.
+func (r *Runner) runStrategies(ctx context.Context, ds *Dataset) ([]DatasetResult, error) {
+	strats := r.cfg.Strategies
+	results := make([]DatasetResult, len(strats))
+	if r.cfg.Parallelism <= 1 {
+		for i, strat := range strats {
+			res, err := r.runSingle(ctx, ds, strat)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", ds.Name, strat.Name, err)
+			}
+			results[i] = *res
+		}
+		return results, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(strats))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < r.cfg.Parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res, err := r.runSingle(ctx, ds, strats[i])
+				if err != nil {
+					errs[i] = err
+					cancel()
+					continue
+				}
+				results[i] = *res
+			}
+		}()
+	}
+	for i := range strats {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", ds.Name, strats[i].Name, err)
+		}
+	}
+	return results, nil
+}
+
+// runSingle builds (or reuses) one strategy's index over a dataset and
+// evaluates the selected labeled queries against it.
+func (r *Runner) runSingle(ctx context.Context, ds *Dataset, strat Strategy) (*DatasetResult, error) {
+	workspace := filepath.Join(r.cfg.WorkDir, ds.Name, strat.Name)
+	docByPath, err := prepareWorkspace(workspace, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	svc := r.services[workspace]
+	r.mu.Unlock()
+	if svc == nil {
+		svc, err = rag.NewService(workspace, strat.Config(r.cfg.Base))
+		if err != nil {
+			return nil, err
+		}
+		r.logf("%s/%s: building index over %d documents", ds.Name, strat.Name, len(ds.Docs))
+		if _, err := svc.BuildIndex(ctx); err != nil {
+			svc.Close()
+			return nil, fmt.Errorf("building index: %w", err)
+		}
+		r.mu.Lock()
+		r.services[workspace] = svc
+		r.mu.Unlock()
+	}
+
+	qids, sampled := r.selectQueries(ds)
+	report := &Report{Dataset: ds.Name, Provider: providerName(r.cfg.Base), K: r.cfg.K}
+	for _, qid := range qids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ranked, err := rankedDocs(ctx, svc, ds.Queries[qid], r.cfg.K, docByPath)
+		if err != nil {
+			// A dead context aborts the run; a per-query error is recorded
+			// so it stays visible instead of silently skewing the averages.
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("query %s: %w", qid, err)
+			}
+			report.Failures = append(report.Failures, QueryFailure{
+				QueryID: qid,
+				Query:   ds.Queries[qid],
+				Error:   err.Error(),
+			})
+			continue
+		}
+		report.Queries = append(report.Queries, QueryResult{
+			QueryID: qid,
+			Query:   ds.Queries[qid],
+			Metrics: ComputeAll(ranked, ds.Qrels[qid], r.cfg.K),
+		})
+	}
+	report.Finalize()
+	r.logf("%s/%s: %d queries (%d failed), nDCG@%d %.4f",
+		ds.Name, strat.Name, len(qids), len(report.Failures), r.cfg.K, report.Summary.NDCG)
+	return &DatasetResult{
+		Dataset:        ds.Name,
+		Strategy:       strat.Name,
+		Report:         report,
+		SampledQueries: sampled,
+	}, nil
+}
+
+// selectQueries returns the labeled query ids to evaluate, sorted, plus
+// the sample size when only a subset was selected (0 = full set).
+func (r *Runner) selectQueries(ds *Dataset) ([]string, int) {
+	ids := make([]string, 0, len(ds.Qrels))
+	for qid := range ds.Qrels {
+		if ds.Queries[qid] != "" {
+			ids = append(ids, qid)
+		}
+	}
+	sort.Strings(ids)
+	n := r.cfg.SampleQueries
+	if n <= 0 || n >= len(ids) {
+		return ids, 0
+	}
+	if r.cfg.SampleSeed != 0 {
+		rng := rand.New(rand.NewSource(r.cfg.SampleSeed))
+		rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	}
+	ids = ids[:n]
+	sort.Strings(ids)
+	return ids, n
+}
+
+// rankedDocs runs one query and maps the hits back to dataset doc ids in
+// rank order. PerSourceCap 1 makes each hit a distinct document, which is
+// the granularity the relevance labels speak.
+func rankedDocs(ctx context.Context, svc *rag.Service, query string, k int, docByPath map[string]string) ([]string, error) {
+	result, err := svc.Search(ctx, rag.SearchRequest{Query: query, TopK: k, PerSourceCap: 1})
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		if id, ok := docByPath[item.Ref.SourcePath]; ok {
+			ranked = append(ranked, id)
+		}
+	}
+	return ranked, nil
+}
+
+// prepareWorkspace writes the corpus into workspace/knowledge, one
+// markdown file per document in sorted doc-id order so index ids come out
+// identical everywhere, and returns the doc id behind each KB-relative
+// path. An already-populated workspace is reused as-is.
+func prepareWorkspace(workspace string, ds *Dataset) (map[string]string, error) {
+	kb := filepath.Join(workspace, "knowledge")
+	byPath := make(map[string]string, len(ds.Docs))
+	ids := make([]string, 0, len(ds.Docs))
+	for id := range ds.Docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	populated := false
+	if _, err := os.Stat(kb); err == nil {
+		populated = true
+	}
+	if err := os.MkdirAll(kb, 0o755); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		rel := docFileName(id)
+		byPath[rel] = id
+		if populated {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(kb, rel), []byte(ds.Docs[id]), 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return byPath, nil
+}
+
+// docFileName maps a dataset doc id onto a flat markdown filename,
+// replacing path-hostile characters.
+func docFileName(id string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':':
+			return '_'
+		}
+		return r
+	}, id)
+	return safe + ".md"
+}
+
+// providerName resolves the provider label for reports.
+func providerName(cfg config.RAGToolsConfig) string {
+	if cfg.IndexProvider == "" {
+		return "simple"
+	}
+	return cfg.IndexProvider
+}
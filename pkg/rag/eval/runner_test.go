@@ -0,0 +1,248 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// writeRunnerFixture writes a BEIR-layout dataset with enough signal for
+// end-to-end retrieval: each query names its relevant document's topic.
+func writeRunnerFixture(t *testing.T, root, name string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "qrels"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	corpus := `{"_id": "d1", "title": "Rollout", "text": "The deployment rollout checklist covers canary and rollback steps."}` + "\n" +
+		`{"_id": "d2", "title": "Billing", "text": "Invoice reconciliation happens monthly in the billing service."}` + "\n" +
+		`{"_id": "d3", "title": "Lunch", "text": "Sandwiches and coffee were discussed at length."}` + "\n"
+	queries := `{"_id": "q1", "text": "deployment rollout checklist"}` + "\n" +
+		`{"_id": "q2", "text": "invoice reconciliation billing"}` + "\n" +
+		`{"_id": "q3", "text": "quarterly revenue forecast"}` + "\n"
+	qrels := "query-id\tcorpus-id\tscore\nq1\td1\t1\nq2\td2\t1\nq3\td2\t1\n"
+	for file, content := range map[string]string{
+		"corpus.jsonl":   corpus,
+		"queries.jsonl":  queries,
+		"qrels/test.tsv": qrels,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(file)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	root := t.TempDir()
+	writeRunnerFixture(t, root, "tiny")
+
+	runner := NewRunner(RunConfig{
+		DatasetRoot: root,
+		Datasets:    []string{"tiny"},
+		Strategies:  []Strategy{{Name: "default"}, {Name: "small-chunks", SoftLimit: 400, HardLimit: 800}},
+		Base:        config.RAGToolsConfig{IndexProvider: "simple"},
+		WorkDir:     t.TempDir(),
+	})
+	defer runner.Close()
+
+	results, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, want := range []string{"default", "small-chunks"} {
+		if results[i].Dataset != "tiny" || results[i].Strategy != want {
+			t.Errorf("result %d = %s/%s, want tiny/%s", i, results[i].Dataset, results[i].Strategy, want)
+		}
+		if results[i].SampledQueries != 0 {
+			t.Errorf("full run recorded a sample: %d", results[i].SampledQueries)
+		}
+		if n := len(results[i].Report.Queries); n != 3 {
+			t.Errorf("result %d evaluated %d queries, want 3", i, n)
+		}
+	}
+	// q1 and q2 rank their relevant doc first; q3 has no lexical overlap.
+	summary := results[0].Report.Summary
+	if summary.MRR < 0.6 || summary.MRR > 0.7 {
+		t.Errorf("MRR = %v, want 2/3", summary.MRR)
+	}
+}
+
+func TestRunnerSampling(t *testing.T) {
+	root := t.TempDir()
+	writeRunnerFixture(t, root, "tiny")
+	ds, err := LoadBEIRDataset(root, "tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First-N sampling is the sorted-id prefix.
+	r := NewRunner(RunConfig{SampleQueries: 2})
+	ids, sampled := r.selectQueries(ds)
+	if sampled != 2 || !reflect.DeepEqual(ids, []string{"q1", "q2"}) {
+		t.Errorf("prefix sample = %v (%d), want [q1 q2]", ids, sampled)
+	}
+
+	// A seeded sample is stable across calls and differs by seed space,
+	// not call order.
+	r = NewRunner(RunConfig{SampleQueries: 2, SampleSeed: 42})
+	seeded, sampled := r.selectQueries(ds)
+	if sampled != 2 || len(seeded) != 2 {
+		t.Fatalf("seeded sample = %v (%d)", seeded, sampled)
+	}
+	again, _ := NewRunner(RunConfig{SampleQueries: 2, SampleSeed: 42}).selectQueries(ds)
+	if !reflect.DeepEqual(seeded, again) {
+		t.Errorf("seeded sample not reproducible: %v vs %v", seeded, again)
+	}
+
+	// Asking for at least the full set means no sampling is recorded.
+	r = NewRunner(RunConfig{SampleQueries: 10})
+	ids, sampled = r.selectQueries(ds)
+	if sampled != 0 || len(ids) != 3 {
+		t.Errorf("oversized sample = %v (%d), want full set", ids, sampled)
+	}
+
+	// End to end: the result records the sample size.
+	runner := NewRunner(RunConfig{
+		DatasetRoot:   root,
+		Datasets:      []string{"tiny"},
+		Strategies:    []Strategy{{Name: "default"}},
+		WorkDir:       t.TempDir(),
+		SampleQueries: 2,
+	})
+	defer runner.Close()
+	results, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].SampledQueries != 2 || len(results[0].Report.Queries) != 2 {
+		t.Errorf("sampled run = %d queries, SampledQueries %d, want 2/2",
+			len(results[0].Report.Queries), results[0].SampledQueries)
+	}
+}
+
+func TestDocFileName(t *testing.T) {
+	cases := map[string]string{
+		"d1":        "d1.md",
+		"MED-10":    "MED-10.md",
+		"a/b:c\\d3": "a_b_c_d3.md",
+	}
+	for id, want := range cases {
+		if got := docFileName(id); got != want {
+			t.Errorf("docFileName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestRunnerParallel(t *testing.T) {
+	root := t.TempDir()
+	writeRunnerFixture(t, root, "tiny")
+
+	run := func(parallelism int) []DatasetResult {
+		t.Helper()
+		runner := NewRunner(RunConfig{
+			DatasetRoot: root,
+			Datasets:    []string{"tiny"},
+			Strategies:  DefaultStrategies,
+			WorkDir:     t.TempDir(),
+			Parallelism: parallelism,
+		})
+		defer runner.Close()
+		results, err := runner.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run(parallelism=%d): %v", parallelism, err)
+		}
+		// Timestamps differ between runs; everything else must not.
+		for i := range results {
+			results[i].Report.GeneratedAt = time.Time{}
+		}
+		return results
+	}
+
+	serial := run(1)
+	parallel := run(3)
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("parallel results differ from serial:\n%+v\nvs\n%+v", serial, parallel)
+	}
+	for i, s := range DefaultStrategies {
+		if parallel[i].Strategy != s.Name {
+			t.Errorf("result %d = %s, want %s (order not preserved)", i, parallel[i].Strategy, s.Name)
+		}
+	}
+
+	// A canceled context aborts the run instead of producing results.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := NewRunner(RunConfig{
+		DatasetRoot: root,
+		Datasets:    []string{"tiny"},
+		Strategies:  DefaultStrategies,
+		WorkDir:     t.TempDir(),
+		Parallelism: 2,
+	})
+	defer runner.Close()
+	if _, err := runner.Run(ctx); err == nil {
+		t.Error("expected an error from a canceled context")
+	}
+}
+
+func TestRunnerRecordsQueryFailures(t *testing.T) {
+	root := t.TempDir()
+	writeRunnerFixture(t, root, "tiny")
+	// q4 is stopword-only, so the service rejects it at validation time
+	// instead of producing a ranking.
+	dir := filepath.Join(root, "tiny")
+	queries := `{"_id": "q1", "text": "deployment rollout checklist"}` + "\n" +
+		`{"_id": "q4", "text": "of the and with"}` + "\n"
+	qrels := "query-id\tcorpus-id\tscore\nq1\td1\t1\nq4\td2\t1\n"
+	if err := os.WriteFile(filepath.Join(dir, "queries.jsonl"), []byte(queries), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "qrels", "test.tsv"), []byte(qrels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRunner(RunConfig{
+		DatasetRoot: root,
+		Datasets:    []string{"tiny"},
+		Strategies:  []Strategy{{Name: "default"}},
+		WorkDir:     t.TempDir(),
+	})
+	defer runner.Close()
+	results, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	report := results[0].Report
+	if len(report.Queries) != 1 || report.Queries[0].QueryID != "q1" {
+		t.Errorf("successful queries = %+v, want only q1", report.Queries)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].QueryID != "q4" {
+		t.Fatalf("failures = %+v, want only q4", report.Failures)
+	}
+	if report.Failures[0].Error == "" {
+		t.Error("failure is missing its error message")
+	}
+	// The average covers only the successful query, which ranks its
+	// relevant document first.
+	if report.Summary.MRR != 1 {
+		t.Errorf("summary MRR = %v, want 1 over the single successful query", report.Summary.MRR)
+	}
+
+	var html strings.Builder
+	if err := report.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "Failed queries (1)") {
+		t.Error("HTML report is missing the failed-queries section")
+	}
+}
@@ -0,0 +1,66 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+// Strategy is one indexing configuration under evaluation: which chunker
+// to use and with what knobs. Comparing strategies over the same dataset
+// is the harness's main job.
+type Strategy struct {
+	Name string `json:"name"`
+	// Chunker selects the splitter: "markdown" (default) or "semantic".
+	Chunker   string `json:"chunker,omitempty"`
+	SoftLimit int    `json:"soft_limit,omitempty"`
+	HardLimit int    `json:"hard_limit,omitempty"`
+	// AttachHeadings is the markdown chunker's heading-carry behavior.
+	AttachHeadings bool `json:"attach_headings,omitempty"`
+	// SimilarityThreshold is the semantic chunker's break point; zero
+	// takes its default.
+	SimilarityThreshold float64 `json:"similarity_threshold,omitempty"`
+	// BM25K1 and BM25B tune the comet provider's lexical scoring for this
+	// strategy; zero means the standard 1.2 / 0.75.
+	BM25K1 float64 `json:"bm25_k1,omitempty"`
+	BM25B  float64 `json:"bm25_b,omitempty"`
+}
+
+// Config maps the strategy onto a service configuration, overriding only
+// the knobs the strategy controls.
+func (s Strategy) Config(base config.RAGToolsConfig) config.RAGToolsConfig {
+	base.ChunkSoftLimit = s.SoftLimit
+	base.ChunkHardLimit = s.HardLimit
+	base.AttachHeadings = s.AttachHeadings
+	base.BM25K1 = s.BM25K1
+	base.BM25B = s.BM25B
+	return base
+}
+
+// DefaultStrategies is the standard sweep for comparing indexing
+// configurations over a dataset: the production defaults, chunk-size
+// variants and a BM25 tuning with reduced length normalization.
+var DefaultStrategies = []Strategy{
+	{Name: "default"},
+	{Name: "small-chunks", SoftLimit: 400, HardLimit: 800},
+	{Name: "attach-headings", AttachHeadings: true},
+	{Name: "bm25-low-b", BM25K1: 1.5, BM25B: 0.4},
+}
+
+// ResolveChunker constructs the strategy's chunker. The embedder is only
+// required by embedding-based chunkers; pass nil when the strategy does
+// not need one.
+func ResolveChunker(s Strategy, embedder rag.Embedder) (rag.Chunker, error) {
+	switch s.Chunker {
+	case "", "markdown":
+		return rag.NewMarkdownChunker(s.SoftLimit, s.HardLimit, s.AttachHeadings), nil
+	case "semantic":
+		if embedder == nil {
+			return nil, fmt.Errorf("strategy %s: semantic chunker requires an embedder", s.Name)
+		}
+		return rag.NewSemanticChunker(s.SoftLimit, s.HardLimit, s.SimilarityThreshold, embedder), nil
+	default:
+		return nil, fmt.Errorf("strategy %s: unknown chunker %q", s.Name, s.Chunker)
+	}
+}
@@ -0,0 +1,64 @@
+package eval
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestResolveChunker(t *testing.T) {
+	if _, err := ResolveChunker(Strategy{Name: "default"}, nil); err != nil {
+		t.Errorf("default chunker: %v", err)
+	}
+	if _, err := ResolveChunker(Strategy{Name: "md", Chunker: "markdown"}, nil); err != nil {
+		t.Errorf("markdown chunker: %v", err)
+	}
+	if _, err := ResolveChunker(Strategy{Name: "sem", Chunker: "semantic"}, nil); err == nil {
+		t.Error("semantic without embedder should fail")
+	}
+	if _, err := ResolveChunker(Strategy{Name: "bogus", Chunker: "agglomerative"}, nil); err == nil {
+		t.Error("unknown chunker should fail")
+	}
+
+	chunker, err := ResolveChunker(Strategy{Name: "md", SoftLimit: 100, HardLimit: 200}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := chunker.Chunk(context.Background(), "# Title\n\nSome body text.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) == 0 {
+		t.Error("resolved chunker produced no chunks")
+	}
+}
+
+func TestStrategyConfig(t *testing.T) {
+	base := config.RAGToolsConfig{IndexProvider: "comet", KBRoot: "kb"}
+	cfg := Strategy{Name: "tuned", SoftLimit: 400, HardLimit: 800, BM25K1: 1.5, BM25B: 0.4}.Config(base)
+	if cfg.IndexProvider != "comet" || cfg.KBRoot != "kb" {
+		t.Errorf("base config fields lost: %+v", cfg)
+	}
+	if cfg.ChunkSoftLimit != 400 || cfg.ChunkHardLimit != 800 {
+		t.Errorf("chunk limits = %d/%d", cfg.ChunkSoftLimit, cfg.ChunkHardLimit)
+	}
+	if cfg.BM25K1 != 1.5 || cfg.BM25B != 0.4 {
+		t.Errorf("bm25 params = %g/%g", cfg.BM25K1, cfg.BM25B)
+	}
+}
+
+func TestDefaultStrategies(t *testing.T) {
+	tuned := false
+	for _, s := range DefaultStrategies {
+		if _, err := ResolveChunker(s, nil); err != nil {
+			t.Errorf("strategy %s does not resolve: %v", s.Name, err)
+		}
+		if s.BM25K1 != 0 || s.BM25B != 0 {
+			tuned = true
+		}
+	}
+	if !tuned {
+		t.Error("no default strategy exercises non-default BM25 parameters")
+	}
+}
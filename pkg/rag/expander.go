@@ -0,0 +1,67 @@
+package rag
+
+import (
+	"context"
+	"strings"
+)
+
+// QueryExpander rewrites a query before retrieval, typically to add
+// synonyms or spell out acronyms ("k8s" → "kubernetes") for
+// recall-sensitive corpora. Implementations must be safe for concurrent
+// use.
+type QueryExpander interface {
+	Expand(ctx context.Context, query string) (string, error)
+}
+
+// WithQueryExpander installs a query expander. Service.Search passes the
+// expanded query to the provider and notes the rewrite in the result.
+func WithQueryExpander(e QueryExpander) ServiceOption {
+	return func(s *Service) {
+		s.expander = e
+	}
+}
+
+// SynonymExpander is a static QueryExpander backed by a token → expansion
+// map, so deployments can ship a synonym table without an LLM. Expansions
+// are appended to the query rather than replacing the matched token: both
+// forms stay searchable.
+type SynonymExpander struct {
+	synonyms map[string]string
+}
+
+// NewSynonymExpander builds an expander from a synonym table. Keys are
+// matched against lowercased query tokens.
+func NewSynonymExpander(synonyms map[string]string) *SynonymExpander {
+	norm := make(map[string]string, len(synonyms))
+	for k, v := range synonyms {
+		norm[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return &SynonymExpander{synonyms: norm}
+}
+
+// Expand appends the expansion of every matched query token, deduplicated
+// against tokens already present.
+func (e *SynonymExpander) Expand(_ context.Context, query string) (string, error) {
+	tokens := tokenize(query)
+	seen := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		seen[t] = true
+	}
+	var extra []string
+	for _, t := range tokens {
+		expansion, ok := e.synonyms[t]
+		if !ok {
+			continue
+		}
+		for _, et := range tokenize(expansion) {
+			if !seen[et] {
+				seen[et] = true
+				extra = append(extra, et)
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return query, nil
+	}
+	return query + " " + strings.Join(extra, " "), nil
+}
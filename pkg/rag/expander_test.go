@@ -0,0 +1,87 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSynonymExpander(t *testing.T) {
+	e := NewSynonymExpander(map[string]string{
+		"k8s": "kubernetes",
+		"DB":  "database postgres",
+	})
+
+	got, err := e.Expand(context.Background(), "k8s upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "k8s upgrade kubernetes" {
+		t.Errorf("expanded = %q", got)
+	}
+
+	// Keys match case-insensitively; tokens already present are not
+	// appended twice.
+	got, _ = e.Expand(context.Background(), "db postgres outage")
+	if got != "db postgres outage database" {
+		t.Errorf("expanded = %q", got)
+	}
+
+	// No matches leave the query untouched.
+	got, _ = e.Expand(context.Background(), "release notes")
+	if got != "release notes" {
+		t.Errorf("expanded = %q", got)
+	}
+}
+
+func TestSearchQueryExpansion(t *testing.T) {
+	workspace := t.TempDir()
+	full := filepath.Join(workspace, "knowledge", "infra.md")
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := doc("Infra", "2026-01-01", "The kubernetes cluster upgrade plan.")
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := NewService(workspace, config.RAGToolsConfig{
+		IndexProvider: "simple",
+		QuerySynonyms: map[string]string{"k8s": "kubernetes"},
+	})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	// "k8s" alone matches nothing in the corpus; the expansion does.
+	result, err := svc.Search(context.Background(), SearchRequest{Query: "k8s upgrade"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Items) == 0 {
+		t.Fatal("expanded query found no hits")
+	}
+	var noted bool
+	for _, n := range result.Notes {
+		if strings.Contains(n, "query expanded") && strings.Contains(n, "k8s upgrade kubernetes") {
+			noted = true
+		}
+	}
+	if !noted {
+		t.Errorf("no expansion note in %v", result.Notes)
+	}
+
+	// An expansion cannot rescue an empty query: the guard still runs on
+	// the expanded form.
+	if _, err := svc.Search(context.Background(), SearchRequest{Query: "!!"}); err == nil {
+		t.Error("token-free query should still be rejected")
+	}
+}
@@ -0,0 +1,206 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Verdicts a chunk can receive from ExplainDocument.
+const (
+	VerdictReturned      = "returned"
+	VerdictNotRetrieved  = "not_retrieved"
+	VerdictFiltered      = "filtered"
+	VerdictBelowMinScore = "below_min_score"
+	VerdictSourceCapped  = "per_source_capped"
+	VerdictDocTypeCapped = "doc_type_capped"
+	VerdictOutranked     = "outranked"
+)
+
+// ChunkExplanation reports how one chunk of the targeted document fared
+// in the scoring pipeline for a query.
+type ChunkExplanation struct {
+	Ref ChunkRef `json:"ref"`
+	// Retrieved reports whether the provider returned the chunk as a
+	// candidate at all; raw scores are only meaningful when it did.
+	Retrieved     bool           `json:"retrieved"`
+	FailedFilters []string       `json:"failed_filters,omitempty"`
+	Rank          int            `json:"rank,omitempty"`
+	Breakdown     ScoreBreakdown `json:"breakdown"`
+	Verdict       string         `json:"verdict"`
+}
+
+// DocumentExplanation is the full "why not" report for one document.
+type DocumentExplanation struct {
+	Query      string             `json:"query"`
+	SourcePath string             `json:"source_path"`
+	ProfileID  string             `json:"profile_id"`
+	Mode       string             `json:"mode"`
+	TopK       int                `json:"top_k"`
+	Chunks     []ChunkExplanation `json:"chunks"`
+	Notes      []string           `json:"notes,omitempty"`
+}
+
+// ExplainDocument replays the scoring pipeline for the request and
+// reports, per chunk of the targeted document, its raw signals, which
+// filters it failed and why it did or did not make the top-k. It answers
+// "why isn't my doc showing up" concretely instead of by guesswork.
+func (s *Service) ExplainDocument(ctx context.Context, req SearchRequest, sourcePath string) (*DocumentExplanation, error) {
+	if len(s.tokenizer(req.Query)) == 0 {
+		return nil, fmt.Errorf("query has no searchable tokens")
+	}
+	if err := s.checkProviderMatch(); err != nil {
+		return nil, err
+	}
+
+	release, err := s.beginQueued()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	profile, err := resolveProfile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	topK := s.clampTopK(req.TopK)
+
+	targets, err := s.documentChunks(ctx, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []string
+	mode := req.Mode
+	if mode == "" {
+		mode = profile.Mode
+	}
+	if (mode == "semantic" || mode == "hybrid") && !s.provider.Capabilities().Semantic {
+		notes = append(notes, fmt.Sprintf("mode %q unavailable (no embedder); degraded to keyword", mode))
+		mode = "keyword"
+	}
+
+	candidates, err := s.provider.Search(ctx, req.Query, ProviderSearchOptions{
+		TopN: maxProviderCandidates,
+		Mode: mode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refTime := s.searchRefTime(ctx)
+
+	byRef := make(map[ChunkRef]Candidate, len(candidates))
+	filtered := candidates[:0:0]
+	for _, c := range candidates {
+		byRef[c.Chunk.Ref()] = c
+		if s.passesFilters(&c.Chunk, req.Filters, refTime) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	items := s.scoreCandidates(req.Query, filtered, profile, refTime, req.Highlight)
+
+	// Replay the selection loop from runProfile, but classify every scored
+	// item instead of stopping at top-k.
+	docTypeCap := profile.DocTypeCap
+	if req.DocTypeDiversity > 0 {
+		docTypeCap = req.DocTypeDiversity
+	}
+	perSourceCap := perSourceCapFor(req, profile)
+	rankOf := make(map[ChunkRef]int, len(items))
+	breakdownOf := make(map[ChunkRef]ScoreBreakdown, len(items))
+	verdictOf := make(map[ChunkRef]string, len(items))
+	perSource := make(map[string]int)
+	perType := make(map[string]int)
+	var typeCapped []EvidenceItemFull
+	selected := 0
+	for i, item := range items {
+		rankOf[item.Ref] = i + 1
+		breakdownOf[item.Ref] = item.Breakdown
+		switch {
+		case item.Breakdown.FinalScore < profile.MinScore:
+			verdictOf[item.Ref] = VerdictBelowMinScore
+		case perSourceCap > 0 && perSource[item.Ref.SourcePath] >= perSourceCap:
+			verdictOf[item.Ref] = VerdictSourceCapped
+		case docTypeCap > 0 && perType[docTypeKey(item.DocType)] >= docTypeCap:
+			verdictOf[item.Ref] = VerdictDocTypeCapped
+			typeCapped = append(typeCapped, item)
+		case selected >= topK:
+			verdictOf[item.Ref] = VerdictOutranked
+		default:
+			perSource[item.Ref.SourcePath]++
+			perType[docTypeKey(item.DocType)]++
+			selected++
+			verdictOf[item.Ref] = VerdictReturned
+		}
+	}
+	// Mirror the soft refill of the doc-type cap: capped hits regain a
+	// slot when the first pass left top-k unfilled.
+	for _, item := range typeCapped {
+		if selected >= topK {
+			break
+		}
+		if perSourceCap > 0 && perSource[item.Ref.SourcePath] >= perSourceCap {
+			continue
+		}
+		perSource[item.Ref.SourcePath]++
+		selected++
+		verdictOf[item.Ref] = VerdictReturned
+	}
+
+	out := &DocumentExplanation{
+		Query:      req.Query,
+		SourcePath: sourcePath,
+		ProfileID:  profile.ID,
+		Mode:       mode,
+		TopK:       topK,
+		Notes:      notes,
+	}
+	for i := range targets {
+		chunk := &targets[i]
+		ref := chunk.Ref()
+		ce := ChunkExplanation{
+			Ref:           ref,
+			FailedFilters: filterFailures(chunk, req.Filters, refTime, s.confRank),
+		}
+		cand, retrieved := byRef[ref]
+		ce.Retrieved = retrieved
+		switch {
+		case !retrieved:
+			ce.Verdict = VerdictNotRetrieved
+		case len(ce.FailedFilters) > 0:
+			// Raw signals are still informative even though the chunk was
+			// excluded before normalization.
+			ce.Breakdown.RawBM25 = cand.RawBM25
+			ce.Breakdown.RawCosine = cand.RawCosine
+			ce.Verdict = VerdictFiltered
+		default:
+			ce.Rank = rankOf[ref]
+			ce.Breakdown = breakdownOf[ref]
+			ce.Verdict = verdictOf[ref]
+		}
+		out.Chunks = append(out.Chunks, ce)
+	}
+	return out, nil
+}
+
+// documentChunks fetches every chunk of one document by walking ordinals
+// until the provider reports the end.
+func (s *Service) documentChunks(ctx context.Context, sourcePath string) ([]IndexedChunk, error) {
+	var chunks []IndexedChunk
+	for ordinal := 1; ; ordinal++ {
+		chunk, err := s.provider.FetchChunk(ctx, sourcePath, ordinal)
+		if err != nil {
+			if errors.Is(err, ErrChunkNotFound) {
+				break
+			}
+			return nil, err
+		}
+		chunks = append(chunks, *chunk)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("document %s is not in the index", sourcePath)
+	}
+	return chunks, nil
+}
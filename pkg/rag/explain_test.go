@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplainDocumentVerdicts(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"deploy.md": doc("Deploy Guide", "2026-01-01", "The deployment checklist covers rollback and canary steps."),
+		"lunch.md":  doc("Lunch Notes", "2026-01-02", "We discussed sandwiches and coffee."),
+	})
+	ctx := context.Background()
+
+	explanation, err := svc.ExplainDocument(ctx, SearchRequest{Query: "deployment checklist"}, "deploy.md")
+	if err != nil {
+		t.Fatalf("ExplainDocument: %v", err)
+	}
+	if len(explanation.Chunks) != 1 {
+		t.Fatalf("chunks = %d, want 1", len(explanation.Chunks))
+	}
+	ce := explanation.Chunks[0]
+	if ce.Verdict != VerdictReturned {
+		t.Errorf("verdict = %s, want %s", ce.Verdict, VerdictReturned)
+	}
+	if ce.Rank != 1 {
+		t.Errorf("rank = %d, want 1", ce.Rank)
+	}
+	if !ce.Retrieved || ce.Breakdown.RawBM25 <= 0 {
+		t.Errorf("expected retrieved chunk with a lexical score, got %+v", ce)
+	}
+
+	explanation, err = svc.ExplainDocument(ctx, SearchRequest{Query: "deployment checklist"}, "lunch.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := explanation.Chunks[0].Verdict; got != VerdictNotRetrieved {
+		t.Errorf("verdict = %s, want %s", got, VerdictNotRetrieved)
+	}
+}
+
+func TestExplainDocumentFiltered(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"deploy.md": doc("Deploy Guide", "2026-01-01", "The deployment checklist covers rollback and canary steps."),
+	})
+
+	explanation, err := svc.ExplainDocument(context.Background(), SearchRequest{
+		Query:   "deployment checklist",
+		Filters: SearchFilters{DocTypes: []string{"runbook"}},
+	}, "deploy.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ce := explanation.Chunks[0]
+	if ce.Verdict != VerdictFiltered {
+		t.Errorf("verdict = %s, want %s", ce.Verdict, VerdictFiltered)
+	}
+	var hasDocType bool
+	for _, f := range ce.FailedFilters {
+		if f == "doc_type" {
+			hasDocType = true
+		}
+	}
+	if !hasDocType {
+		t.Errorf("failed filters = %v, want doc_type", ce.FailedFilters)
+	}
+}
+
+func TestExplainDocumentUnknownPath(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "content"),
+	})
+	if _, err := svc.ExplainDocument(context.Background(), SearchRequest{Query: "content"}, "missing.md"); err == nil {
+		t.Fatal("expected error for unindexed document")
+	}
+}
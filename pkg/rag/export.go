@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// chunkIterator is implemented by providers that can stream their chunks
+// in id order without materializing the whole set.
+type chunkIterator interface {
+	ForEachChunk(fn func(id int, c IndexedChunk) error) error
+}
+
+// vectorLoader is implemented by providers that store embedding vectors
+// and can hand them out id-ordered, parallel to the chunk ids.
+type vectorLoader interface {
+	LoadChunkVectors() ([][]float32, error)
+}
+
+// exportRecord is one JSON-lines export row: the chunk with all its
+// metadata, plus the optional base64-encoded embedding.
+type exportRecord struct {
+	IndexedChunk
+	// VectorBase64 is the chunk's embedding as little-endian float32
+	// bytes in base64, present only when vectors were requested.
+	VectorBase64 string `json:"vector_base64,omitempty"`
+}
+
+// ExportChunks streams every indexed chunk to w as one JSON object per
+// line, for loading into external tooling (pandas, jq, ...). Chunks are
+// exported as stored, including security annotations. Embedding vectors
+// are omitted unless withVectors is set, in which case each line carries
+// a vector_base64 field; providers that keep no vectors reject the
+// request.
+func (s *Service) ExportChunks(ctx context.Context, w io.Writer, withVectors bool) error {
+	it, ok := s.provider.(chunkIterator)
+	if !ok {
+		return fmt.Errorf("index provider %s does not support export", s.provider.Name())
+	}
+	var vectors [][]float32
+	if withVectors {
+		vl, ok := s.provider.(vectorLoader)
+		if !ok {
+			return fmt.Errorf("index provider %s does not store vectors", s.provider.Name())
+		}
+		var err error
+		if vectors, err = vl.LoadChunkVectors(); err != nil {
+			return err
+		}
+		if vectors == nil {
+			return fmt.Errorf("index was built without embeddings")
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	return it.ForEachChunk(func(id int, c IndexedChunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec := exportRecord{IndexedChunk: c}
+		if withVectors && id < len(vectors) {
+			rec.VectorBase64 = encodeVectorBase64(vectors[id])
+		}
+		return enc.Encode(rec)
+	})
+}
+
+// encodeVectorBase64 packs a vector as little-endian float32 bytes and
+// base64-encodes them.
+func encodeVectorBase64(v []float32) string {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,87 @@
+package rag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestExportChunks(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("Alpha", "2026-01-10", "First document body."),
+		"b.md": doc("Beta", "2026-01-11", "Second document body."),
+	})
+
+	var buf bytes.Buffer
+	if err := svc.ExportChunks(context.Background(), &buf, false); err != nil {
+		t.Fatalf("ExportChunks: %v", err)
+	}
+
+	var rows []exportRecord
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec exportRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line not valid JSON: %v", err)
+		}
+		rows = append(rows, rec)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("exported %d rows, want 2", len(rows))
+	}
+	for _, rec := range rows {
+		if rec.SourcePath == "" || rec.Title == "" || rec.ParagraphID == "" {
+			t.Errorf("row missing metadata: %+v", rec)
+		}
+		if rec.VectorBase64 != "" {
+			t.Errorf("vectors exported without --with-vectors")
+		}
+	}
+
+	// The simple provider keeps no vectors.
+	if err := svc.ExportChunks(context.Background(), &buf, true); err == nil {
+		t.Error("expected an error asking the simple provider for vectors")
+	}
+}
+
+func TestExportChunksWithVectors(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := newCometProvider(dir, config.RAGToolsConfig{}, axisEmbedder{dims: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	chunks := []IndexedChunk{
+		{SourcePath: "a.md", ChunkOrdinal: 1, ParagraphID: "p1", Text: "alpha body"},
+		{SourcePath: "b.md", ChunkOrdinal: 1, ParagraphID: "p2", Text: "beta body"},
+	}
+	if err := provider.Build(context.Background(), chunks, IndexInfo{TotalChunks: len(chunks)}); err != nil {
+		t.Fatal(err)
+	}
+	svc := &Service{provider: provider}
+
+	var buf bytes.Buffer
+	if err := svc.ExportChunks(context.Background(), &buf, true); err != nil {
+		t.Fatalf("ExportChunks: %v", err)
+	}
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec exportRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := base64.StdEncoding.DecodeString(rec.VectorBase64)
+		if err != nil {
+			t.Fatalf("vector_base64 not decodable: %v", err)
+		}
+		if len(raw) != 4*4 {
+			t.Errorf("vector for %s has %d bytes, want 16", rec.SourcePath, len(raw))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+)
+
+// Rocchio weights: how strongly the positive/negative centroids pull the
+// query vector. Classic values; the query itself keeps weight 1.
+const (
+	feedbackAlpha = 0.75
+	feedbackBeta  = 0.25
+)
+
+// feedbackVectorProvider is the optional provider interface relevance
+// feedback needs: the query embedding in the provider's stored-vector
+// space, and the stored vectors for specific chunks.
+type feedbackVectorProvider interface {
+	QueryVector(ctx context.Context, query string) ([]float32, error)
+	VectorsForRefs(ctx context.Context, refs []ChunkRef) ([][]float32, error)
+}
+
+// SearchWithFeedback reruns a search with Rocchio relevance feedback: the
+// query embedding is pulled toward the chunks the caller marked positive
+// and away from the negative ones, while BM25 still scores the original
+// text query. When the service has no embedder, the provider keeps no
+// vectors, or no feedback chunk resolves, it falls back to a plain Search
+// with an explanatory note.
+//
+// Feedback results deliberately bypass the result cache: the adjusted
+// vector is not part of the cache key, so caching them would poison plain
+// searches for the same request.
+func (s *Service) SearchWithFeedback(ctx context.Context, req SearchRequest, positive, negative []ChunkRef) (*SearchResult, error) {
+	fallback := func(note string) (*SearchResult, error) {
+		result, err := s.Search(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		result.Notes = append(result.Notes, note)
+		return result, nil
+	}
+
+	if len(positive) == 0 && len(negative) == 0 {
+		return s.Search(ctx, req)
+	}
+	if s.embedder == nil {
+		return fallback("relevance feedback ignored: no embedder configured")
+	}
+	fv, ok := s.provider.(feedbackVectorProvider)
+	if !ok {
+		return fallback(fmt.Sprintf("relevance feedback ignored: provider %q keeps no vectors", s.provider.Name()))
+	}
+
+	queryVec, err := fv.QueryVector(ctx, req.Query)
+	if err != nil {
+		return fallback(fmt.Sprintf("relevance feedback ignored: %v", err))
+	}
+	posVecs, err := fv.VectorsForRefs(ctx, positive)
+	if err != nil {
+		return nil, fmt.Errorf("resolve positive feedback vectors: %w", err)
+	}
+	negVecs, err := fv.VectorsForRefs(ctx, negative)
+	if err != nil {
+		return nil, fmt.Errorf("resolve negative feedback vectors: %w", err)
+	}
+	if len(posVecs) == 0 && len(negVecs) == 0 {
+		return fallback("relevance feedback ignored: no feedback chunk has a stored vector")
+	}
+
+	req.queryVector = rocchioAdjust(queryVec, posVecs, negVecs, feedbackAlpha, feedbackBeta)
+
+	if err := s.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+	release, err := s.beginQueued()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := s.searchUnqueued(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result.Notes = append(result.Notes,
+		fmt.Sprintf("relevance feedback applied (%d positive, %d negative)", len(posVecs), len(negVecs)))
+	return result, nil
+}
+
+// rocchioAdjust moves the query vector toward the positive centroid and
+// away from the negative one: q + alpha*mean(pos) - beta*mean(neg).
+// Vectors whose dimensionality does not match the query are skipped.
+func rocchioAdjust(query []float32, positive, negative [][]float32, alpha, beta float64) []float32 {
+	adjusted := make([]float32, len(query))
+	copy(adjusted, query)
+	addMean := func(vecs [][]float32, weight float64) {
+		matching := 0
+		for _, v := range vecs {
+			if len(v) == len(query) {
+				matching++
+			}
+		}
+		if matching == 0 {
+			return
+		}
+		w := float32(weight / float64(matching))
+		for _, v := range vecs {
+			if len(v) != len(query) {
+				continue
+			}
+			for i, x := range v {
+				adjusted[i] += w * x
+			}
+		}
+	}
+	addMean(positive, alpha)
+	addMean(negative, -beta)
+	return adjusted
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// newFeedbackTestService is newTestService with a deterministic embedder
+// wired in, so hybrid search and relevance feedback have vectors to work
+// with.
+func newFeedbackTestService(t *testing.T, files map[string]string) *Service {
+	t.Helper()
+	svc := newTestService(t, files)
+	provider, err := newSimpleProvider(filepath.Join(t.TempDir(), "index.json"), axisEmbedder{dims: 8}, config.RAGToolsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.provider.Close()
+	svc.provider = provider
+	svc.embedder = axisEmbedder{dims: 8}
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	return svc
+}
+
+func cosineBySource(t *testing.T, result *SearchResult) map[string]float64 {
+	t.Helper()
+	out := make(map[string]float64, len(result.Items))
+	for _, item := range result.Items {
+		out[item.Ref.SourcePath] = item.Breakdown.RawCosine
+	}
+	return out
+}
+
+func TestSearchWithFeedback(t *testing.T) {
+	// The axis embedder keys on the first byte, so the corpus is shaped
+	// around initials: "bravo..." shares the query's axis ('r' and 'b'
+	// collide mod 8), "alpha..." does not.
+	svc := newFeedbackTestService(t, map[string]string{
+		"alpha.md": doc("Alpha", "2026-01-01", "alpha rollout playbook for the billing service"),
+		"bravo.md": doc("Bravo", "2026-01-02", "bravo rollout playbook for the shipping service"),
+		"delta.md": doc("Delta", "2026-01-03", "delta rollout playbook for the data service"),
+	})
+	ctx := context.Background()
+	req := SearchRequest{Query: "rollout playbook", Mode: "hybrid"}
+
+	plain, err := svc.Search(ctx, req)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	before := cosineBySource(t, plain)
+	if before["bravo.md"] <= before["alpha.md"] {
+		t.Fatalf("fixture broken: want bravo.md semantically closer than alpha.md, got %v", before)
+	}
+
+	fed, err := svc.SearchWithFeedback(ctx, req,
+		[]ChunkRef{{SourcePath: "alpha.md", ChunkOrdinal: 1}},
+		[]ChunkRef{{SourcePath: "bravo.md", ChunkOrdinal: 1}})
+	if err != nil {
+		t.Fatalf("SearchWithFeedback: %v", err)
+	}
+	after := cosineBySource(t, fed)
+	if after["alpha.md"] <= before["alpha.md"] {
+		t.Errorf("positive feedback did not raise alpha.md cosine: %v -> %v",
+			before["alpha.md"], after["alpha.md"])
+	}
+	if after["bravo.md"] >= before["bravo.md"] {
+		t.Errorf("negative feedback did not lower bravo.md cosine: %v -> %v",
+			before["bravo.md"], after["bravo.md"])
+	}
+	var noted bool
+	for _, n := range fed.Notes {
+		if strings.Contains(n, "relevance feedback applied (1 positive, 1 negative)") {
+			noted = true
+		}
+	}
+	if !noted {
+		t.Errorf("missing feedback note, got %v", fed.Notes)
+	}
+
+	// Refs without stored vectors fall back to a plain search with a note.
+	fed, err = svc.SearchWithFeedback(ctx, req,
+		[]ChunkRef{{SourcePath: "missing.md", ChunkOrdinal: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fed.Notes) == 0 || !strings.Contains(fed.Notes[len(fed.Notes)-1], "no feedback chunk has a stored vector") {
+		t.Errorf("missing fallback note, got %v", fed.Notes)
+	}
+}
+
+func TestSearchWithFeedbackNoEmbedder(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "release checklist for the api"),
+		"b.md": doc("B", "2026-01-02", "release checklist for the web app"),
+		"c.md": doc("C", "2026-01-03", "release checklist for the batch jobs"),
+	})
+	result, err := svc.SearchWithFeedback(context.Background(),
+		SearchRequest{Query: "release checklist"},
+		[]ChunkRef{{SourcePath: "a.md", ChunkOrdinal: 1}}, nil)
+	if err != nil {
+		t.Fatalf("SearchWithFeedback: %v", err)
+	}
+	if len(result.Items) == 0 {
+		t.Fatal("fallback search returned no results")
+	}
+	var noted bool
+	for _, n := range result.Notes {
+		if strings.Contains(n, "no embedder configured") {
+			noted = true
+		}
+	}
+	if !noted {
+		t.Errorf("missing no-embedder note, got %v", result.Notes)
+	}
+}
+
+func TestRocchioAdjust(t *testing.T) {
+	query := []float32{1, 0}
+	positive := [][]float32{{0, 1}, {0, 1}, {1, 2, 3}} // mismatched dims skipped
+	negative := [][]float32{{2, 0}}
+	got := rocchioAdjust(query, positive, negative, 0.5, 0.5)
+	want := []float32{0, 0.5}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("rocchioAdjust = %v, want %v", got, want)
+	}
+	// No feedback leaves the query untouched.
+	got = rocchioAdjust(query, nil, nil, 0.75, 0.25)
+	if got[0] != 1 || got[1] != 0 {
+		t.Errorf("rocchioAdjust with no feedback = %v, want %v", got, query)
+	}
+}
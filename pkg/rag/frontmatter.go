@@ -0,0 +1,217 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// docMeta is the document metadata recognized from markdown frontmatter.
+type docMeta struct {
+	Title           string
+	Date            string
+	DocType         string
+	Confidentiality string
+	Project         string
+	Tags            []string
+	// Extra collects scalar keys not covered by the named fields, so
+	// arbitrary team conventions survive into IndexedChunk.Metadata.
+	Extra map[string]string
+}
+
+// parseFrontmatter extracts a leading `---` delimited frontmatter block
+// and returns the parsed metadata plus the remaining body. If no
+// frontmatter block is present the content is returned unchanged. A
+// malformed block also passes the content through unchanged, with the
+// YAML error returned so the build can surface a warning.
+func parseFrontmatter(content string) (docMeta, string, error) {
+	var meta docMeta
+	if !strings.HasPrefix(content, "---\n") {
+		return meta, content, nil
+	}
+	rest := content[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return meta, content, nil
+	}
+	block := rest[:end]
+	body := rest[end+len("\n---"):]
+	// Drop the remainder of the delimiter line and any blank lines
+	// separating frontmatter from the body.
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		body = ""
+	}
+	body = strings.TrimLeft(body, "\n")
+
+	meta, err := parseFrontmatterBlock(block)
+	return meta, body, err
+}
+
+// parseFrontmatterBlock parses the YAML between the `---` delimiters:
+// known keys populate the named docMeta fields, everything else lands in
+// Extra (nested structures flattened to dotted keys) so arbitrary team
+// conventions survive into IndexedChunk.Metadata. The streaming chunker
+// calls it directly with the block it collected.
+func parseFrontmatterBlock(block string) (docMeta, error) {
+	var meta docMeta
+	raw := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(block), &raw); err != nil {
+		return meta, err
+	}
+	for key, value := range raw {
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch key {
+		case "title":
+			meta.Title = yamlScalar(value)
+		case "date":
+			meta.Date = yamlScalar(value)
+		case "doc_type", "type":
+			meta.DocType = strings.ToLower(yamlScalar(value))
+		case "confidentiality":
+			meta.Confidentiality = strings.ToLower(yamlScalar(value))
+		case "project":
+			meta.Project = yamlScalar(value)
+		case "tags":
+			meta.Tags = yamlTagList(value)
+		default:
+			if meta.Extra == nil {
+				meta.Extra = make(map[string]string)
+			}
+			flattenExtra(meta.Extra, key, value)
+			if len(meta.Extra) == 0 {
+				meta.Extra = nil
+			}
+		}
+	}
+	return meta, nil
+}
+
+// yamlScalar renders a YAML scalar as the string our metadata fields
+// carry. Dates parsed into time.Time come back in the ISO form the
+// frontmatter was written in.
+func yamlScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case time.Time:
+		return v.Format("2006-01-02")
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// yamlTagList accepts both a YAML sequence and the legacy comma-separated
+// string form.
+func yamlTagList(value any) []string {
+	var parts []string
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			parts = append(parts, yamlScalar(item))
+		}
+	default:
+		parts = strings.Split(yamlScalar(value), ",")
+	}
+	tags := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if t := strings.ToLower(strings.TrimSpace(p)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// flattenExtra stores a YAML value under key in extra, descending into
+// maps with dotted keys (`review.status`) and joining sequences with
+// commas, so nested custom metadata stays filterable as flat strings.
+func flattenExtra(extra map[string]string, key string, value any) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case map[string]any:
+		for child, childValue := range v {
+			flattenExtra(extra, key+"."+strings.ToLower(strings.TrimSpace(child)), childValue)
+		}
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := yamlScalar(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			extra[key] = strings.Join(parts, ", ")
+		}
+	default:
+		if s := yamlScalar(value); s != "" {
+			extra[key] = s
+		}
+	}
+}
+
+// dateGranularity records how precise a frontmatter date was: imported
+// and legacy docs often carry only a year or a year-month.
+type dateGranularity int
+
+const (
+	granularityDay dateGranularity = iota
+	granularityMonth
+	granularityYear
+)
+
+// dateLayouts maps the accepted date forms to their granularity, most
+// precise first so a full date never matches a partial layout.
+var dateLayouts = []struct {
+	layout string
+	gran   dateGranularity
+}{
+	{"2006-01-02", granularityDay},
+	{"2006-01", granularityMonth},
+	{"2006", granularityYear},
+}
+
+// parseISODate parses a frontmatter date in `2006-01-02`, `2006-01` or
+// `2006` form, returning the start of the covered period. The zero time
+// and false are returned for anything else so callers can treat the date
+// as unknown rather than guessing.
+func parseISODate(s string) (time.Time, bool) {
+	t, _, ok := parseDateGranular(s)
+	return t, ok
+}
+
+// parseDateGranular is parseISODate plus the granularity of the matched
+// form, for callers that care how much of the period a partial date
+// leaves unspecified.
+func parseDateGranular(s string) (time.Time, dateGranularity, bool) {
+	s = strings.TrimSpace(s)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l.layout, s); err == nil {
+			return t, l.gran, true
+		}
+	}
+	return time.Time{}, granularityDay, false
+}
+
+// periodEnd returns the last day a date of the given granularity could
+// refer to; exact dates are returned unchanged. Freshness scoring uses
+// this conservative reference so a year-only date is not penalized as if
+// the document were written on January 1st.
+func periodEnd(t time.Time, gran dateGranularity) time.Time {
+	switch gran {
+	case granularityYear:
+		return t.AddDate(1, 0, -1)
+	case granularityMonth:
+		return t.AddDate(0, 1, -1)
+	default:
+		return t
+	}
+}
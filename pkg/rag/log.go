@@ -0,0 +1,41 @@
+package rag
+
+import "github.com/sipeed/picoclaw/pkg/logger"
+
+// RAGLogger receives the subsystem's structured log events: a message
+// plus key/value fields (event, path, count, duration). Implement it to
+// route RAG logs into a JSON sink or a test recorder; the default
+// forwards to the process-wide logger under the "rag" component, so
+// callers that never inject one see exactly the old output.
+type RAGLogger interface {
+	Debug(msg string, fields map[string]any)
+	Info(msg string, fields map[string]any)
+	Warn(msg string, fields map[string]any)
+	Error(msg string, fields map[string]any)
+}
+
+// stdRAGLogger is the default RAGLogger: the global component logger.
+type stdRAGLogger struct{}
+
+func (stdRAGLogger) Debug(msg string, fields map[string]any) { logger.DebugCF("rag", msg, fields) }
+func (stdRAGLogger) Info(msg string, fields map[string]any)  { logger.InfoCF("rag", msg, fields) }
+func (stdRAGLogger) Warn(msg string, fields map[string]any)  { logger.WarnCF("rag", msg, fields) }
+func (stdRAGLogger) Error(msg string, fields map[string]any) { logger.ErrorCF("rag", msg, fields) }
+
+// WithLogger routes the service's log events to l instead of the global
+// logger, propagating to components that log on the service's behalf
+// (the index provider, the embedding client).
+func WithLogger(l RAGLogger) ServiceOption {
+	return func(s *Service) {
+		if l == nil {
+			return
+		}
+		s.log = l
+		if p, ok := s.provider.(interface{ setLogger(RAGLogger) }); ok {
+			p.setLogger(l)
+		}
+		if e, ok := s.embedder.(interface{ setLogger(RAGLogger) }); ok {
+			e.setLogger(l)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// MigrateSimpleToComet converts a built simple (JSON) index in the
+// workspace's state directory into a comet store. The stored chunks feed
+// straight into comet's build, so the knowledge base is not re-walked or
+// re-chunked, and vectors recorded in the JSON store are replayed instead
+// of re-embedded when they still belong to the configured embedding
+// model. cfg should carry the target comet configuration.
+func MigrateSimpleToComet(ctx context.Context, workspace string, cfg config.RAGToolsConfig) (*IndexInfo, error) {
+	embedder, err := newEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	stateDir := filepath.Join(workspace, "state", "rag")
+	return migrateSimpleToComet(ctx, stateDir, cfg, embedder)
+}
+
+// migrateSimpleToComet is the core migration against an explicit state
+// directory and embedder, shared with tests.
+func migrateSimpleToComet(ctx context.Context, stateDir string, cfg config.RAGToolsConfig, live Embedder) (*IndexInfo, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loading simple index: %w", err)
+	}
+	var store IndexStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("parsing simple index: %w", err)
+	}
+	if len(store.Chunks) == 0 {
+		return nil, fmt.Errorf("simple index at %s holds no chunks", stateDir)
+	}
+
+	embedder, reused := migrationEmbedder(live, &store)
+
+	provider, err := newCometProvider(stateDir, cfg, embedder)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Close()
+
+	info := store.Info
+	info.IndexProvider = provider.Name()
+	info.BuiltAt = time.Now().UTC()
+	info.VectorDims = 0 // Build fills it from the vectors it ends up with
+	info.EmbeddingModelID = ""
+	if embedder != nil {
+		info.EmbeddingModelID = embedder.ModelID()
+	}
+	if err := provider.Build(ctx, store.Chunks, info); err != nil {
+		return nil, fmt.Errorf("building comet index: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(stateDir, providerMarkerFile),
+		[]byte(provider.Name()+"\n"), 0o644); err != nil {
+		logger.WarnCF("rag", "Failed to write provider marker", map[string]any{"error": err.Error()})
+	}
+	logger.InfoCF("rag", "Migrated index to comet", map[string]any{
+		"chunks":         len(store.Chunks),
+		"vectors_reused": reused,
+	})
+	return provider.LoadIndexInfo(ctx)
+}
+
+// migrationEmbedder decides how the migration obtains vectors. When the
+// JSON store carries a full vector set for the model the live embedder
+// serves (or no embedder is configured at all), the stored vectors are
+// replayed; anything else falls back to the live embedder, re-embedding
+// the whole corpus.
+func migrationEmbedder(live Embedder, store *IndexStore) (Embedder, bool) {
+	if store.Vectors == nil || len(store.Vectors) != len(store.Chunks) {
+		return live, false
+	}
+	if live != nil && live.ModelID() != store.Info.EmbeddingModelID {
+		return live, false
+	}
+	byText := make(map[string][]float32, len(store.Chunks))
+	for i, c := range store.Chunks {
+		byText[c.Text] = store.Vectors[i]
+	}
+	return &storedVectorEmbedder{
+		modelID: store.Info.EmbeddingModelID,
+		dims:    len(store.Vectors[0]),
+		next:    live,
+		byText:  byText,
+	}, true
+}
+
+// storedVectorEmbedder replays the vectors a previous index stored for
+// its chunk texts, so a migration rebuild does not pay embedding cost
+// again. Texts outside the recorded set fall through to the wrapped
+// embedder; without one they are an error, which cannot happen while the
+// texts come from the same store as the vectors.
+type storedVectorEmbedder struct {
+	modelID string
+	dims    int
+	next    Embedder
+	byText  map[string][]float32
+}
+
+func (e *storedVectorEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	var missing []string
+	var missingIdx []int
+	for i, t := range texts {
+		if v, ok := e.byText[t]; ok {
+			out[i] = v
+			continue
+		}
+		missing = append(missing, t)
+		missingIdx = append(missingIdx, i)
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	if e.next == nil {
+		return nil, fmt.Errorf("no stored vector for %d texts and no embedder configured", len(missing))
+	}
+	fresh, err := e.next.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range fresh {
+		out[missingIdx[i]] = v
+	}
+	return out, nil
+}
+
+func (e *storedVectorEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if e.next == nil {
+		return nil, fmt.Errorf("no embedder configured")
+	}
+	return e.next.EmbedQuery(ctx, text)
+}
+
+func (e *storedVectorEmbedder) ModelID() string {
+	return e.modelID
+}
+
+func (e *storedVectorEmbedder) Dims() int {
+	return e.dims
+}
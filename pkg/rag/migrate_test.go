@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestMigrateSimpleToCometKeywordOnly(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"deploy.md":  doc("Deploy", "2026-01-01", "The rollout checklist covers canary analysis and rollback steps."),
+		"billing.md": doc("Billing", "2026-01-02", "Billing reconciliation notes mention the rollout date in passing."),
+		"notes.md":   doc("Notes", "2026-01-03", "Lunch notes; the rollout came up once."),
+	})
+	ctx := context.Background()
+	before, err := svc.IndexInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cometCfg := config.RAGToolsConfig{IndexProvider: "comet"}
+	info, err := MigrateSimpleToComet(ctx, svc.workspace, cometCfg)
+	if err != nil {
+		t.Fatalf("MigrateSimpleToComet: %v", err)
+	}
+	if info.IndexProvider != "comet" {
+		t.Errorf("migrated provider = %q, want comet", info.IndexProvider)
+	}
+	if info.TotalChunks != before.TotalChunks || info.TotalDocuments != before.TotalDocuments {
+		t.Errorf("migrated %d docs/%d chunks, want %d/%d",
+			info.TotalDocuments, info.TotalChunks, before.TotalDocuments, before.TotalChunks)
+	}
+
+	// The migrated index serves searches through a comet service without
+	// ever re-walking the knowledge base.
+	cometSvc, err := NewService(svc.workspace, cometCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cometSvc.Close()
+	result, err := cometSvc.Search(ctx, SearchRequest{Query: "rollout checklist"})
+	if err != nil {
+		t.Fatalf("Search on migrated index: %v", err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Ref.SourcePath != "deploy.md" {
+		t.Errorf("migrated search top hit = %+v, want deploy.md", result.Items)
+	}
+
+	// The provider marker now names comet, so the old simple configuration
+	// fails loudly instead of serving a stale index.
+	if _, err := svc.Search(ctx, SearchRequest{Query: "rollout checklist"}); err == nil {
+		t.Error("expected a provider mismatch error from the simple service after migration")
+	}
+}
+
+// tripwireEmbedder poses as the model the vectors were stored under but
+// fails the test if the migration actually re-embeds.
+type tripwireEmbedder struct{ t *testing.T }
+
+func (e tripwireEmbedder) Embed(context.Context, []string) ([][]float32, error) {
+	e.t.Error("migration re-embedded despite reusable stored vectors")
+	return nil, fmt.Errorf("unexpected embed call")
+}
+
+func (e tripwireEmbedder) EmbedQuery(context.Context, string) ([]float32, error) {
+	return nil, fmt.Errorf("unexpected embed call")
+}
+
+func (e tripwireEmbedder) ModelID() string { return "test/axis" }
+func (e tripwireEmbedder) Dims() int       { return 8 }
+
+func TestMigrateSimpleToCometReusesVectors(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"alpha.md": doc("Alpha", "2026-01-01", "Alpha document about rollouts."),
+		"bravo.md": doc("Bravo", "2026-01-02", "Bravo document about billing."),
+		"delta.md": doc("Delta", "2026-01-03", "Delta document about dashboards."),
+	})
+	ctx := context.Background()
+
+	// Rebuild the simple index with vectors from the axis embedder.
+	provider, err := newSimpleProvider(filepath.Join(svc.stateDir, "index.json"), axisEmbedder{dims: 8}, config.RAGToolsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.provider = provider
+	svc.embedder = axisEmbedder{dims: 8}
+	if _, err := svc.BuildIndex(ctx); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	info, err := migrateSimpleToComet(ctx, svc.stateDir, config.RAGToolsConfig{IndexProvider: "comet"}, tripwireEmbedder{t})
+	if err != nil {
+		t.Fatalf("migrateSimpleToComet: %v", err)
+	}
+	if info.EmbeddingModelID != "test/axis" || info.VectorDims != 8 {
+		t.Errorf("migrated info = model %q dims %d, want test/axis dims 8", info.EmbeddingModelID, info.VectorDims)
+	}
+
+	// The comet store holds one stored vector per chunk.
+	cp, err := newCometProvider(svc.stateDir, config.RAGToolsConfig{}, axisEmbedder{dims: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	vectors, err := cp.LoadChunkVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != info.TotalChunks || len(vectors[0]) != 8 {
+		t.Errorf("comet store holds %d vectors of %d dims, want %d of 8", len(vectors), len(vectors[0]), info.TotalChunks)
+	}
+
+	// A live embedder for a different model invalidates the stored
+	// vectors instead of mislabeling them.
+	if _, reused := migrationEmbedder(axisEmbedder{dims: 4}, &IndexStore{
+		Info:    IndexInfo{EmbeddingModelID: "other/model"},
+		Chunks:  []IndexedChunk{{Text: "a"}},
+		Vectors: [][]float32{{1}},
+	}); reused {
+		t.Error("vectors reused across a model change")
+	}
+}
@@ -0,0 +1,128 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// moreLikeThisTerms is how many of the document's most frequent terms
+// form the fallback keyword query.
+const moreLikeThisTerms = 12
+
+// similaritySearcher is implemented by providers that can rank chunks
+// against a stored document's vectors directly, without re-embedding.
+// A (nil, nil) return means vectors are unavailable and the caller
+// should fall back to term extraction.
+type similaritySearcher interface {
+	SimilarToDocument(ctx context.Context, sourcePath string, topN int) ([]Candidate, error)
+}
+
+// MoreLikeThis finds content similar to an indexed document: stored
+// vectors when the provider has them, otherwise a keyword query built
+// from the document's most frequent terms. The source document itself is
+// excluded from the results.
+func (s *Service) MoreLikeThis(ctx context.Context, sourcePath string, topK int) (*SearchResult, error) {
+	if err := s.checkProviderMatch(); err != nil {
+		return nil, err
+	}
+	release, err := s.beginQueued()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	targets, err := s.documentChunks(ctx, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	topK = s.clampTopK(topK)
+
+	var (
+		candidates []Candidate
+		query      string
+		notes      []string
+		mode       = "semantic"
+	)
+	if ss, ok := s.provider.(similaritySearcher); ok {
+		candidates, err = ss.SimilarToDocument(ctx, sourcePath, maxProviderCandidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if candidates == nil {
+		terms := topTerms(targets, moreLikeThisTerms)
+		if len(terms) == 0 {
+			return nil, fmt.Errorf("document %s has no searchable terms", sourcePath)
+		}
+		query = strings.Join(terms, " ")
+		mode = "keyword"
+		notes = append(notes, "more-like-this terms: "+query)
+		candidates, err = s.provider.Search(ctx, query, ProviderSearchOptions{
+			TopN: maxProviderCandidates,
+			Mode: mode,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop the query document itself.
+	kept := candidates[:0:0]
+	for _, c := range candidates {
+		if c.Chunk.SourcePath != sourcePath {
+			kept = append(kept, c)
+		}
+	}
+
+	profile, err := resolveProfile("")
+	if err != nil {
+		return nil, err
+	}
+	items := s.scoreCandidates(query, kept, profile, s.searchRefTime(ctx), false)
+	if len(items) > topK {
+		items = items[:topK]
+	}
+
+	result := &SearchResult{
+		Query:     "more_like_this:" + sourcePath,
+		Mode:      mode,
+		ProfileID: profile.ID,
+		Items:     items,
+		Notes:     notes,
+	}
+	if info, err := s.provider.LoadIndexInfo(ctx); err == nil {
+		result.IndexVersion = info.IndexVersion
+	}
+	return result, nil
+}
+
+// topTerms returns the n most frequent tokens across the chunks, ties
+// broken alphabetically for determinism. Very short tokens are skipped
+// as unhelpful query terms.
+func topTerms(chunks []IndexedChunk, n int) []string {
+	freq := make(map[string]int)
+	for i := range chunks {
+		for _, tok := range tokenize(chunks[i].Text) {
+			if len(tok) < 3 {
+				continue
+			}
+			freq[tok]++
+		}
+	}
+	terms := make([]string, 0, len(freq))
+	for t := range freq {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if freq[terms[i]] != freq[terms[j]] {
+			return freq[terms[i]] > freq[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
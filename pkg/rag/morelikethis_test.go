@@ -0,0 +1,59 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMoreLikeThisExcludesSource(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("Rollout Plan", "2026-01-01", "deployment rollout canary checklist rollback procedures deployment rollout"),
+		"b.md": doc("Rollout Retro", "2026-01-05", "retro on the deployment rollout and the canary checklist we used"),
+		"c.md": doc("Lunch Notes", "2026-01-02", "sandwiches coffee pastries and the cafeteria schedule"),
+	})
+
+	result, err := svc.MoreLikeThis(context.Background(), "a.md", 5)
+	if err != nil {
+		t.Fatalf("MoreLikeThis: %v", err)
+	}
+	if len(result.Items) == 0 {
+		t.Fatal("expected similar documents")
+	}
+	for _, item := range result.Items {
+		if item.Ref.SourcePath == "a.md" {
+			t.Error("source document returned in its own similarity results")
+		}
+	}
+	if result.Items[0].Ref.SourcePath != "b.md" {
+		t.Errorf("top similar = %s, want b.md", result.Items[0].Ref.SourcePath)
+	}
+	if result.Mode != "keyword" {
+		t.Errorf("mode = %s, want keyword fallback without embedder", result.Mode)
+	}
+}
+
+func TestMoreLikeThisUnknownDocument(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"a.md": doc("A", "2026-01-01", "content"),
+	})
+	if _, err := svc.MoreLikeThis(context.Background(), "missing.md", 5); err == nil {
+		t.Fatal("expected error for unindexed document")
+	}
+}
+
+func TestTopTerms(t *testing.T) {
+	chunks := []IndexedChunk{
+		{Text: "alpha alpha alpha beta beta gamma"},
+		{Text: "alpha delta delta"},
+	}
+	terms := topTerms(chunks, 3)
+	want := []string{"alpha", "beta", "delta"}
+	if len(terms) != len(want) {
+		t.Fatalf("terms = %v, want %v", terms, want)
+	}
+	for i := range want {
+		if terms[i] != want[i] {
+			t.Errorf("terms[%d] = %s, want %s", i, terms[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package rag
+
+import "math"
+
+// PCA dimensionality reduction for stored embeddings. On constrained
+// devices the vector file dominates index size and cosine cost scales
+// with dims, so an optional projection onto the corpus's top principal
+// components trades a little recall for a large storage/latency win. The
+// projection is fitted at build time and persisted so query vectors pass
+// through the identical transform.
+
+// pcaProjection is a fitted projection: vectors are centered on the
+// corpus mean and projected onto the component rows.
+type pcaProjection struct {
+	Mean       []float32   `json:"mean"`
+	Components [][]float32 `json:"components"` // one principal component per row
+}
+
+// pcaFitSampleCap bounds how many corpus vectors the fit uses; a
+// deterministic stride subsample beyond this keeps build cost flat
+// without materially changing the covariance estimate.
+const pcaFitSampleCap = 2048
+
+// pcaPowerIterations bounds the power-iteration count per component.
+const pcaPowerIterations = 100
+
+// fitPCA learns a dims-dimensional projection from the corpus vectors
+// via power iteration with deflation. It returns nil when reduction is
+// pointless (no vectors, or dims >= the input dimensionality). Fewer
+// components than requested may be returned when the corpus has less
+// variance than dims directions.
+func fitPCA(vectors [][]float32, dims int) *pcaProjection {
+	if len(vectors) == 0 {
+		return nil
+	}
+	d := len(vectors[0])
+	if dims <= 0 || dims >= d {
+		return nil
+	}
+
+	sample := vectors
+	if len(sample) > pcaFitSampleCap {
+		stride := len(vectors) / pcaFitSampleCap
+		sample = make([][]float32, 0, pcaFitSampleCap)
+		for i := 0; i < len(vectors) && len(sample) < pcaFitSampleCap; i += stride {
+			sample = append(sample, vectors[i])
+		}
+	}
+
+	mean := make([]float32, d)
+	for _, v := range sample {
+		for i, f := range v {
+			mean[i] += f
+		}
+	}
+	for i := range mean {
+		mean[i] /= float32(len(sample))
+	}
+
+	// Covariance matrix, row-major d×d.
+	cov := make([]float64, d*d)
+	for _, v := range sample {
+		centered := make([]float64, d)
+		for i, f := range v {
+			centered[i] = float64(f - mean[i])
+		}
+		for i := 0; i < d; i++ {
+			if centered[i] == 0 {
+				continue
+			}
+			row := cov[i*d:]
+			for j := 0; j < d; j++ {
+				row[j] += centered[i] * centered[j]
+			}
+		}
+	}
+	n := float64(len(sample))
+	for i := range cov {
+		cov[i] /= n
+	}
+
+	proj := &pcaProjection{Mean: mean}
+	for c := 0; c < dims; c++ {
+		vec, val := dominantEigenvector(cov, d, c)
+		if val <= 1e-12 {
+			break // remaining directions carry no variance
+		}
+		comp := make([]float32, d)
+		for i, f := range vec {
+			comp[i] = float32(f)
+		}
+		proj.Components = append(proj.Components, comp)
+		// Deflate so the next iteration finds the next component.
+		for i := 0; i < d; i++ {
+			row := cov[i*d:]
+			for j := 0; j < d; j++ {
+				row[j] -= val * vec[i] * vec[j]
+			}
+		}
+	}
+	if len(proj.Components) == 0 {
+		return nil
+	}
+	return proj
+}
+
+// dominantEigenvector power-iterates cov (row-major d×d) to its largest
+// eigenpair. seed varies the deterministic starting vector so deflated
+// passes do not start orthogonal to their target.
+func dominantEigenvector(cov []float64, d, seed int) ([]float64, float64) {
+	x := make([]float64, d)
+	for i := range x {
+		x[i] = 1 + 0.001*float64((i+seed)%7)
+	}
+	normalize(x)
+
+	next := make([]float64, d)
+	for iter := 0; iter < pcaPowerIterations; iter++ {
+		for i := 0; i < d; i++ {
+			row := cov[i*d:]
+			var sum float64
+			for j := 0; j < d; j++ {
+				sum += row[j] * x[j]
+			}
+			next[i] = sum
+		}
+		norm := normalize(next)
+		if norm == 0 {
+			return x, 0
+		}
+		var dot float64
+		for i := range x {
+			dot += x[i] * next[i]
+		}
+		x, next = next, x
+		if math.Abs(1-math.Abs(dot)) < 1e-9 {
+			break
+		}
+	}
+
+	// Rayleigh quotient for the eigenvalue.
+	var val float64
+	for i := 0; i < d; i++ {
+		row := cov[i*d:]
+		var sum float64
+		for j := 0; j < d; j++ {
+			sum += row[j] * x[j]
+		}
+		val += x[i] * sum
+	}
+	return x, val
+}
+
+func normalize(v []float64) float64 {
+	var norm float64
+	for _, f := range v {
+		norm += f * f
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return 0
+	}
+	for i := range v {
+		v[i] /= norm
+	}
+	return norm
+}
+
+// dims is the projection's output dimensionality.
+func (p *pcaProjection) dims() int {
+	return len(p.Components)
+}
+
+// apply projects one vector into the reduced space.
+func (p *pcaProjection) apply(v []float32) []float32 {
+	out := make([]float32, len(p.Components))
+	for k, comp := range p.Components {
+		var sum float64
+		for i := range comp {
+			sum += float64(comp[i]) * float64(v[i]-p.Mean[i])
+		}
+		out[k] = float32(sum)
+	}
+	return out
+}
+
+// applyPCA projects a whole vector set.
+func applyPCA(proj *pcaProjection, vectors [][]float32) [][]float32 {
+	out := make([][]float32, len(vectors))
+	for i, v := range vectors {
+		out[i] = proj.apply(v)
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestFitPCADegenerate(t *testing.T) {
+	if fitPCA(nil, 2) != nil {
+		t.Error("no vectors should yield no projection")
+	}
+	if fitPCA([][]float32{{1, 2}}, 2) != nil {
+		t.Error("dims >= input dimensionality should yield no projection")
+	}
+	// Identical vectors carry zero variance: nothing to project onto.
+	same := [][]float32{{1, 1, 1, 1}, {1, 1, 1, 1}, {1, 1, 1, 1}}
+	if fitPCA(same, 2) != nil {
+		t.Error("zero-variance corpus should yield no projection")
+	}
+}
+
+func TestFitPCAPreservesSeparation(t *testing.T) {
+	// Two clusters varying along orthogonal axes of an 8-dim space; a
+	// 2-dim projection must keep them separable.
+	var vectors [][]float32
+	for i := 0; i < 20; i++ {
+		a := make([]float32, 8)
+		a[0] = 1 + float32(i)*0.1
+		b := make([]float32, 8)
+		b[1] = 1 + float32(i)*0.1
+		vectors = append(vectors, a, b)
+	}
+
+	proj := fitPCA(vectors, 2)
+	if proj == nil {
+		t.Fatal("expected a projection")
+	}
+	if proj.dims() != 2 {
+		t.Fatalf("dims = %d, want 2", proj.dims())
+	}
+
+	pa0 := proj.apply(vectors[0]) // cluster A
+	pa1 := proj.apply(vectors[2]) // cluster A
+	pb0 := proj.apply(vectors[1]) // cluster B
+	within := cosineSimilarity(pa0, pa1)
+	across := cosineSimilarity(pa0, pb0)
+	if within <= across {
+		t.Errorf("projection lost cluster structure: within %.3f <= across %.3f", within, across)
+	}
+}
+
+func TestProjectionRoundTrip(t *testing.T) {
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	proj := &pcaProjection{
+		Mean:       []float32{0.5, 0.5, 0.5},
+		Components: [][]float32{{1, 0, 0}, {0, 1, 0}},
+	}
+	if err := store.SaveProjection("test/model", proj); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.LoadProjection("test/model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.dims() != 2 || len(loaded.Mean) != 3 {
+		t.Fatalf("round trip lost data: %+v", loaded)
+	}
+
+	if err := store.SaveProjection("test/model", nil); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = store.LoadProjection("test/model"); err != nil || loaded != nil {
+		t.Errorf("nil save should remove the projection, got %+v, %v", loaded, err)
+	}
+}
+
+// axisEmbedder is a deterministic test embedder: each text maps onto an
+// axis chosen by its first byte, so similarity structure is predictable.
+type axisEmbedder struct{ dims int }
+
+func (e axisEmbedder) embedOne(text string) []float32 {
+	v := make([]float32, e.dims)
+	if len(text) > 0 {
+		v[int(text[0])%e.dims] = 1
+		v[(int(text[0])+1)%e.dims] = 0.3
+	}
+	return v
+}
+
+func (e axisEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = e.embedOne(t)
+	}
+	return out, nil
+}
+
+func (e axisEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return e.embedOne(text), nil
+}
+
+func (e axisEmbedder) ModelID() string { return "test/axis" }
+func (e axisEmbedder) Dims() int       { return e.dims }
+
+func TestCometPCAEndToEnd(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := newCometProvider(dir, config.RAGToolsConfig{PCADims: 3}, axisEmbedder{dims: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := make([]IndexedChunk, 0, 8)
+	for i := 0; i < 8; i++ {
+		chunks = append(chunks, IndexedChunk{
+			SourcePath:   fmt.Sprintf("doc%d.md", i),
+			ChunkOrdinal: 1,
+			ParagraphID:  fmt.Sprintf("p%d", i),
+			Text:         fmt.Sprintf("%ctext body of document %d", 'a'+i, i),
+		})
+	}
+	if err := provider.Build(context.Background(), chunks, IndexInfo{TotalChunks: len(chunks)}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if provider.proj == nil {
+		t.Fatal("expected a fitted projection")
+	}
+	if provider.vecDims != provider.proj.dims() {
+		t.Errorf("stored dims %d != projection dims %d", provider.vecDims, provider.proj.dims())
+	}
+	if provider.vecDims >= 8 {
+		t.Errorf("vectors not reduced: %d dims", provider.vecDims)
+	}
+
+	hits, err := provider.Search(context.Background(), "atext body", ProviderSearchOptions{TopN: 5, Mode: "hybrid"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) == 0 {
+		t.Fatal("no hits")
+	}
+	anyCosine := false
+	for _, h := range hits {
+		if h.HasCosine {
+			anyCosine = true
+		}
+	}
+	if !anyCosine {
+		t.Error("expected cosine signal through the projection")
+	}
+
+	// A fresh provider instance must load the projection back from disk.
+	if err := provider.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := newCometProvider(dir, config.RAGToolsConfig{PCADims: 3}, axisEmbedder{dims: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.Search(context.Background(), "atext body", ProviderSearchOptions{TopN: 5, Mode: "hybrid"}); err != nil {
+		t.Fatalf("Search after reopen: %v", err)
+	}
+	if reopened.proj == nil {
+		t.Error("projection not loaded from store")
+	}
+}
@@ -0,0 +1,88 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// IndexPlan is the dry-run counterpart of a rebuild: which documents a
+// build would add, re-chunk, leave alone or drop, judged by comparing
+// each file's content hash against the DocumentVersion stored on its
+// chunks. Paths are KB-relative and sorted.
+type IndexPlan struct {
+	Added     []string `json:"added,omitempty"`
+	Changed   []string `json:"changed,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+}
+
+// InSync reports whether a rebuild would be a no-op.
+func (p *IndexPlan) InSync() bool {
+	return len(p.Added) == 0 && len(p.Changed) == 0 && len(p.Removed) == 0
+}
+
+// PlanIndex walks the knowledge base exactly as BuildIndex would —
+// same denylist, extension set and candidate order — and compares each
+// document's version hash against the stored index, without writing
+// anything. Walk entries that failed a check (unreadable, symlink out of
+// root) are skipped here the same way a build would skip them with a
+// warning.
+func (s *Service) PlanIndex(ctx context.Context) (*IndexPlan, error) {
+	it, ok := s.provider.(chunkIterator)
+	if !ok {
+		return nil, fmt.Errorf("index provider %s does not support planning", s.provider.Name())
+	}
+	// One version per document: every chunk of a document carries the
+	// same DocumentVersion, so the last chunk seen is as good as any.
+	stored := make(map[string]string)
+	if err := it.ForEachChunk(func(_ int, c IndexedChunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stored[c.SourcePath] = c.DocumentVersion
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	work, err := s.walkCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &IndexPlan{}
+	seen := make(map[string]bool, len(work))
+	for _, c := range work {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if c.warning != "" {
+			continue
+		}
+		version, err := documentVersionFile(c.path)
+		if err != nil {
+			// The file vanished or became unreadable mid-plan; a build
+			// would warn and skip it, so it is not part of the on-disk set.
+			continue
+		}
+		seen[c.relToKB] = true
+		switch v, indexed := stored[c.relToKB]; {
+		case !indexed:
+			plan.Added = append(plan.Added, c.relToKB)
+		case v != version:
+			// An empty stored version (pre-DocumentVersion index) also
+			// lands here: it cannot be proven unchanged.
+			plan.Changed = append(plan.Changed, c.relToKB)
+		default:
+			plan.Unchanged = append(plan.Unchanged, c.relToKB)
+		}
+	}
+	for path := range stored {
+		if !seen[path] {
+			plan.Removed = append(plan.Removed, path)
+		}
+	}
+	sort.Strings(plan.Removed)
+	return plan, nil
+}
@@ -0,0 +1,84 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlanIndex(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"alpha.md": doc("Alpha", "2026-01-01", "Alpha document about rollouts."),
+		"bravo.md": doc("Bravo", "2026-01-02", "Bravo document about billing."),
+		"delta.md": doc("Delta", "2026-01-03", "Delta document about dashboards."),
+	})
+	ctx := context.Background()
+
+	plan, err := svc.PlanIndex(ctx)
+	if err != nil {
+		t.Fatalf("PlanIndex: %v", err)
+	}
+	if !plan.InSync() {
+		t.Errorf("fresh index plan = %+v, want in sync", plan)
+	}
+	if want := []string{"alpha.md", "bravo.md", "delta.md"}; !reflect.DeepEqual(plan.Unchanged, want) {
+		t.Errorf("unchanged = %v, want %v", plan.Unchanged, want)
+	}
+
+	// Edit one document, add one, remove one; the index is untouched.
+	kb := filepath.Join(svc.workspace, "knowledge")
+	if err := os.WriteFile(filepath.Join(kb, "bravo.md"),
+		[]byte(doc("Bravo", "2026-01-02", "Bravo document, now revised.")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(kb, "echo.md"),
+		[]byte(doc("Echo", "2026-01-04", "Echo document about escalations.")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(kb, "delta.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = svc.PlanIndex(ctx)
+	if err != nil {
+		t.Fatalf("PlanIndex after edits: %v", err)
+	}
+	if plan.InSync() {
+		t.Error("plan reports in sync after edits")
+	}
+	if want := []string{"echo.md"}; !reflect.DeepEqual(plan.Added, want) {
+		t.Errorf("added = %v, want %v", plan.Added, want)
+	}
+	if want := []string{"bravo.md"}; !reflect.DeepEqual(plan.Changed, want) {
+		t.Errorf("changed = %v, want %v", plan.Changed, want)
+	}
+	if want := []string{"delta.md"}; !reflect.DeepEqual(plan.Removed, want) {
+		t.Errorf("removed = %v, want %v", plan.Removed, want)
+	}
+	if want := []string{"alpha.md"}; !reflect.DeepEqual(plan.Unchanged, want) {
+		t.Errorf("unchanged = %v, want %v", plan.Unchanged, want)
+	}
+
+	// Planning never writes: a rebuild still sees the old index info.
+	info, err := svc.IndexInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalDocuments != 3 {
+		t.Errorf("plan mutated the index: %d documents, want 3", info.TotalDocuments)
+	}
+
+	// After rebuilding, the plan converges.
+	if _, err := svc.BuildIndex(ctx); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	plan, err = svc.PlanIndex(ctx)
+	if err != nil {
+		t.Fatalf("PlanIndex after rebuild: %v", err)
+	}
+	if !plan.InSync() {
+		t.Errorf("plan after rebuild = %+v, want in sync", plan)
+	}
+}
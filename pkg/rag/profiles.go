@@ -0,0 +1,106 @@
+package rag
+
+import "fmt"
+
+// Profile is a named scoring policy: which retrieval mode to run, how to
+// weight the individual signals and how to shape the final result set.
+// Weights are expected to sum to roughly 1.0 so final scores stay in a
+// comparable range across profiles.
+type Profile struct {
+	ID   string `json:"id"`
+	Mode string `json:"mode"` // "keyword", "semantic" or "hybrid"
+
+	WeightBM25      float64 `json:"weight_bm25"`
+	WeightCosine    float64 `json:"weight_cosine"`
+	WeightFreshness float64 `json:"weight_freshness"`
+	WeightMetadata  float64 `json:"weight_metadata"`
+
+	// ScoreNorm selects how RawBM25/RawCosine map into the weighting
+	// range: "minmax" (the default) scales over the candidate range,
+	// which amplifies noise when raw scores bunch together; "zscore"
+	// centers on the candidate mean and squashes through a logistic,
+	// which is steadier on tight spreads; "none" passes raw scores
+	// through unchanged.
+	ScoreNorm string `json:"score_norm,omitempty"`
+
+	// FreshnessHalfLifeDays controls the exponential decay used for the
+	// freshness signal; 0 disables decay (freshness contributes 0).
+	FreshnessHalfLifeDays float64 `json:"freshness_half_life_days"`
+
+	// PerSourceCap bounds how many chunks of one document may appear in
+	// the final result set; 0 means unlimited.
+	PerSourceCap int `json:"per_source_cap"`
+
+	// DocTypeCap is a soft bound on how many hits of one doc type may
+	// appear in the final set, so packs span notes, papers and policies
+	// instead of ten notes. Soft: leftover slots are refilled from capped
+	// hits when nothing else qualifies. 0 disables it.
+	DocTypeCap int `json:"doc_type_cap,omitempty"`
+
+	// MinScore drops hits whose final score falls below the floor.
+	MinScore float64 `json:"min_score"`
+
+	// RiskThreshold drops candidates whose index-time injection risk
+	// score meets or exceeds it, instead of merely down-ranking them via
+	// the standard risk penalty. 0 disables the hard gate.
+	RiskThreshold float64 `json:"risk_threshold,omitempty"`
+
+	// DiversityLambda enables a Maximal Marginal Relevance pass over the
+	// scored candidates: 0 keeps pure relevance ordering, 1 is pure
+	// diversity. Similarity between candidates uses stored vectors when
+	// the provider keeps them, else token-overlap Jaccard.
+	DiversityLambda float64 `json:"diversity_lambda,omitempty"`
+}
+
+// builtinProfiles are the profiles available without any configuration.
+//
+//   - default_research: balanced hybrid retrieval with per-source
+//     diversity, suited to broad research questions.
+//   - decisions_recent: freshness-weighted retrieval for "what did we
+//     decide lately" style queries.
+var builtinProfiles = map[string]Profile{
+	"default_research": {
+		ID:                    "default_research",
+		Mode:                  "hybrid",
+		WeightBM25:            0.35,
+		WeightCosine:          0.45,
+		WeightFreshness:       0.10,
+		WeightMetadata:        0.10,
+		FreshnessHalfLifeDays: 180,
+		PerSourceCap:          2,
+		MinScore:              0.05,
+	},
+	"decisions_recent": {
+		ID:                    "decisions_recent",
+		Mode:                  "hybrid",
+		WeightBM25:            0.30,
+		WeightCosine:          0.30,
+		WeightFreshness:       0.35,
+		WeightMetadata:        0.05,
+		FreshnessHalfLifeDays: 45,
+		PerSourceCap:          3,
+		MinScore:              0.05,
+	},
+}
+
+// defaultProfileID is used when a request names no profile.
+const defaultProfileID = "default_research"
+
+// resolveProfile returns the profile for id, or the default profile for
+// an empty id. Unknown ids are an error so callers notice typos instead
+// of silently getting different ranking behavior.
+func resolveProfile(id string) (Profile, error) {
+	if id == "" {
+		id = defaultProfileID
+	}
+	p, ok := builtinProfiles[id]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown search profile %q", id)
+	}
+	return p, nil
+}
+
+// ProfileIDs lists the available profile ids in deterministic order.
+func ProfileIDs() []string {
+	return []string{"decisions_recent", "default_research"}
+}
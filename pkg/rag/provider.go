@@ -0,0 +1,129 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// maxProviderCandidates bounds how many candidates the service requests
+// from a provider before filtering and scoring.
+const maxProviderCandidates = 2000
+
+// ProviderCapabilities describes which retrieval signals a provider can
+// produce, so the service can degrade modes honestly.
+type ProviderCapabilities struct {
+	Lexical  bool
+	Semantic bool
+	// FilterPushdown means Search applies the pushdown-eligible subset of
+	// ProviderSearchOptions.Filters (see splitPushdownFilters) inside the
+	// engine, so the service skips re-checking those fields in Go.
+	FilterPushdown bool
+}
+
+// ProviderSearchOptions parameterize one provider search.
+type ProviderSearchOptions struct {
+	// TopN is the maximum number of candidates to return.
+	TopN int
+	// Mode is the effective retrieval mode ("keyword", "semantic",
+	// "hybrid"); providers ignore signals they cannot produce.
+	Mode string
+	// Filters restrict candidates inside the engine for providers that
+	// report FilterPushdown; everyone else ignores them and the service
+	// filters candidates itself.
+	Filters SearchFilters
+	// QueryVector, when set, replaces the query embedding for the
+	// semantic signal (relevance feedback). It must already be in the
+	// provider's stored-vector space; providers without vectors ignore
+	// it.
+	QueryVector []float32
+}
+
+// splitPushdownFilters separates the filter fields a pushdown-capable
+// provider applies in-engine (doc types, projects, confidentiality
+// allow-list) from those the service always applies itself. The
+// confidentiality allow-list is expanded through the level ordering so
+// the provider's exact-term matching keeps the at-or-below semantics.
+// The restricted hard gate and the MaxConfidentiality ceiling
+// deliberately stay service-side so a provider bug can never leak
+// sensitive chunks.
+func splitPushdownFilters(f SearchFilters, rank confidentialityRank) (pushed, remaining SearchFilters) {
+	pushed = SearchFilters{
+		DocTypes:             f.DocTypes,
+		Projects:             f.Projects,
+		ConfidentialityAllow: rank.expandAllow(f.ConfidentialityAllow),
+	}
+	remaining = f
+	remaining.DocTypes = nil
+	remaining.Projects = nil
+	remaining.ConfidentialityAllow = nil
+	// Date ranges push down only when a bound actually parses; otherwise
+	// the Go-side date_range check keeps its exclude-undated behavior.
+	_, fromOK := parseISODate(f.DateFrom)
+	_, toOK := parseISODate(f.DateTo)
+	if fromOK || toOK {
+		pushed.DateFrom, pushed.DateTo = f.DateFrom, f.DateTo
+		remaining.DateFrom, remaining.DateTo = "", ""
+	}
+	return pushed, remaining
+}
+
+// Candidate is a provider search hit before service-side scoring. A
+// provider fills the raw signals it has: RawBM25 for lexical, RawCosine
+// (with HasCosine) for semantic. Providers whose engine returns a single
+// opaque relevance score set RawFused/HasFused instead and the service
+// uses that one normalized value for both signal slots.
+type Candidate struct {
+	Chunk     IndexedChunk
+	RawBM25   float64
+	RawCosine float64
+	HasCosine bool
+	RawFused  float64
+	HasFused  bool
+	// Vector is the chunk's stored embedding when the provider keeps one;
+	// the service uses it for diversity re-ranking without re-embedding.
+	Vector []float32
+}
+
+// Provider is a search index backend. Implementations must be safe for
+// concurrent Search/FetchChunk calls; Build is exclusive.
+type Provider interface {
+	Name() string
+	Capabilities() ProviderCapabilities
+
+	// Build replaces the index contents with the given chunks.
+	Build(ctx context.Context, chunks []IndexedChunk, info IndexInfo) error
+
+	// Search returns up to opts.TopN candidates for the query.
+	Search(ctx context.Context, query string, opts ProviderSearchOptions) ([]Candidate, error)
+
+	// FetchChunk resolves one chunk by reference.
+	FetchChunk(ctx context.Context, sourcePath string, ordinal int) (*IndexedChunk, error)
+
+	// LoadIndexInfo returns the stored info for the current index, or an
+	// error if no index has been built.
+	LoadIndexInfo(ctx context.Context) (*IndexInfo, error)
+
+	Close() error
+}
+
+// newIndexProvider constructs the configured index provider rooted at the
+// service's state directory.
+func newIndexProvider(stateDir string, cfg config.RAGToolsConfig, embedder Embedder) (Provider, error) {
+	name := cfg.IndexProvider
+	if name == "" {
+		name = "simple"
+	}
+	switch name {
+	case "simple":
+		return newSimpleProvider(filepath.Join(stateDir, "index.json"), embedder, cfg)
+	case "comet":
+		return newCometProvider(stateDir, cfg, embedder)
+	case "bleve":
+		return newBleveProvider(filepath.Join(stateDir, "bleve"))
+	default:
+		return nil, fmt.Errorf("unknown index provider %q", name)
+	}
+}
@@ -0,0 +1,333 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// secretPatterns are redacted from chunk text before it is indexed or
+// surfaced in snippets. The list covers the common credential shapes we
+// have seen leak into knowledge bases.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)api[_-]?key\s*[:=]\s*\S+`),
+	regexp.MustCompile(`(?i)bearer\s+[a-z0-9._\-]{16,}`),
+	regexp.MustCompile(`sk-[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`(?i)password\s*[:=]\s*\S+`),
+}
+
+const secretReplacement = "[REDACTED]"
+
+// secretMaskRule pairs a compiled redaction pattern with its
+// replacement text.
+type secretMaskRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// builtinSecretRules wraps the built-in patterns with the standard
+// redaction marker.
+var builtinSecretRules = func() []secretMaskRule {
+	rules := make([]secretMaskRule, len(secretPatterns))
+	for i, p := range secretPatterns {
+		rules[i] = secretMaskRule{re: p, replacement: secretReplacement}
+	}
+	return rules
+}()
+
+// compileSecretRules returns the effective masking rule set: the
+// built-ins followed by the configured extras. An invalid pattern is a
+// construction error so a bad config fails fast instead of panicking at
+// index time.
+func compileSecretRules(extra []config.SecretMaskRule) ([]secretMaskRule, error) {
+	rules := make([]secretMaskRule, 0, len(builtinSecretRules)+len(extra))
+	rules = append(rules, builtinSecretRules...)
+	for _, r := range extra {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret mask pattern %q: %w", r.Pattern, err)
+		}
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = secretReplacement
+		}
+		rules = append(rules, secretMaskRule{re: re, replacement: replacement})
+	}
+	return rules, nil
+}
+
+// maskSecretsWith applies each rule in order.
+func maskSecretsWith(text string, rules []secretMaskRule) string {
+	for _, r := range rules {
+		text = r.re.ReplaceAllString(text, r.replacement)
+	}
+	return text
+}
+
+// maskSecrets redacts with the built-in rules only; service paths go
+// through Service.maskSecrets so configured extras apply.
+func maskSecrets(text string) string {
+	return maskSecretsWith(text, builtinSecretRules)
+}
+
+// maskSecrets applies the service's effective rule set. It runs at index
+// time so secrets never reach the index, and again on snippets as
+// defense in depth. A zero-value Service falls back to the built-ins.
+func (s *Service) maskSecrets(text string) string {
+	rules := s.secretRules
+	if rules == nil {
+		rules = builtinSecretRules
+	}
+	return maskSecretsWith(text, rules)
+}
+
+// riskRule flags chunk content that looks like a prompt-injection attempt
+// so the service can down-rank it and callers can see why.
+type riskRule struct {
+	substrings []string
+	flag       string
+	weight     float64
+}
+
+var riskRules = []riskRule{
+	{
+		substrings: []string{"ignore previous instructions", "ignore all previous", "disregard the above"},
+		flag:       "instruction_override_attempt",
+		weight:     0.6,
+	},
+	{
+		substrings: []string{"you are now", "new system prompt", "act as the system"},
+		flag:       "persona_override_attempt",
+		weight:     0.4,
+	},
+	{
+		substrings: []string{"call the tool", "invoke the function", "execute the command"},
+		flag:       "tool_call_attempt",
+		weight:     0.3,
+	},
+	{
+		substrings: []string{"do not tell the user", "hide this from the user"},
+		flag:       "policy_override_attempt",
+		weight:     0.5,
+	},
+}
+
+// resolveRiskRules returns the effective detection rule set: the
+// built-ins followed by the configured extras, with substrings
+// normalized once so matching stays cheap. Entries without substrings or
+// a flag are skipped.
+func resolveRiskRules(extra []config.RiskRule) []riskRule {
+	rules := make([]riskRule, 0, len(riskRules)+len(extra))
+	rules = append(rules, riskRules...)
+	for _, r := range extra {
+		if len(r.Substrings) == 0 || r.Flag == "" {
+			continue
+		}
+		subs := make([]string, len(r.Substrings))
+		for i, sub := range r.Substrings {
+			subs[i] = normalizeText(sub)
+		}
+		rules = append(rules, riskRule{substrings: subs, flag: r.Flag, weight: r.Weight})
+	}
+	return rules
+}
+
+// detectInjectionRisk scores how likely a chunk is to be adversarial
+// content aimed at the agent rather than information, using the
+// service's effective rule set (a zero-value Service falls back to the
+// built-ins). The score is the sum of matched rule weights capped at
+// 1.0, with one flag per matched rule. Matching is case-insensitive
+// substring search on normalized text.
+func (s *Service) detectInjectionRisk(text string) (float64, []string) {
+	rules := s.riskRules
+	if rules == nil {
+		rules = riskRules
+	}
+	norm := normalizeText(text)
+	var (
+		score float64
+		flags []string
+	)
+	for _, rule := range rules {
+		for _, sub := range rule.substrings {
+			if strings.Contains(norm, sub) {
+				score += rule.weight
+				flags = append(flags, rule.flag)
+				break
+			}
+		}
+	}
+	if score > 1.0 {
+		score = 1.0
+	}
+	return score, flags
+}
+
+// snippetMaxRunes bounds how much chunk text is surfaced per hit.
+const snippetMaxRunes = 600
+
+// safeSnippet produces the text surfaced for a hit: secret-masked and
+// truncated to the snippet budget on a rune boundary.
+func (s *Service) safeSnippet(text string) string {
+	text = s.maskSecrets(text)
+	runes := []rune(text)
+	if len(runes) <= snippetMaxRunes {
+		return text
+	}
+	return string(runes[:snippetMaxRunes]) + "…"
+}
+
+// highlightSnippet windows the snippet around the densest run of query
+// term matches and returns the term offsets within the returned snippet,
+// so a frontend can bold them. text must already be secret-masked:
+// offsets are computed on the final string and masking afterwards would
+// shift them. Without any match it degrades to plain truncation.
+func highlightSnippet(text string, queryTokens []string) (string, []TermSpan) {
+	runes := []rune(text)
+	matches := termOccurrences(runes, queryTokens)
+	if len(matches) == 0 {
+		if len(runes) <= snippetMaxRunes {
+			return text, nil
+		}
+		return string(runes[:snippetMaxRunes]) + "…", nil
+	}
+
+	// Pick the window covering the most matches, anchored a little before
+	// an occurrence so the first bolded term has leading context. Earliest
+	// window wins ties for determinism.
+	const leadContext = snippetMaxRunes / 6
+	start, best := 0, -1
+	for _, m := range matches {
+		s := m.Start - leadContext
+		if s < 0 {
+			s = 0
+		}
+		count := 0
+		for _, o := range matches {
+			if o.Start >= s && o.End <= s+snippetMaxRunes {
+				count++
+			}
+		}
+		if count > best {
+			start, best = s, count
+		}
+	}
+	end := start + snippetMaxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := string(runes[start:end])
+	offset := -start
+	if start > 0 {
+		snippet = "…" + snippet
+		offset++
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+
+	var spans []TermSpan
+	for _, m := range matches {
+		if m.Start < start || m.End > end {
+			continue
+		}
+		spans = append(spans, TermSpan{Start: m.Start + offset, End: m.End + offset})
+	}
+	return snippet, spans
+}
+
+// termOccurrences scans the runes with the same word boundaries as
+// tokenize and reports every token equal (case-insensitively) to a query
+// token, as [start, end) rune offsets.
+func termOccurrences(runes []rune, queryTokens []string) []TermSpan {
+	want := make(map[string]bool, len(queryTokens))
+	for _, t := range queryTokens {
+		want[t] = true
+	}
+	var spans []TermSpan
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if want[strings.ToLower(string(runes[start:end]))] {
+			spans = append(spans, TermSpan{Start: start, End: end})
+		}
+		start = -1
+	}
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(runes))
+	return spans
+}
+
+// validSnippetFormat reports whether format names a known snippet
+// rendering; empty means raw.
+func validSnippetFormat(format string) bool {
+	switch format {
+	case "", "raw", "plain", "markdown-safe":
+		return true
+	}
+	return false
+}
+
+// fenceRe matches any run of three or more backticks, including fences
+// left open by snippet truncation.
+var fenceRe = regexp.MustCompile("`{3,}")
+
+// whitespaceRe collapses whitespace runs (including newlines) for the
+// single-line format.
+var whitespaceRe = regexp.MustCompile(`\s+`)
+
+// formatSnippet renders one snippet per the requested format. Snippets go
+// straight into LLM prompts, so "markdown-safe" backslash-escapes every
+// fence-capable backtick run — a stray ``` from KB content must not open
+// or close a code block in the surrounding prompt. "plain" additionally
+// flattens the snippet to one line.
+func formatSnippet(text, format string) string {
+	switch format {
+	case "plain":
+		return strings.TrimSpace(whitespaceRe.ReplaceAllString(neutralizeFences(text), " "))
+	case "markdown-safe":
+		return neutralizeFences(text)
+	default:
+		return text
+	}
+}
+
+// neutralizeFences backslash-escapes each backtick in a fence run so the
+// characters survive visibly but markdown no longer treats them as a
+// fence delimiter.
+func neutralizeFences(text string) string {
+	return fenceRe.ReplaceAllStringFunc(text, func(run string) string {
+		var b strings.Builder
+		b.Grow(len(run) * 2)
+		for range run {
+			b.WriteString("\\`")
+		}
+		return b.String()
+	})
+}
+
+// formatSnippets applies formatSnippet across a result set.
+func formatSnippets(items []EvidenceItemFull, format string) {
+	if format == "" || format == "raw" {
+		return
+	}
+	for i := range items {
+		items[i].Snippet = formatSnippet(items[i].Snippet, format)
+	}
+}
@@ -0,0 +1,192 @@
+package rag
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// HTTPHandler exposes the service over HTTP for serve mode:
+//
+//	POST /search  — run a SearchRequest (JSON body), returns SearchResult
+//	GET  /chunk   — fetch one chunk by ?source=&ordinal= or ?paragraph=
+//	POST /index   — rebuild the index, returns IndexInfo
+//	GET  /status  — index status and health, returns IndexStatus
+//
+// Bearer-token auth (ServeAuthToken) and CORS (ServeAllowedOrigins) come
+// from the service configuration; both are off by default, which is only
+// acceptable for loopback deployments.
+func (s *Service) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", s.handleHTTPSearch)
+	mux.HandleFunc("/chunk", s.handleHTTPChunk)
+	mux.HandleFunc("/index", s.handleHTTPIndex)
+	mux.HandleFunc("/status", s.handleHTTPStatus)
+
+	// CORS wraps auth so preflight requests (which carry no Authorization
+	// header) are answered before the token check.
+	var h http.Handler = mux
+	h = s.withAuth(h)
+	h = s.withCORS(h)
+	return h
+}
+
+// withAuth rejects requests without the configured bearer token. A
+// missing token in the config disables the check.
+func (s *Service) withAuth(next http.Handler) http.Handler {
+	token := s.cfg.ServeAuthToken
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeHTTPError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCORS answers preflight requests and sets the allow-origin headers
+// for configured origins. Requests from unlisted origins pass through
+// without CORS headers, so browsers block the response themselves.
+func (s *Service) withCORS(next http.Handler) http.Handler {
+	if len(s.cfg.ServeAllowedOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && s.originAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Service) originAllowed(origin string) bool {
+	for _, o := range s.cfg.ServeAllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) handleHTTPSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	result, err := s.Search(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			w.Header().Set("Retry-After", strconv.Itoa(s.RetryAfterSeconds()))
+			writeHTTPError(w, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, ErrNoIndex):
+			writeHTTPError(w, http.StatusConflict, err.Error())
+		default:
+			writeHTTPError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, result)
+}
+
+func (s *Service) handleHTTPChunk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	var (
+		chunk *IndexedChunk
+		err   error
+	)
+	if paragraph := r.URL.Query().Get("paragraph"); paragraph != "" {
+		chunk, err = s.FetchChunkByParagraphID(r.Context(), paragraph)
+	} else {
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			writeHTTPError(w, http.StatusBadRequest, "source or paragraph parameter required")
+			return
+		}
+		ordinal := 1
+		if o := r.URL.Query().Get("ordinal"); o != "" {
+			if ordinal, err = strconv.Atoi(o); err != nil {
+				writeHTTPError(w, http.StatusBadRequest, "invalid ordinal")
+				return
+			}
+		}
+		chunk, err = s.FetchChunk(r.Context(), source, ordinal)
+	}
+	if err != nil {
+		if errors.Is(err, ErrChunkNotFound) {
+			writeHTTPError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, chunk)
+}
+
+func (s *Service) handleHTTPIndex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	info, err := s.BuildIndex(r.Context())
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, info)
+}
+
+func (s *Service) handleHTTPStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	status, err := s.Status(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrNoIndex) {
+			writeHTTPError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, status)
+}
+
+func writeHTTPJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.WarnCF("rag", "Failed to encode HTTP response", map[string]any{"error": err.Error()})
+	}
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, msg string) {
+	writeHTTPJSON(w, status, map[string]string{"error": msg})
+}
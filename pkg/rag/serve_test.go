@@ -0,0 +1,188 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// newServeTestService is newTestService with serve-specific config knobs.
+func newServeTestService(t *testing.T, cfg config.RAGToolsConfig, files map[string]string) *Service {
+	t.Helper()
+	workspace := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(workspace, "knowledge", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg.IndexProvider = "simple"
+	svc, err := NewService(workspace, cfg)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	if _, err := svc.BuildIndex(context.Background()); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+	return svc
+}
+
+func TestHTTPSearch(t *testing.T) {
+	svc := newServeTestService(t, config.RAGToolsConfig{}, map[string]string{
+		"deploy.md": doc("Deploy", "2026-01-01", "deployment checklist and rollback steps"),
+	})
+	ts := httptest.NewServer(svc.HTTPHandler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/search", "application/json",
+		strings.NewReader(`{"query":"deployment checklist"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/chunk?source=deploy.md&ordinal=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("chunk status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/chunk?source=missing.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing chunk status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPAuth(t *testing.T) {
+	svc := newServeTestService(t, config.RAGToolsConfig{ServeAuthToken: "sesame"}, map[string]string{
+		"a.md": doc("A", "2026-01-01", "guarded content"),
+	})
+	ts := httptest.NewServer(svc.HTTPHandler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/search", "application/json",
+		strings.NewReader(`{"query":"guarded"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without token status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/search",
+		strings.NewReader(`{"query":"guarded"}`))
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong token status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/search",
+		strings.NewReader(`{"query":"guarded"}`))
+	req.Header.Set("Authorization", "Bearer sesame")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("valid token status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestHTTPCORS(t *testing.T) {
+	svc := newServeTestService(t, config.RAGToolsConfig{
+		ServeAuthToken:      "sesame",
+		ServeAllowedOrigins: []string{"https://ui.example"},
+	}, map[string]string{
+		"a.md": doc("A", "2026-01-01", "content"),
+	})
+	ts := httptest.NewServer(svc.HTTPHandler())
+	defer ts.Close()
+
+	// Preflight must succeed without a token.
+	req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/search", nil)
+	req.Header.Set("Origin", "https://ui.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("preflight status = %d, want 204", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://ui.example" {
+		t.Errorf("allow-origin = %q", got)
+	}
+
+	// Unlisted origins get no CORS headers.
+	req, _ = http.NewRequest(http.MethodOptions, ts.URL+"/search", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected allow-origin %q for unlisted origin", got)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	svc := newServeTestService(t, config.RAGToolsConfig{}, map[string]string{
+		"deploy.md": doc("Deploy", "2026-01-01", "deployment checklist and rollback steps"),
+	})
+	ts := httptest.NewServer(svc.HTTPHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var status IndexStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Provider != "simple" || status.TotalDocuments != 1 {
+		t.Errorf("status = %+v", status)
+	}
+
+	resp, err = http.Post(ts.URL+"/status", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,1827 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// defaultTopK is returned when a request does not ask for a count.
+	defaultTopK = 8
+	// maxServiceTopK is the library-level ceiling on result count.
+	maxServiceTopK = 100
+	// defaultQueueSize bounds concurrent searches before callers are told
+	// to back off.
+	defaultQueueSize = 8
+	// riskPenaltyWeight scales how strongly injection-risk down-ranks a
+	// chunk: final *= 1 - riskPenaltyWeight*RiskScore.
+	riskPenaltyWeight = 0.2
+	// rrfK is the standard reciprocal-rank-fusion constant.
+	rrfK = 60
+	// acronymBoostWeight scales the exact-case bonus for all-caps query
+	// tokens when AcronymBoost is enabled.
+	acronymBoostWeight = 0.15
+)
+
+// Service is the retrieval front door: it owns the index provider, the
+// optional embedder, profile resolution and the scoring pipeline. All
+// entry points (CLI, agent tool, HTTP) go through it.
+type Service struct {
+	workspace string
+	kbRoots   []kbRootDir
+	stateDir  string
+	cfg       config.RAGToolsConfig
+	embedder  Embedder
+	provider  Provider
+	maxTopK   int
+	// tokenizer backs the empty-query guard: the configured tokenizer
+	// wrapped with stopword and minimum-length filtering, in lockstep
+	// with the lexical scoring tokenizer.
+	tokenizer func(string) []string
+
+	// boilerplate holds the compiled BoilerplatePatterns stripped from
+	// document bodies before chunking.
+	boilerplate []*regexp.Regexp
+	// secretRules is the effective masking rule set: built-ins plus any
+	// configured SecretMaskRules.
+	secretRules []secretMaskRule
+	// riskRules is the effective injection-risk rule set: built-ins plus
+	// any configured RiskRules.
+	riskRules []riskRule
+	// indexExts is the normalized set of file extensions indexed and
+	// watched; nil means the default [".md"].
+	indexExts []string
+	// confRank is the configured confidentiality ordering behind the
+	// ceiling filters and the restricted hard gate.
+	confRank confidentialityRank
+
+	// readOnly rejects every index-mutating entry point with ErrReadOnly;
+	// set by NewReadOnlyService for query replicas over a shared index.
+	readOnly bool
+
+	// q bounds concurrent searches; a full queue rejects rather than
+	// piling up latency.
+	q chan struct{}
+
+	// closing flips once Shutdown begins; beginQueued then rejects new
+	// requests while in-flight ones drain.
+	closing atomic.Bool
+
+	// resultCache, when enabled via WithResultCache, serves repeated
+	// requests without touching the provider or the search queue.
+	resultCache *resultCache
+
+	// expander, when set, rewrites queries before retrieval (synonym or
+	// acronym expansion).
+	expander QueryExpander
+
+	// log receives the service's structured log events; stdRAGLogger
+	// unless WithLogger injected a sink.
+	log RAGLogger
+}
+
+// ServiceOption customizes a Service at construction.
+type ServiceOption func(*Service)
+
+// WithResultCache enables an LRU cache of complete search results, keyed
+// by the normalized request and the index version (so rebuilds
+// invalidate automatically). Cache hits bypass the search queue. A ttl
+// of 0 means entries only age out by eviction.
+func WithResultCache(size int, ttl time.Duration) ServiceOption {
+	return func(s *Service) {
+		if size > 0 {
+			s.resultCache = newResultCache(size, ttl)
+		}
+	}
+}
+
+// kbRootDir is one knowledge base root: its on-disk path plus the label
+// prefixed onto indexed paths when several roots are configured. The
+// single-root case keeps an empty label so existing indexes stay valid.
+type kbRootDir struct {
+	label string
+	path  string
+}
+
+// resolveKBRoots expands the configured roots: KBRoot as the
+// single-value shortcut plus every KBRoots entry, each made absolute
+// against the workspace. With several roots each gets its directory
+// base name as label, deduplicated with a numeric suffix, in
+// configuration order so labels are deterministic.
+func resolveKBRoots(workspace string, cfg config.RAGToolsConfig) []kbRootDir {
+	paths := make([]string, 0, len(cfg.KBRoots)+1)
+	if cfg.KBRoot != "" {
+		paths = append(paths, cfg.KBRoot)
+	}
+	paths = append(paths, cfg.KBRoots...)
+	if len(paths) == 0 {
+		paths = []string{"knowledge"}
+	}
+
+	roots := make([]kbRootDir, 0, len(paths))
+	taken := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		abs := p
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(workspace, abs)
+		}
+		var label string
+		if len(paths) > 1 {
+			base := filepath.Base(filepath.Clean(p))
+			label = base
+			for i := 2; taken[label]; i++ {
+				label = fmt.Sprintf("%s-%d", base, i)
+			}
+			taken[label] = true
+		}
+		roots = append(roots, kbRootDir{label: label, path: abs})
+	}
+	return roots
+}
+
+// kbPathFor resolves an indexed source path back to disk; the label
+// prefix selects the root when several are configured. An unknown label
+// falls through to the first root so the caller's read fails with a
+// clear not-exist error.
+func (s *Service) kbPathFor(relPath string) string {
+	if len(s.kbRoots) > 1 {
+		for _, root := range s.kbRoots {
+			if rest, ok := strings.CutPrefix(relPath, root.label+"/"); ok {
+				return filepath.Join(root.path, filepath.FromSlash(rest))
+			}
+		}
+	}
+	return filepath.Join(s.kbRoots[0].path, filepath.FromSlash(relPath))
+}
+
+// relToKBPath maps an absolute path to its indexed form: relative to the
+// containing root, label-prefixed when several roots are configured. ok
+// is false for paths outside every root.
+func (s *Service) relToKBPath(abs string) (string, bool) {
+	for _, root := range s.kbRoots {
+		rel, err := filepath.Rel(root.path, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if root.label != "" {
+			rel = root.label + "/" + rel
+		}
+		return rel, true
+	}
+	return "", false
+}
+
+// NewService constructs a Service for the given workspace. A missing
+// embedding configuration is not an error: the service runs keyword-only
+// and degrades hybrid requests with a note.
+func NewService(workspace string, cfg config.RAGToolsConfig, opts ...ServiceOption) (*Service, error) {
+	return newService(workspace, cfg, false, opts...)
+}
+
+// NewReadOnlyService constructs a Service that only queries an existing
+// index: searches and chunk fetches work identically to NewService, but
+// every index-mutating entry point returns ErrReadOnly and no model
+// auto-pull is attempted. Meant for query replicas pointing at a shared
+// index directory.
+func NewReadOnlyService(workspace string, cfg config.RAGToolsConfig, opts ...ServiceOption) (*Service, error) {
+	return newService(workspace, cfg, true, opts...)
+}
+
+// newService is the shared constructor behind NewService and
+// NewReadOnlyService.
+func newService(workspace string, cfg config.RAGToolsConfig, readOnly bool, opts ...ServiceOption) (*Service, error) {
+	kbRoots := resolveKBRoots(workspace, cfg)
+
+	embedder, err := newEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if embedder != nil && !readOnly {
+		ollamaPullIfNeeded(context.Background(), cfg)
+	}
+	embedder = wrapQueryCache(embedder, cfg.QueryEmbedCacheSize)
+
+	stateDir := filepath.Join(workspace, "state", "rag")
+	provider, err := newIndexProvider(stateDir, cfg, embedder)
+	if err != nil {
+		return nil, err
+	}
+
+	boilerplate := make([]*regexp.Regexp, 0, len(cfg.BoilerplatePatterns))
+	for _, pattern := range cfg.BoilerplatePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boilerplate pattern %q: %w", pattern, err)
+		}
+		boilerplate = append(boilerplate, re)
+	}
+
+	secretRules, err := compileSecretRules(cfg.SecretMaskRules)
+	if err != nil {
+		return nil, err
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	maxTopK := cfg.MaxTopK
+	if maxTopK <= 0 || maxTopK > maxServiceTopK {
+		maxTopK = maxServiceTopK
+	}
+
+	s := &Service{
+		workspace:   workspace,
+		kbRoots:     kbRoots,
+		stateDir:    stateDir,
+		cfg:         cfg,
+		embedder:    embedder,
+		provider:    provider,
+		maxTopK:     maxTopK,
+		tokenizer:   tokenizeFiltered(tokenizerFor(cfg.CJKBigrams), cfg.Stopwords, cfg.MinTokenLength),
+		boilerplate: boilerplate,
+		secretRules: secretRules,
+		riskRules:   resolveRiskRules(cfg.RiskRules),
+		indexExts:   resolveIndexExtensions(cfg.IndexExtensions),
+		confRank:    resolveConfidentialityRank(cfg.ConfidentialityLevels),
+		readOnly:    readOnly,
+		q:           make(chan struct{}, queueSize),
+		log:         stdRAGLogger{},
+	}
+	if len(cfg.QuerySynonyms) > 0 {
+		s.expander = NewSynonymExpander(cfg.QuerySynonyms)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(cfg.SecretMaskRules) > 0 {
+		s.log.Info("Custom secret mask rules loaded", map[string]any{
+			"custom":    len(cfg.SecretMaskRules),
+			"effective": len(secretRules),
+		})
+	}
+	return s, nil
+}
+
+// Close releases the provider.
+func (s *Service) Close() error {
+	return s.provider.Close()
+}
+
+// Compact asks the provider to reclaim disk space (free-page
+// fragmentation from repeated rebuilds, orphaned temp files). Providers
+// without on-disk state to vacuum report an error. It returns the bytes
+// reclaimed.
+func (s *Service) Compact() (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	c, ok := s.provider.(interface{ Compact() (int64, error) })
+	if !ok {
+		return 0, fmt.Errorf("index provider %s does not support compaction", s.provider.Name())
+	}
+	reclaimed, err := c.Compact()
+	if err != nil {
+		return 0, err
+	}
+	s.log.Info("Index compacted",
+		map[string]any{"provider": s.provider.Name(), "reclaimed_bytes": reclaimed})
+	return reclaimed, nil
+}
+
+// AvailableModes lists the retrieval modes the provider can actually
+// serve right now: semantic and hybrid require an embedder, so a
+// keyword-only setup advertises only keyword instead of silently
+// degrading requests.
+func (s *Service) AvailableModes() []string {
+	if s.provider.Capabilities().Semantic {
+		return []string{"keyword", "semantic", "hybrid"}
+	}
+	return []string{"keyword"}
+}
+
+// IsQueueFull reports whether a new search would be rejected right now.
+func (s *Service) IsQueueFull() bool {
+	return len(s.q) == cap(s.q)
+}
+
+// RetryAfterSeconds is the suggested client backoff when the queue is full.
+func (s *Service) RetryAfterSeconds() int {
+	return 5
+}
+
+// beginQueued takes a queue slot or fails fast with ErrQueueFull.
+// The returned func releases the slot.
+func (s *Service) beginQueued() (func(), error) {
+	if s.closing.Load() {
+		return nil, ErrShuttingDown
+	}
+	select {
+	case s.q <- struct{}{}:
+		return func() { <-s.q }, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// shutdownPollInterval is how often Shutdown re-checks the in-flight
+// count while draining.
+const shutdownPollInterval = 20 * time.Millisecond
+
+// Shutdown stops accepting new searches, waits for in-flight ones to
+// finish (bounded by ctx), flushes any dirty provider state and closes
+// the provider. Serve mode's signal handler calls it so a SIGTERM
+// neither drops requests nor leaves unsynced index writes behind.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.closing.Store(true)
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for len(s.q) > 0 {
+		select {
+		case <-ctx.Done():
+			s.log.Warn("Shutdown drain timed out",
+				map[string]any{"in_flight": len(s.q)})
+			_ = s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if f, ok := s.provider.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			s.log.Warn("Shutdown flush failed", map[string]any{"error": err.Error()})
+		}
+	}
+	return s.Close()
+}
+
+// providerMarkerFile records which provider last built an index in the
+// state directory. Every provider keeps its own on-disk artifacts, so
+// after an IndexProvider config change the old provider's stale index
+// would silently resurface; the marker lets searches fail clearly
+// instead.
+const providerMarkerFile = "provider"
+
+// checkProviderMatch fails when the state directory was last built by a
+// different provider than the one configured now. Building again clears
+// the mismatch.
+func (s *Service) checkProviderMatch() error {
+	data, err := os.ReadFile(filepath.Join(s.stateDir, providerMarkerFile))
+	if err != nil {
+		return nil // no marker: nothing built yet, or a pre-marker index
+	}
+	built := strings.TrimSpace(string(data))
+	if built == "" || built == s.provider.Name() {
+		return nil
+	}
+	return fmt.Errorf("index was built with provider %q but %q is configured; rebuild the index or clear %s",
+		built, s.provider.Name(), s.stateDir)
+}
+
+// BuildIndex walks the knowledge base, chunks all documents and replaces
+// the provider's index.
+func (s *Service) BuildIndex(ctx context.Context) (*IndexInfo, error) {
+	return s.buildIndex(ctx, nil, time.Time{})
+}
+
+// BuildIndexSince rebuilds like BuildIndex but skips re-chunking
+// documents whose mtime predates since and whose content still hashes to
+// the DocumentVersion stored on their chunks — those are reused from the
+// current index, so the result covers the whole KB, not just recent
+// files. Files modified at or after since always re-chunk, and so do old
+// files whose stored version no longer matches (a stale mtime never
+// hides a content change). Intended for frequent incremental passes
+// between full rebuilds on large knowledge bases.
+func (s *Service) BuildIndexSince(ctx context.Context, since time.Time) (*IndexInfo, error) {
+	return s.buildIndex(ctx, nil, since)
+}
+
+// buildIndex is the shared build pipeline; emit, when non-nil, receives
+// typed progress events as the build moves through its phases. A
+// non-zero since enables document reuse (see BuildIndexSince).
+func (s *Service) buildIndex(ctx context.Context, emit func(BuildEvent), since time.Time) (*IndexInfo, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if emit == nil {
+		emit = func(BuildEvent) {}
+	}
+	start := time.Now()
+	var reuse map[string][]IndexedChunk
+	if !since.IsZero() {
+		var err error
+		reuse, err = s.reusableDocuments(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chunks, info, err := s.buildChunksAndInfo(emit, since, reuse)
+	if err != nil {
+		return nil, err
+	}
+
+	info.IndexProvider = s.provider.Name()
+	info.BuiltAt = time.Now().UTC()
+	info.IndexVersion = indexVersion(chunks, info.ChunkingHash)
+	if s.embedder != nil {
+		info.EmbeddingModelID = s.embedder.ModelID()
+	}
+
+	if pb, ok := s.provider.(progressBuilder); ok {
+		err = pb.BuildWithProgress(ctx, chunks, info, emit)
+	} else {
+		emit(BuildEvent{Phase: PhaseFlushing, Documents: info.TotalDocuments, Chunks: len(chunks)})
+		err = s.provider.Build(ctx, chunks, info)
+	}
+	if err != nil {
+		return nil, err
+	}
+	emit(BuildEvent{Phase: PhaseDone, Documents: info.TotalDocuments, Chunks: len(chunks)})
+
+	if err := os.WriteFile(filepath.Join(s.stateDir, providerMarkerFile),
+		[]byte(s.provider.Name()+"\n"), 0o644); err != nil {
+		s.log.Warn("Failed to write provider marker", map[string]any{"error": err.Error()})
+	}
+
+	s.log.Info("Index built",
+		map[string]any{
+			"documents": info.TotalDocuments,
+			"chunks":    info.TotalChunks,
+			"provider":  info.IndexProvider,
+			"warnings":  len(info.Warnings),
+			"duration":  time.Since(start).String(),
+		})
+	return &info, nil
+}
+
+// reusableDocuments loads the current index grouped by document, for a
+// time-bounded build to reuse. No index yet means nothing to reuse, and
+// so does an index chunked under different parameters: reusing its
+// chunks would resurrect the old chunking for every untouched document.
+func (s *Service) reusableDocuments(ctx context.Context) (map[string][]IndexedChunk, error) {
+	it, ok := s.provider.(chunkIterator)
+	if !ok {
+		return nil, fmt.Errorf("index provider %s does not support time-bounded rebuilds", s.provider.Name())
+	}
+	info, err := s.provider.LoadIndexInfo(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	if info.ChunkingHash != "" && info.ChunkingHash != s.chunkingHash() {
+		return nil, nil
+	}
+	bySource := make(map[string][]IndexedChunk)
+	if err := it.ForEachChunk(func(_ int, c IndexedChunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bySource[c.SourcePath] = append(bySource[c.SourcePath], c)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return bySource, nil
+}
+
+// documentReplacer is implemented by providers that can swap or drop a
+// single document's chunks without a full rebuild.
+type documentReplacer interface {
+	ReplaceDocument(ctx context.Context, sourcePath string, chunks []IndexedChunk) error
+	RemoveDocument(ctx context.Context, sourcePath string) error
+}
+
+// ReindexFile re-chunks one document and replaces just its chunks in the
+// index, skipping the full KB walk BuildIndex does. A missing or
+// empty-after-chunking file removes the document. Providers without
+// in-place replacement report an error; callers fall back to BuildIndex.
+func (s *Service) ReindexFile(ctx context.Context, relPath string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	dr, ok := s.provider.(documentReplacer)
+	if !ok {
+		return fmt.Errorf("index provider %s does not support incremental reindex", s.provider.Name())
+	}
+
+	relPath = filepath.ToSlash(filepath.Clean(filepath.FromSlash(relPath)))
+	if filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return fmt.Errorf("path %s is outside the knowledge base", relPath)
+	}
+	full := s.kbPathFor(relPath)
+
+	raw, err := os.ReadFile(full)
+	if os.IsNotExist(err) {
+		return dr.RemoveDocument(ctx, relPath)
+	}
+	if err != nil {
+		return err
+	}
+	hardBytes := s.cfg.DocumentHardBytes
+	if hardBytes <= 0 {
+		hardBytes = defaultDocumentHardBytes
+	}
+	if int64(len(raw)) > hardBytes {
+		return fmt.Errorf("document %s exceeds the size limit", relPath)
+	}
+
+	chunks, _ := s.chunkDocument(relPath, string(raw))
+	if len(chunks) == 0 {
+		return dr.RemoveDocument(ctx, relPath)
+	}
+	if err := dr.ReplaceDocument(ctx, relPath, chunks); err != nil {
+		return err
+	}
+	s.log.Info("Reindexed document",
+		map[string]any{"source": relPath, "chunks": len(chunks)})
+	return nil
+}
+
+// RemoveDocument drops one document's chunks from the index without
+// touching the rest. The watcher uses it for deletes and renames so
+// vanished files leave search right away instead of waiting for a full
+// rebuild. Providers without in-place replacement report an error;
+// callers fall back to BuildIndex.
+func (s *Service) RemoveDocument(ctx context.Context, relPath string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	dr, ok := s.provider.(documentReplacer)
+	if !ok {
+		return fmt.Errorf("index provider %s does not support incremental reindex", s.provider.Name())
+	}
+	relPath = filepath.ToSlash(filepath.Clean(filepath.FromSlash(relPath)))
+	if filepath.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return fmt.Errorf("path %s is outside the knowledge base", relPath)
+	}
+	if err := dr.RemoveDocument(ctx, relPath); err != nil {
+		return err
+	}
+	s.log.Info("Removed document", map[string]any{"source": relPath})
+	return nil
+}
+
+// indexVersion derives a deterministic version id from the chunk set so
+// identical content yields identical versions.
+func indexVersion(chunks []IndexedChunk, chunkingHash string) string {
+	var b strings.Builder
+	b.WriteString(chunkingHash)
+	for i := range chunks {
+		b.WriteString(chunks[i].ParagraphID)
+	}
+	return sha256Hex([]byte(b.String()))[:16]
+}
+
+// FetchChunk resolves one chunk by reference, with snippet-level secret
+// masking applied to the returned text.
+func (s *Service) FetchChunk(ctx context.Context, sourcePath string, ordinal int) (*IndexedChunk, error) {
+	chunk, err := s.provider.FetchChunk(ctx, sourcePath, ordinal)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Text = s.maskSecrets(chunk.Text)
+	return chunk, nil
+}
+
+// rangeFetcher is implemented by providers that can fetch a contiguous
+// ordinal range of one document more cheaply than per-ordinal fetches
+// (comet: a single store scan).
+type rangeFetcher interface {
+	FetchChunkRange(ctx context.Context, sourcePath string, fromOrdinal, toOrdinal int) ([]IndexedChunk, error)
+}
+
+// FetchChunkWithContext resolves a chunk together with its neighbors:
+// every ordinal in [ordinal-radius, ordinal+radius] that exists for the
+// document, in document order. Neighbors missing at document boundaries
+// are simply omitted; the requested ordinal itself must exist. Secret
+// masking applies to every returned chunk, as in FetchChunk.
+func (s *Service) FetchChunkWithContext(ctx context.Context, sourcePath string, ordinal, radius int) ([]IndexedChunk, error) {
+	if radius < 0 {
+		radius = 0
+	}
+	from, to := ordinal-radius, ordinal+radius
+	if from < 1 {
+		from = 1
+	}
+
+	var chunks []IndexedChunk
+	if rf, ok := s.provider.(rangeFetcher); ok {
+		var err error
+		chunks, err = rf.FetchChunkRange(ctx, sourcePath, from, to)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for o := from; o <= to; o++ {
+			chunk, err := s.provider.FetchChunk(ctx, sourcePath, o)
+			if err != nil {
+				if errors.Is(err, ErrChunkNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			chunks = append(chunks, *chunk)
+		}
+	}
+
+	found := false
+	for i := range chunks {
+		chunks[i].Text = s.maskSecrets(chunks[i].Text)
+		if chunks[i].ChunkOrdinal == ordinal {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("chunk %s#%d: %w", sourcePath, ordinal, ErrChunkNotFound)
+	}
+	return chunks, nil
+}
+
+// paragraphFetcher is implemented by providers that can resolve a chunk
+// by its stable ParagraphID.
+type paragraphFetcher interface {
+	FetchChunkByParagraphID(ctx context.Context, paragraphID string) (*IndexedChunk, error)
+}
+
+// FetchChunkByParagraphID resolves one chunk by its stable content id.
+// Ordinals shift when a document is edited near the top; the ParagraphID
+// survives as long as the paragraph does, so external citations stay
+// durable across rebuilds.
+func (s *Service) FetchChunkByParagraphID(ctx context.Context, paragraphID string) (*IndexedChunk, error) {
+	pf, ok := s.provider.(paragraphFetcher)
+	if !ok {
+		return nil, fmt.Errorf("index provider %s cannot resolve chunks by paragraph id", s.provider.Name())
+	}
+	chunk, err := pf.FetchChunkByParagraphID(ctx, paragraphID)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Text = s.maskSecrets(chunk.Text)
+	return chunk, nil
+}
+
+// IndexInfo returns the stored info (provenance, warnings, corpus
+// statistics) for the current index.
+func (s *Service) IndexInfo(ctx context.Context) (*IndexInfo, error) {
+	return s.provider.LoadIndexInfo(ctx)
+}
+
+// IndexStatus is the operator-facing health summary of the current
+// index, shared by the CLI status command and HTTP callers.
+type IndexStatus struct {
+	Provider       string    `json:"provider"`
+	IndexVersion   string    `json:"index_version"`
+	BuiltAt        time.Time `json:"built_at"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	TotalDocuments int       `json:"total_documents"`
+	TotalChunks    int       `json:"total_chunks"`
+	Warnings       []string  `json:"warnings,omitempty"`
+	// StructuredWarnings classifies Warnings by severity; derived on the
+	// fly so indexes built before classification existed still get one.
+	StructuredWarnings []Warning `json:"structured_warnings,omitempty"`
+	// Dirty, HasVectors and VectorDims come from providers with mutable
+	// on-disk state (comet); other providers leave them zero.
+	Dirty      bool `json:"dirty,omitempty"`
+	HasVectors bool `json:"has_vectors,omitempty"`
+	VectorDims int  `json:"vector_dims,omitempty"`
+}
+
+// Status summarizes the current index for health checks: provenance from
+// LoadIndexInfo plus any provider-specific store state.
+func (s *Service) Status(ctx context.Context) (*IndexStatus, error) {
+	info, err := s.provider.LoadIndexInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st := &IndexStatus{
+		Provider:           info.IndexProvider,
+		IndexVersion:       info.IndexVersion,
+		BuiltAt:            info.BuiltAt,
+		EmbeddingModel:     info.EmbeddingModelID,
+		TotalDocuments:     info.TotalDocuments,
+		TotalChunks:        info.TotalChunks,
+		Warnings:           info.Warnings,
+		StructuredWarnings: ClassifyWarnings(info.Warnings),
+	}
+	if sr, ok := s.provider.(interface {
+		StoreStatus() (dirty, hasVectors bool, vectorDims int)
+	}); ok {
+		st.Dirty, st.HasVectors, st.VectorDims = sr.StoreStatus()
+	}
+	return st, nil
+}
+
+// Search runs the full retrieval pipeline for one request: provider
+// candidates, filtering, signal normalization, profile-weighted scoring,
+// risk penalty, threshold, per-source capping and top-k selection.
+func (s *Service) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	// Expand before validation so the empty-query guard sees the final
+	// form, and before cache lookup so the key reflects what actually ran.
+	origQuery := req.Query
+	if s.expander != nil {
+		expanded, err := s.expander.Expand(ctx, req.Query)
+		if err != nil {
+			return nil, fmt.Errorf("query expansion: %w", err)
+		}
+		req.Query = expanded
+	}
+	if err := s.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+	matchNote, err := s.checkIndexMatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache hits bypass the queue entirely: a cached answer costs no
+	// provider work, so it should not compete for a slot either.
+	cacheKey := s.cacheKeyFor(ctx, req)
+	if cached, ok := s.cacheGet(cacheKey); ok {
+		return cached, nil
+	}
+
+	release, err := s.beginQueued()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := s.searchUnqueued(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Query != origQuery {
+		result.Notes = append(result.Notes,
+			fmt.Sprintf("query expanded: %q -> %q", origQuery, req.Query))
+	}
+	if matchNote != "" {
+		result.Notes = append(result.Notes, matchNote)
+	}
+	s.cachePut(cacheKey, result)
+	return result, nil
+}
+
+// checkIndexMatch compares the loaded index's provenance against the
+// service's current configuration. A stale index still answers — with a
+// prominent note — because a degraded answer beats none; StrictIndexMatch
+// upgrades the mismatch to a hard error for deployments that would
+// rather fail than serve results chunked or embedded differently than
+// configured. A missing index is not a mismatch: the search surfaces
+// ErrNoIndex on its own.
+func (s *Service) checkIndexMatch(ctx context.Context) (string, error) {
+	info, err := s.provider.LoadIndexInfo(ctx)
+	if err != nil {
+		return "", nil
+	}
+	var stale []string
+	if info.ChunkingHash != "" && info.ChunkingHash != s.chunkingHash() {
+		stale = append(stale, "chunking parameters")
+	}
+	var modelID string
+	if s.embedder != nil {
+		modelID = s.embedder.ModelID()
+	}
+	if info.EmbeddingModelID != modelID {
+		stale = append(stale, "embedding model")
+	}
+	if len(stale) == 0 {
+		return "", nil
+	}
+	msg := fmt.Sprintf("index built with different %s; rebuild recommended (picoclaw rag index)",
+		strings.Join(stale, " and "))
+	if s.cfg.StrictIndexMatch {
+		return "", errors.New(msg)
+	}
+	return msg, nil
+}
+
+// validateSearchRequest is the cheap up-front rejection shared by Search
+// and SearchBatch: it runs before any queue or cache interaction.
+func (s *Service) validateSearchRequest(req SearchRequest) error {
+	if len(s.tokenizer(req.Query)) == 0 {
+		return fmt.Errorf("query has no searchable tokens")
+	}
+	if !validSnippetFormat(req.SnippetFormat) {
+		return fmt.Errorf("unknown snippet format %q (want raw, plain or markdown-safe)", req.SnippetFormat)
+	}
+	switch req.Granularity {
+	case "", "chunk", "document":
+	default:
+		return fmt.Errorf("unknown granularity %q (want chunk or document)", req.Granularity)
+	}
+	return s.checkProviderMatch()
+}
+
+// cacheKeyFor derives the result-cache key for a request, or "" when
+// caching is disabled.
+func (s *Service) cacheKeyFor(ctx context.Context, req SearchRequest) string {
+	if s.resultCache == nil {
+		return ""
+	}
+	var version string
+	if info, err := s.provider.LoadIndexInfo(ctx); err == nil {
+		version = info.IndexVersion
+	}
+	return resultCacheKey(req, version)
+}
+
+func (s *Service) cacheGet(key string) (*SearchResult, bool) {
+	if s.resultCache == nil || key == "" {
+		return nil, false
+	}
+	return s.resultCache.get(key)
+}
+
+func (s *Service) cachePut(key string, result *SearchResult) {
+	if s.resultCache != nil && key != "" {
+		s.resultCache.put(key, result)
+	}
+}
+
+// searchUnqueued runs the retrieval pipeline without queue accounting or
+// caching; the caller owns the queue slot.
+func (s *Service) searchUnqueued(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	topK := s.clampTopK(req.TopK)
+	start := time.Now()
+	var tm searchTimings
+
+	// Document granularity collapses chunk hits afterwards, so rank
+	// generously first: topK documents can need far more than topK chunks.
+	runK := topK
+	if req.Granularity == "document" {
+		runK = s.maxTopK
+	}
+
+	// Profile blending: score under each profile and fuse with RRF.
+	if len(req.BlendProfiles) >= 2 {
+		result, err := s.searchBlended(ctx, req, runK, &tm)
+		if err != nil {
+			return nil, err
+		}
+		if req.Granularity == "document" {
+			result.Items = aggregateByDocument(result.Items, topK)
+		}
+		formatSnippets(result.Items, req.SnippetFormat)
+		if req.ReturnParent {
+			s.attachParents(ctx, result)
+		}
+		total := time.Since(start)
+		result.Timing = tm.timing(total)
+		if fields := s.slowSearchFields(req.Query, result.Mode, total, tm); fields != nil {
+			s.log.Warn("Slow search", fields)
+		}
+		return result, nil
+	}
+
+	profile, err := resolveProfile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	items, mode, notes, err := s.runProfile(ctx, req, profile, runK, &tm)
+	if err != nil {
+		return nil, err
+	}
+	if req.Granularity == "document" {
+		items = aggregateByDocument(items, topK)
+	}
+
+	result := &SearchResult{
+		Query:     req.Query,
+		Mode:      mode,
+		ProfileID: profile.ID,
+		Items:     items,
+		Notes:     notes,
+	}
+	if info, err := s.provider.LoadIndexInfo(ctx); err == nil {
+		result.IndexVersion = info.IndexVersion
+	}
+	formatSnippets(result.Items, req.SnippetFormat)
+	if req.ReturnParent {
+		s.attachParents(ctx, result)
+	}
+	total := time.Since(start)
+	result.Timing = tm.timing(total)
+	if fields := s.slowSearchFields(req.Query, mode, total, tm); fields != nil {
+		s.log.Warn("Slow search", fields)
+	}
+	return result, nil
+}
+
+// searchBlended runs the request under every listed profile and fuses the
+// per-profile rankings with reciprocal rank fusion. Fused items keep the
+// breakdown from the first profile that ranked them; the fused RRF score
+// replaces FinalScore.
+func (s *Service) searchBlended(ctx context.Context, req SearchRequest, topK int, tm *searchTimings) (*SearchResult, error) {
+	type fused struct {
+		item  EvidenceItemFull
+		score float64
+	}
+	byRef := make(map[ChunkRef]*fused)
+	var notes []string
+	var lastMode string
+
+	for _, id := range req.BlendProfiles {
+		profile, err := resolveProfile(id)
+		if err != nil {
+			return nil, err
+		}
+		// Rank generously per profile so fusion sees beyond any single
+		// profile's top-k.
+		items, mode, profNotes, err := s.runProfile(ctx, req, profile, s.maxTopK, tm)
+		if err != nil {
+			return nil, err
+		}
+		lastMode = mode
+		notes = append(notes, profNotes...)
+		for rank, item := range items {
+			f := byRef[item.Ref]
+			if f == nil {
+				f = &fused{item: item}
+				byRef[item.Ref] = f
+			}
+			f.score += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	all := make([]*fused, 0, len(byRef))
+	for _, f := range byRef {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return lessRef(all[i].item.Ref, all[j].item.Ref)
+	})
+	if len(all) > topK {
+		all = all[:topK]
+	}
+
+	items := make([]EvidenceItemFull, 0, len(all))
+	for _, f := range all {
+		f.item.Breakdown.FinalScore = f.score
+		items = append(items, f.item)
+	}
+
+	result := &SearchResult{
+		Query:     req.Query,
+		Mode:      lastMode,
+		ProfileID: "blend(" + strings.Join(req.BlendProfiles, "+") + ")",
+		Items:     items,
+		Notes: append(notes,
+			fmt.Sprintf("blended profiles via RRF: %s", strings.Join(req.BlendProfiles, ", "))),
+	}
+	if info, err := s.provider.LoadIndexInfo(ctx); err == nil {
+		result.IndexVersion = info.IndexVersion
+	}
+	return result, nil
+}
+
+// aggregateByDocument collapses ranked chunk hits into one item per
+// document for document-granularity requests. Items arrive in score
+// order, so the first hit per source is the document's best chunk: it
+// keeps its snippet and breakdown, its score is the document's (max
+// rule, so the breakdown still explains the score), and MatchedChunks
+// records how many ranked chunks it absorbed.
+func aggregateByDocument(items []EvidenceItemFull, topK int) []EvidenceItemFull {
+	bySource := make(map[string]int, len(items))
+	out := make([]EvidenceItemFull, 0, len(items))
+	for _, item := range items {
+		if i, ok := bySource[item.Ref.SourcePath]; ok {
+			out[i].MatchedChunks++
+			continue
+		}
+		item.MatchedChunks = 1
+		bySource[item.Ref.SourcePath] = len(out)
+		out = append(out, item)
+	}
+	if len(out) > topK {
+		out = out[:topK]
+	}
+	return out
+}
+
+// parentTextMaxRunes caps the assembled parent section so a single hit in
+// a huge section cannot balloon the evidence pack.
+const parentTextMaxRunes = 4000
+
+// attachParents fills ParentText for every hit: the concatenation, in
+// document order, of all chunks sharing the hit's ParentID ("small-to-big"
+// retrieval: the child chunk matched, the whole section is the context).
+// Failures to resolve a parent degrade to a note rather than failing the
+// search.
+func (s *Service) attachParents(ctx context.Context, result *SearchResult) {
+	byDoc := make(map[string][]IndexedChunk)
+	for i := range result.Items {
+		item := &result.Items[i]
+		if item.ParentID == "" {
+			continue
+		}
+		chunks, ok := byDoc[item.Ref.SourcePath]
+		if !ok {
+			var err error
+			chunks, err = s.documentChunks(ctx, item.Ref.SourcePath)
+			if err != nil {
+				result.Notes = append(result.Notes, "parent section unavailable for "+item.Ref.SourcePath)
+			}
+			byDoc[item.Ref.SourcePath] = chunks
+		}
+		var parts []string
+		for j := range chunks {
+			if chunks[j].ParentID == item.ParentID {
+				parts = append(parts, chunks[j].Text)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		text := strings.Join(parts, "\n\n")
+		if runeLen(text) > parentTextMaxRunes {
+			text = string([]rune(text)[:parentTextMaxRunes]) + " …"
+		}
+		item.ParentText = s.maskSecrets(text)
+	}
+}
+
+// searchTimings accumulates per-phase durations for one search so slow
+// requests can be attributed to retrieval or scoring.
+type searchTimings struct {
+	Provider   time.Duration
+	Scoring    time.Duration
+	Candidates int
+	Filtered   int
+}
+
+// timing converts the accumulated phases into the result-facing form.
+func (tm searchTimings) timing(total time.Duration) *SearchTiming {
+	return &SearchTiming{
+		ProviderSearchMs: tm.Provider.Milliseconds(),
+		ScoringMs:        tm.Scoring.Milliseconds(),
+		TotalMs:          total.Milliseconds(),
+		CandidateCount:   tm.Candidates,
+		FilteredCount:    tm.Filtered,
+	}
+}
+
+// slowSearchFields builds the structured fields for a slow-search
+// warning, or returns nil when the search finished within the configured
+// threshold (or the threshold is unset). The query passes through secret
+// masking before it is logged.
+func (s *Service) slowSearchFields(query, mode string, total time.Duration, tm searchTimings) map[string]any {
+	threshold := time.Duration(s.cfg.SlowSearchMillis) * time.Millisecond
+	if threshold <= 0 || total < threshold {
+		return nil
+	}
+	return map[string]any{
+		"query":       s.maskSecrets(query),
+		"mode":        mode,
+		"candidates":  tm.Candidates,
+		"provider_ms": tm.Provider.Milliseconds(),
+		"scoring_ms":  tm.Scoring.Milliseconds(),
+		"total_ms":    total.Milliseconds(),
+	}
+}
+
+// searchRefTime is the reference "now" for freshness decay and relative
+// date filters: the index build time when known, so results are stable
+// between rebuilds, else the wall clock.
+func (s *Service) searchRefTime(ctx context.Context) time.Time {
+	if info, err := s.provider.LoadIndexInfo(ctx); err == nil && !info.BuiltAt.IsZero() {
+		return info.BuiltAt
+	}
+	return time.Now().UTC()
+}
+
+// resolveWithinDays rewrites the relative recency window into an
+// effective DateFrom anchored at refTime, so pushdown-capable providers
+// can apply the cutoff in-engine and the result is reproducible within
+// one index version. An explicit later DateFrom still wins, and
+// WithinDays itself stays set: the max_age check remains the
+// authoritative (and slightly stricter, to-the-hour) service-side gate.
+func resolveWithinDays(f SearchFilters, refTime time.Time) SearchFilters {
+	if f.WithinDays <= 0 {
+		return f
+	}
+	from := refTime.AddDate(0, 0, -f.WithinDays).Format("2006-01-02")
+	if f.DateFrom == "" || from > f.DateFrom {
+		f.DateFrom = from
+	}
+	return f
+}
+
+// maxPerSourceCap bounds the request-level per-source override so one
+// document can never dominate an evidence pack.
+const maxPerSourceCap = 20
+
+// perSourceCapFor resolves the effective per-source cap for a request:
+// the request override (clamped) when set, else the profile's.
+func perSourceCapFor(req SearchRequest, profile Profile) int {
+	if req.PerSourceCap <= 0 {
+		return profile.PerSourceCap
+	}
+	if req.PerSourceCap > maxPerSourceCap {
+		return maxPerSourceCap
+	}
+	return req.PerSourceCap
+}
+
+// runProfile executes retrieval and scoring for one profile and returns
+// the capped, ordered evidence items. Phase durations accumulate into tm
+// when it is non-nil.
+func (s *Service) runProfile(ctx context.Context, req SearchRequest, profile Profile, topK int, tm *searchTimings) ([]EvidenceItemFull, string, []string, error) {
+	if tm == nil {
+		tm = &searchTimings{}
+	}
+	var notes []string
+
+	mode := req.Mode
+	if mode == "" {
+		mode = profile.Mode
+	}
+	caps := s.provider.Capabilities()
+	if (mode == "semantic" || mode == "hybrid") && !caps.Semantic {
+		notes = append(notes, fmt.Sprintf("mode %q unavailable (no embedder); degraded to keyword", mode))
+		mode = "keyword"
+	}
+
+	refTime := s.searchRefTime(ctx)
+	req.Filters = resolveWithinDays(req.Filters, refTime)
+
+	// Pushdown-capable providers apply the eligible filter fields inside
+	// the engine; only the remainder is re-checked in Go below.
+	goFilters := req.Filters
+	searchOpts := ProviderSearchOptions{
+		TopN:        maxProviderCandidates,
+		Mode:        mode,
+		QueryVector: req.queryVector,
+	}
+	if caps.FilterPushdown {
+		searchOpts.Filters, goFilters = splitPushdownFilters(req.Filters, s.confRank)
+	}
+
+	providerStart := time.Now()
+	candidates, err := s.provider.Search(ctx, req.Query, searchOpts)
+	if err != nil {
+		// A corrupt index is not the caller's fault: answer with an empty
+		// pack and a rebuild hint instead of a raw unmarshal error.
+		if errors.Is(err, ErrIndexCorrupt) {
+			notes = append(notes, "index failed verification; run a full rebuild (picoclaw rag index)")
+			return nil, mode, notes, nil
+		}
+		return nil, "", nil, err
+	}
+	tm.Provider += time.Since(providerStart)
+	tm.Candidates += len(candidates)
+
+	// Filter before normalization so excluded chunks do not skew ranges.
+	filtered := candidates[:0:0]
+	riskDropped := 0
+	for _, c := range candidates {
+		if profile.RiskThreshold > 0 && c.Chunk.RiskScore >= profile.RiskThreshold {
+			riskDropped++
+			continue
+		}
+		if s.passesFilters(&c.Chunk, goFilters, refTime) {
+			filtered = append(filtered, c)
+		}
+	}
+	if riskDropped > 0 {
+		notes = append(notes,
+			fmt.Sprintf("%d chunk(s) dropped for injection risk >= %.2f", riskDropped, profile.RiskThreshold))
+	}
+	tm.Filtered += len(filtered)
+
+	scoringStart := time.Now()
+	items := s.scoreCandidates(req.Query, filtered, profile, refTime, req.Highlight)
+	if profile.DiversityLambda > 0 && len(items) > 1 {
+		items = applyMMR(items, filtered, profile.DiversityLambda)
+		notes = append(notes,
+			fmt.Sprintf("diversity re-ranking applied (lambda %.2f)", profile.DiversityLambda))
+	}
+	tm.Scoring += time.Since(scoringStart)
+
+	// Threshold, then per-source and doc-type caps while collecting top-k.
+	docTypeCap := profile.DocTypeCap
+	if req.DocTypeDiversity > 0 {
+		docTypeCap = req.DocTypeDiversity
+	}
+	perSourceCap := perSourceCapFor(req, profile)
+	excluded := make(map[ChunkRef]bool, len(req.ExcludeRefs))
+	for _, ref := range req.ExcludeRefs {
+		excluded[ref] = true
+	}
+	perSource := make(map[string]int)
+	perType := make(map[string]int)
+	var typeCapped []EvidenceItemFull
+	out := make([]EvidenceItemFull, 0, topK)
+	for _, item := range items {
+		if excluded[item.Ref] {
+			continue
+		}
+		if item.Breakdown.FinalScore < profile.MinScore {
+			continue
+		}
+		if perSourceCap > 0 && perSource[item.Ref.SourcePath] >= perSourceCap {
+			continue
+		}
+		if docTypeCap > 0 && perType[docTypeKey(item.DocType)] >= docTypeCap {
+			typeCapped = append(typeCapped, item)
+			continue
+		}
+		perSource[item.Ref.SourcePath]++
+		perType[docTypeKey(item.DocType)]++
+		out = append(out, item)
+		if len(out) >= topK {
+			break
+		}
+	}
+	// The doc-type cap is soft: refill leftover slots from capped hits in
+	// score order, then restore the overall score ordering.
+	if len(typeCapped) > 0 && len(out) < topK {
+		for _, item := range typeCapped {
+			if perSourceCap > 0 && perSource[item.Ref.SourcePath] >= perSourceCap {
+				continue
+			}
+			perSource[item.Ref.SourcePath]++
+			out = append(out, item)
+			if len(out) >= topK {
+				break
+			}
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Breakdown.FinalScore != out[j].Breakdown.FinalScore {
+				return out[i].Breakdown.FinalScore > out[j].Breakdown.FinalScore
+			}
+			return lessRef(out[i].Ref, out[j].Ref)
+		})
+	}
+	return out, mode, notes, nil
+}
+
+// docTypeKey buckets doc types case-insensitively; untyped documents form
+// their own bucket.
+func docTypeKey(docType string) string {
+	return strings.ToLower(docType)
+}
+
+// scoreCandidates normalizes the raw signals and applies the profile
+// weighting, returning items sorted by final score (descending, ties by
+// source path then ordinal for reproducibility).
+func (s *Service) scoreCandidates(query string, candidates []Candidate, profile Profile, refTime time.Time, highlight bool) []EvidenceItemFull {
+	queryTokens := tokenize(query)
+	var acronyms []string
+	if s.cfg.AcronymBoost {
+		acronyms = acronymTokens(query)
+	}
+
+	// Per-signal normalizers over the candidate set. Fused providers
+	// contribute one distribution used for both signal slots.
+	var bmVals, cosVals, fusVals []float64
+	for _, c := range candidates {
+		if c.HasFused {
+			fusVals = append(fusVals, c.RawFused)
+			continue
+		}
+		bmVals = append(bmVals, c.RawBM25)
+		if c.HasCosine {
+			cosVals = append(cosVals, c.RawCosine)
+		}
+	}
+	bmNormFn := newNormalizer(profile.ScoreNorm, bmVals)
+	cosNormFn := newNormalizer(profile.ScoreNorm, cosVals)
+	fusNormFn := newNormalizer(profile.ScoreNorm, fusVals)
+
+	// When the cosine signal is globally unavailable (keyword-only run of
+	// a hybrid profile), renormalize the remaining weights so the cosine
+	// mass is redistributed proportionally instead of silently shrinking
+	// final scores and over-weighting freshness/metadata.
+	wBM25, wCos := profile.WeightBM25, profile.WeightCosine
+	wFresh, wMeta := profile.WeightFreshness, profile.WeightMetadata
+	anyCosine := false
+	for _, c := range candidates {
+		if c.HasCosine || c.HasFused {
+			anyCosine = true
+			break
+		}
+	}
+	if !anyCosine && wCos > 0 {
+		if rest := wBM25 + wFresh + wMeta; rest > 0 {
+			scale := (rest + wCos) / rest
+			wBM25 *= scale
+			wFresh *= scale
+			wMeta *= scale
+		}
+		wCos = 0
+	}
+
+	items := make([]EvidenceItemFull, 0, len(candidates))
+	for _, c := range candidates {
+		chunk := c.Chunk
+
+		snippet := s.safeSnippet(chunk.Text)
+		var spans []TermSpan
+		if highlight {
+			// Highlight on the masked full text so redactions cannot shift
+			// the reported offsets.
+			snippet, spans = highlightSnippet(s.maskSecrets(chunk.Text), queryTokens)
+		}
+
+		var bmNorm, cosNorm float64
+		if c.HasFused {
+			fusedNorm := fusNormFn(c.RawFused)
+			bmNorm, cosNorm = fusedNorm, fusedNorm
+		} else {
+			bmNorm = bmNormFn(c.RawBM25)
+			if c.HasCosine {
+				cosNorm = cosNormFn(c.RawCosine)
+			}
+		}
+
+		fresh := freshnessNorm(chunk.Date, refTime, profile.FreshnessHalfLifeDays)
+		boost := metadataBoost(queryTokens, &chunk)
+		var acro float64
+		if len(acronyms) > 0 {
+			acro = acronymMatch(acronyms, chunk.Text)
+		}
+
+		final := wBM25*bmNorm +
+			wCos*cosNorm +
+			wFresh*fresh +
+			wMeta*boost +
+			acronymBoostWeight*acro
+		var riskPenalty float64
+		if chunk.RiskScore > 0 {
+			riskPenalty = riskPenaltyWeight * chunk.RiskScore
+			final *= 1 - riskPenalty
+		}
+
+		items = append(items, EvidenceItemFull{
+			Ref:             chunk.Ref(),
+			ParagraphID:     chunk.ParagraphID,
+			ParentID:        chunk.ParentID,
+			Title:           chunk.Title,
+			HeadingPath:     chunk.HeadingPath,
+			Snippet:         snippet,
+			Highlights:      spans,
+			DocType:         chunk.DocType,
+			Confidentiality: chunk.Confidentiality,
+			Date:            chunk.Date,
+			Tags:            chunk.Tags,
+			Flags:           chunk.Flags,
+			RiskScore:       chunk.RiskScore,
+			Breakdown: ScoreBreakdown{
+				RawBM25:       c.RawBM25,
+				RawCosine:     c.RawCosine,
+				BM25Norm:      bmNorm,
+				CosineNorm:    cosNorm,
+				FreshnessNorm: fresh,
+				MetadataBoost: boost,
+				AcronymBoost:  acro,
+				RiskPenalty:   riskPenalty,
+				FinalScore:    final,
+			},
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Breakdown.FinalScore != items[j].Breakdown.FinalScore {
+			return items[i].Breakdown.FinalScore > items[j].Breakdown.FinalScore
+		}
+		return lessRef(items[i].Ref, items[j].Ref)
+	})
+	return items
+}
+
+// newNormalizer returns the function mapping one signal's raw scores
+// into the weighting range, per the profile's ScoreNorm strategy. An
+// unknown strategy behaves as the default min-max.
+func newNormalizer(strategy string, values []float64) func(float64) float64 {
+	switch strategy {
+	case "none":
+		return func(v float64) float64 { return v }
+	case "zscore":
+		mean, stddev := meanStddev(values)
+		if stddev == 0 {
+			// Same degenerate-spread convention as min-max: no signal
+			// distribution to center on, so presence of signal decides.
+			return func(v float64) float64 { return minMaxNorm(v, math.Inf(1), math.Inf(-1)) }
+		}
+		return func(v float64) float64 {
+			return 1 / (1 + math.Exp(-(v-mean)/stddev))
+		}
+	default:
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, v := range values {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		return func(v float64) float64 { return minMaxNorm(v, min, max) }
+	}
+}
+
+// meanStddev returns the mean and population standard deviation.
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// minMaxNorm scales v into [0,1] over [min,max]. A degenerate range
+// (single candidate, or every candidate scoring the same) cannot be
+// scaled, so positive signals map to 1.0 and zero/negative signals to 0
+// — a lone candidate with no actual signal must not get a free perfect
+// score in that component.
+func minMaxNorm(v, min, max float64) float64 {
+	if math.IsInf(min, 1) || max == min {
+		if v > 0 {
+			return 1.0
+		}
+		return 0
+	}
+	return (v - min) / (max - min)
+}
+
+// freshnessNorm maps a document date to (0,1] with exponential decay:
+// a document refTime-old by one half-life scores 0.5. Undated or
+// unparseable dates score 0 so they neither benefit nor mislead.
+func freshnessNorm(date string, refTime time.Time, halfLifeDays float64) float64 {
+	if halfLifeDays <= 0 || date == "" {
+		return 0
+	}
+	t, gran, ok := parseDateGranular(date)
+	if !ok {
+		return 0
+	}
+	// Partial dates age from the end of the period they cover.
+	ageDays := refTime.Sub(periodEnd(t, gran)).Hours() / 24
+	if ageDays < 0 {
+		ageDays = 0
+	}
+	return math.Pow(0.5, ageDays/halfLifeDays)
+}
+
+// metadataBoost rewards query-term overlap with the document title and
+// tags, in [0,1].
+func metadataBoost(queryTokens []string, chunk *IndexedChunk) float64 {
+	if len(queryTokens) == 0 {
+		return 0
+	}
+	metaTokens := make(map[string]bool)
+	for _, t := range tokenize(chunk.Title) {
+		metaTokens[t] = true
+	}
+	for _, tag := range chunk.Tags {
+		for _, t := range tokenize(tag) {
+			metaTokens[t] = true
+		}
+	}
+	if len(metaTokens) == 0 {
+		return 0
+	}
+	matched := 0
+	for _, q := range queryTokens {
+		if metaTokens[q] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(queryTokens))
+}
+
+// acronymTokens extracts the all-caps tokens (at least two uppercase
+// letters, digits allowed) from the original-case query. tokenize
+// lowercases everything, so "RAG" and "rag" conflate there; these tokens
+// keep the case information for the exact-case boost.
+func acronymTokens(query string) []string {
+	var out []string
+	for _, tok := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		letters := 0
+		upper := true
+		for _, r := range tok {
+			if unicode.IsLetter(r) {
+				letters++
+				if !unicode.IsUpper(r) {
+					upper = false
+					break
+				}
+			}
+		}
+		if upper && letters >= 2 {
+			out = append(out, tok)
+		}
+	}
+	return out
+}
+
+// acronymMatch is the fraction of acronyms that appear exact-case, on
+// word boundaries, in the chunk text (which preserves original case).
+func acronymMatch(acronyms []string, text string) float64 {
+	matched := 0
+	for _, a := range acronyms {
+		if containsWord(text, a) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(acronyms))
+}
+
+// containsWord reports whether word occurs in text delimited by
+// non-alphanumeric bytes (or the text ends).
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		if (i == 0 || !isWordByte(text[i-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// defaultConfidentialityLevels is the built-in ordering, least to most
+// sensitive.
+var defaultConfidentialityLevels = []string{"public", "internal", "confidential", "restricted"}
+
+// confidentialityRank is the configured confidentiality ordering. Levels
+// compare by position; values outside the ordering have no rank and fall
+// back to exact matching where that is safe.
+type confidentialityRank struct {
+	levels []string
+	rank   map[string]int
+}
+
+// resolveConfidentialityRank builds the ordering from config, falling
+// back to the built-in levels.
+func resolveConfidentialityRank(levels []string) confidentialityRank {
+	cleaned := make([]string, 0, len(levels))
+	for _, l := range levels {
+		if l = strings.ToLower(strings.TrimSpace(l)); l != "" {
+			cleaned = append(cleaned, l)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = defaultConfidentialityLevels
+	}
+	rank := make(map[string]int, len(cleaned))
+	for i, l := range cleaned {
+		rank[l] = i
+	}
+	return confidentialityRank{levels: cleaned, rank: rank}
+}
+
+func (r confidentialityRank) rankOf(level string) (int, bool) {
+	n, ok := r.rank[strings.ToLower(level)]
+	return n, ok
+}
+
+// isTop reports whether level is the most sensitive configured level —
+// the one the AllowRestricted gate protects.
+func (r confidentialityRank) isTop(level string) bool {
+	n, ok := r.rankOf(level)
+	return ok && n == len(r.levels)-1
+}
+
+// withinCeiling reports whether level sits at or below the ceiling. A
+// chunk level outside the ordering never passes; a ceiling outside the
+// ordering admits only its exact value.
+func (r confidentialityRank) withinCeiling(level, ceiling string) bool {
+	cr, ok := r.rankOf(ceiling)
+	if !ok {
+		return strings.EqualFold(level, ceiling)
+	}
+	lr, ok := r.rankOf(level)
+	return ok && lr <= cr
+}
+
+// allows reports whether any allow-list entry admits the level: each
+// entry covers itself and everything below it, with exact matching for
+// values outside the ordering.
+func (r confidentialityRank) allows(allowed []string, level string) bool {
+	for _, a := range allowed {
+		if r.withinCeiling(level, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandAllow rewrites an allow list into the exact set of values it
+// admits, so pushdown-capable providers can keep matching by term.
+func (r confidentialityRank) expandAllow(allowed []string) []string {
+	if len(allowed) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(allowed))
+	seen := make(map[string]bool)
+	add := func(v string) {
+		if v = strings.ToLower(v); !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	for _, a := range allowed {
+		ar, ok := r.rankOf(a)
+		if !ok {
+			add(a)
+			continue
+		}
+		for _, l := range r.levels[:ar+1] {
+			add(l)
+		}
+	}
+	return out
+}
+
+// filterChecks names every hard filter so the explain pipeline can report
+// which one rejected a chunk, while passesFilters stays a short-circuit
+// over the same table.
+var filterChecks = []struct {
+	name  string
+	fails func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool
+}{
+	// The top confidentiality level is a hard gate independent of the
+	// allow list and ceiling.
+	{"restricted", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return rank.isTop(chunk.Confidentiality) && !f.AllowRestricted
+	}},
+	{"confidentiality", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		if f.MaxConfidentiality != "" && !rank.withinCeiling(chunk.Confidentiality, f.MaxConfidentiality) {
+			return true
+		}
+		return len(f.ConfidentialityAllow) > 0 && !rank.allows(f.ConfidentialityAllow, chunk.Confidentiality)
+	}},
+	{"doc_type", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return len(f.DocTypes) > 0 && !containsString(f.DocTypes, chunk.DocType)
+	}},
+	{"project", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return len(f.Projects) > 0 && !containsString(f.Projects, chunk.Project)
+	}},
+	{"tags", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return len(f.Tags) > 0 && !anyOverlap(f.Tags, chunk.Tags)
+	}},
+	{"exclude_flags", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return len(f.ExcludeFlags) > 0 && anyOverlap(f.ExcludeFlags, chunk.Flags)
+	}},
+	{"metadata", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		for k, want := range f.MetadataEquals {
+			if !strings.EqualFold(chunk.Metadata[strings.ToLower(k)], want) {
+				return true
+			}
+		}
+		for k, allowed := range f.Metadata {
+			if len(allowed) == 0 {
+				continue
+			}
+			if !containsString(allowed, chunk.Metadata[strings.ToLower(k)]) {
+				return true
+			}
+		}
+		return false
+	}},
+	{"source_prefix", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		return f.SourcePathPrefix != "" && !strings.HasPrefix(chunk.SourcePath, f.SourcePathPrefix)
+	}},
+	{"source_paths", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		if len(f.SourcePaths) == 0 {
+			return false
+		}
+		for _, p := range f.SourcePaths {
+			if chunk.SourcePath == p {
+				return false
+			}
+		}
+		return true
+	}},
+	{"date_range", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		if f.DateFrom == "" && f.DateTo == "" {
+			return false
+		}
+		t, ok := parseISODate(chunk.Date)
+		if !ok {
+			return true
+		}
+		if f.DateFrom != "" {
+			if from, ok := parseISODate(f.DateFrom); ok && t.Before(from) {
+				return true
+			}
+		}
+		if f.DateTo != "" {
+			if to, ok := parseISODate(f.DateTo); ok && t.After(to) {
+				return true
+			}
+		}
+		return false
+	}},
+	// max_age is the relative counterpart of date_range: a hard cutoff
+	// measured from the freshness reference time.
+	{"max_age", func(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) bool {
+		if f.WithinDays <= 0 {
+			return false
+		}
+		t, ok := parseISODate(chunk.Date)
+		if !ok {
+			return true
+		}
+		return refTime.Sub(t).Hours()/24 > float64(f.WithinDays)
+	}},
+}
+
+// passesFilters applies the request's hard filters to one chunk.
+func (s *Service) passesFilters(chunk *IndexedChunk, f SearchFilters, refTime time.Time) bool {
+	for _, check := range filterChecks {
+		if check.fails(chunk, f, refTime, s.confRank) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterFailures lists the names of every filter that rejects the chunk.
+func filterFailures(chunk *IndexedChunk, f SearchFilters, refTime time.Time, rank confidentialityRank) []string {
+	var failed []string
+	for _, check := range filterChecks {
+		if check.fails(chunk, f, refTime, rank) {
+			failed = append(failed, check.name)
+		}
+	}
+	return failed
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyOverlap(want, have []string) bool {
+	for _, w := range want {
+		if containsString(have, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func lessRef(a, b ChunkRef) bool {
+	if a.SourcePath != b.SourcePath {
+		return a.SourcePath < b.SourcePath
+	}
+	return a.ChunkOrdinal < b.ChunkOrdinal
+}
+
+// ClampTopK resolves a requested result count against an entry point's
+// ceiling: non-positive requests get the default, max <= 0 means the
+// library ceiling. Every entry point (service, agent tool, serve) clamps
+// through here so limits are policy, not scattered constants.
+func ClampTopK(k, max int) int {
+	if max <= 0 || max > maxServiceTopK {
+		max = maxServiceTopK
+	}
+	if k <= 0 {
+		k = defaultTopK
+	}
+	if k > max {
+		return max
+	}
+	return k
+}
+
+// clampTopK applies the service's configured ceiling.
+func (s *Service) clampTopK(k int) int {
+	return ClampTopK(k, s.maxTopK)
+}
@@ -0,0 +1,582 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// IndexStore is the on-disk format of the simple provider: the whole
+// index as one JSON document. It trades scalability for zero
+// dependencies and trivial debuggability.
+type IndexStore struct {
+	Info   IndexInfo      `json:"info"`
+	Chunks []IndexedChunk `json:"chunks"`
+	// Vectors are the chunk embeddings, parallel to Chunks; nil for
+	// keyword-only indexes. JSON-encoded floats are bulky, but so is
+	// everything about this provider.
+	Vectors [][]float32 `json:"vectors,omitempty"`
+}
+
+// simpleProvider is a linear-scan keyword provider backed by a JSON file.
+// It exists for small KBs, tests and environments where the heavier
+// providers are undesirable. Queries support double-quoted phrases and
+// trailing-* prefix terms (see parseQuery); both resolve within the same
+// linear scan — no positional or prefix index is built.
+type simpleProvider struct {
+	path     string
+	embedder Embedder
+	// tokenizer produces the tokens behind the BM25 statistics; chosen at
+	// construction (tokenizerFor) so scoring matches the service's guard.
+	tokenizer func(string) []string
+	// queryFilter drops stopwords and too-short tokens from parsed query
+	// tokens (newTokenFilter). Chunk tokenization stays unfiltered so
+	// document statistics are unaffected.
+	queryFilter func([]string) []string
+	// log receives structured events; replaced via setLogger when the
+	// owning service has an injected RAGLogger.
+	log RAGLogger
+
+	mu     sync.RWMutex
+	loaded bool
+	store  IndexStore
+	// tokens caches each chunk's token list, parallel to store.Chunks.
+	tokens [][]string
+	// docFreq counts in how many chunks each token appears, for IDF.
+	docFreq map[string]int
+	avgLen  float64
+}
+
+func newSimpleProvider(path string, embedder Embedder, cfg config.RAGToolsConfig) (*simpleProvider, error) {
+	return &simpleProvider{
+		path:        path,
+		embedder:    embedder,
+		tokenizer:   tokenizerFor(cfg.CJKBigrams),
+		queryFilter: newTokenFilter(cfg.Stopwords, cfg.MinTokenLength),
+		log:         stdRAGLogger{},
+	}, nil
+}
+
+func (p *simpleProvider) setLogger(l RAGLogger) {
+	p.log = l
+}
+
+func (p *simpleProvider) Name() string {
+	return "simple"
+}
+
+func (p *simpleProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Lexical: true, Semantic: p.embedder != nil}
+}
+
+// Build embeds the chunks (when an embedder is configured) and persists
+// everything as one JSON document.
+func (p *simpleProvider) Build(ctx context.Context, chunks []IndexedChunk, info IndexInfo) error {
+	vectors, err := p.embedChunks(ctx, chunks)
+	if err != nil {
+		return err
+	}
+	if vectors != nil {
+		info.VectorDims = len(vectors[0])
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.store = IndexStore{Info: info, Chunks: chunks, Vectors: vectors}
+	p.rebuildDerived()
+	p.loaded = true
+	return p.persistLocked()
+}
+
+// embedChunks embeds all chunk texts in batches; nil vectors when no
+// embedder is configured.
+func (p *simpleProvider) embedChunks(ctx context.Context, chunks []IndexedChunk) ([][]float32, error) {
+	if p.embedder == nil || len(chunks) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	var maxTokens int
+	if l, ok := p.embedder.(interface{ MaxBatchTokens() int }); ok {
+		maxTokens = l.MaxBatchTokens()
+	}
+	vectors := make([][]float32, 0, len(chunks))
+	for _, b := range splitEmbedBatches(texts, embedBatchSize, maxTokens) {
+		start, end := b[0], b[1]
+		batch, err := p.embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("embedding batch %d-%d: %w", start, end, err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// persistLocked writes the in-memory store to disk atomically. Callers
+// must hold the write lock.
+func (p *simpleProvider) persistLocked() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p.store)
+	if err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+// ReplaceDocument swaps one document's chunks in place, keeping the
+// overall lexical-by-source ordering a full build would produce. When
+// the index carries vectors the replacement chunks are embedded first so
+// the parallel vector list stays in lockstep.
+func (p *simpleProvider) ReplaceDocument(ctx context.Context, sourcePath string, chunks []IndexedChunk) error {
+	if err := p.ensureLoaded(); err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	hasVectors := p.store.Vectors != nil
+	p.mu.RUnlock()
+
+	// Embed outside the write lock, like a full build would.
+	var newVecs [][]float32
+	if hasVectors && p.embedder != nil {
+		var err error
+		if newVecs, err = p.embedChunks(ctx, chunks); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store.Vectors != nil {
+		if p.embedder == nil {
+			// No embedder to keep the vectors coherent: drop them and fall
+			// back to keyword-only rather than serving stale similarities.
+			p.log.Warn("Dropping stored vectors on replace (no embedder configured)",
+				map[string]any{"source": sourcePath})
+			p.store.Vectors = nil
+		} else {
+			p.store.Vectors = spliceDocumentVectors(p.store.Chunks, p.store.Vectors, sourcePath, newVecs)
+		}
+	}
+	p.store.Chunks = spliceDocumentChunks(p.store.Chunks, sourcePath, chunks)
+	refreshIndexInfo(&p.store.Info, p.store.Chunks)
+	p.rebuildDerived()
+	return p.persistLocked()
+}
+
+// RemoveDocument drops one document's chunks; removing an unindexed
+// document is a no-op so deletions are idempotent.
+func (p *simpleProvider) RemoveDocument(ctx context.Context, sourcePath string) error {
+	return p.ReplaceDocument(ctx, sourcePath, nil)
+}
+
+// ensureLoaded lazily loads the JSON index from disk.
+func (p *simpleProvider) ensureLoaded() error {
+	p.mu.RLock()
+	loaded := p.loaded
+	p.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no index found at %s: %w", p.path, ErrNoIndex)
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, &p.store); err != nil {
+		return fmt.Errorf("loading index %s: %w", p.path, err)
+	}
+	p.rebuildDerived()
+	p.loaded = true
+	return nil
+}
+
+// rebuildDerived recomputes the token cache and document frequencies.
+// Callers must hold the write lock.
+func (p *simpleProvider) rebuildDerived() {
+	p.tokens = make([][]string, len(p.store.Chunks))
+	p.docFreq = make(map[string]int)
+	var totalLen int
+	for i, c := range p.store.Chunks {
+		toks := p.tokenizer(c.Text)
+		p.tokens[i] = toks
+		totalLen += len(toks)
+		seen := make(map[string]bool, len(toks))
+		for _, t := range toks {
+			if !seen[t] {
+				seen[t] = true
+				p.docFreq[t]++
+			}
+		}
+	}
+	if len(p.store.Chunks) > 0 {
+		p.avgLen = float64(totalLen) / float64(len(p.store.Chunks))
+	}
+}
+
+// BM25 parameters; standard defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// simpleQuery is the parsed form of a simple-provider query: plain
+// tokens score via BM25, prefix terms (trailing *) match any token
+// sharing the prefix, and double-quoted phrases must appear contiguously
+// in the chunk text.
+type simpleQuery struct {
+	tokens   []string
+	prefixes []string
+	phrases  []string
+}
+
+// parseQuery splits out double-quoted phrases and trailing-star prefix
+// terms before tokenizing the rest. Phrase content also contributes its
+// tokens to scoring, so phrase hits still rank by term statistics; an
+// unbalanced quote is treated as literal text.
+func (p *simpleProvider) parseQuery(query string) simpleQuery {
+	var q simpleQuery
+	var plain strings.Builder
+	for {
+		start := strings.IndexByte(query, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(query[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		phrase := query[start+1 : start+1+end]
+		if norm := normalizePhrase(phrase); norm != "" {
+			q.phrases = append(q.phrases, norm)
+			q.tokens = append(q.tokens, p.tokenizer(phrase)...)
+		}
+		plain.WriteString(query[:start] + " ")
+		query = query[start+1+end+1:]
+	}
+	plain.WriteString(query)
+	for _, field := range strings.Fields(plain.String()) {
+		if strings.HasSuffix(field, "*") && len(field) > 1 {
+			if toks := p.tokenizer(strings.TrimSuffix(field, "*")); len(toks) > 0 {
+				q.tokens = append(q.tokens, toks[:len(toks)-1]...)
+				q.prefixes = append(q.prefixes, toks[len(toks)-1])
+				continue
+			}
+		}
+		q.tokens = append(q.tokens, p.tokenizer(field)...)
+	}
+	// Stopwords and too-short tokens never reach scoring; prefixes and
+	// phrases are explicit syntax and stay as written.
+	q.tokens = p.queryFilter(q.tokens)
+	return q
+}
+
+func (q simpleQuery) empty() bool {
+	return len(q.tokens) == 0 && len(q.prefixes) == 0 && len(q.phrases) == 0
+}
+
+// normalizePhrase lowercases and collapses whitespace so phrase matching
+// is insensitive to case and line wrapping.
+func normalizePhrase(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// prefixDocFreq counts, per query prefix, how many chunks contain at
+// least one token with that prefix. Computed per query with a pass over
+// the token cache — this provider is a linear scan by design, and the
+// extra pass keeps IDF exact for prefix terms.
+func (p *simpleProvider) prefixDocFreq(prefixes []string) map[string]int {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	df := make(map[string]int, len(prefixes))
+	for _, toks := range p.tokens {
+		for _, pre := range prefixes {
+			for _, t := range toks {
+				if strings.HasPrefix(t, pre) {
+					df[pre]++
+					break
+				}
+			}
+		}
+	}
+	return df
+}
+
+// lexicalScore computes a BM25 score for one chunk. Prefix terms use the
+// summed frequency of matching tokens with the query-time prefix
+// document frequency.
+func (p *simpleProvider) lexicalScore(query simpleQuery, prefixDF map[string]int, idx int) float64 {
+	toks := p.tokens[idx]
+	if len(toks) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(toks))
+	for _, t := range toks {
+		counts[t]++
+	}
+	n := float64(len(p.store.Chunks))
+	bm25 := func(tf, df float64) float64 {
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(len(toks))/p.avgLen))
+		return idf * norm
+	}
+	var score float64
+	for _, q := range query.tokens {
+		if tf := float64(counts[q]); tf > 0 {
+			score += bm25(tf, float64(p.docFreq[q]))
+		}
+	}
+	for _, pre := range query.prefixes {
+		var tf float64
+		for tok, c := range counts {
+			if strings.HasPrefix(tok, pre) {
+				tf += float64(c)
+			}
+		}
+		if tf > 0 {
+			score += bm25(tf, float64(prefixDF[pre]))
+		}
+	}
+	return score
+}
+
+func (p *simpleProvider) Search(ctx context.Context, query string, opts ProviderSearchOptions) ([]Candidate, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	// Embed the query outside the read lock; the result only matters when
+	// the index carries vectors. A feedback-adjusted vector, when given,
+	// replaces the query embedding.
+	var queryVec []float32
+	if opts.Mode != "keyword" {
+		queryVec = opts.QueryVector
+	}
+	if queryVec == nil && opts.Mode != "keyword" && p.embedder != nil {
+		qv, err := p.embedder.EmbedQuery(ctx, query)
+		if err != nil {
+			// Degrade to keyword-only rather than failing the search; the
+			// service notes the degradation from the missing cosine signal.
+			p.log.Warn("Query embedding failed; falling back to keyword-only",
+				map[string]any{"error": err.Error()})
+		} else {
+			queryVec = qv
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	parsed := p.parseQuery(query)
+	if parsed.empty() {
+		return nil, nil
+	}
+
+	// Quoted phrases gate every signal: a chunk missing one never becomes
+	// a candidate, not even on cosine similarity alone.
+	phraseOK := func(int) bool { return true }
+	if len(parsed.phrases) > 0 {
+		ok := make([]bool, len(p.store.Chunks))
+		for i := range p.store.Chunks {
+			norm := normalizePhrase(p.store.Chunks[i].Text)
+			ok[i] = true
+			for _, ph := range parsed.phrases {
+				if !strings.Contains(norm, ph) {
+					ok[i] = false
+					break
+				}
+			}
+		}
+		phraseOK = func(i int) bool { return ok[i] }
+	}
+
+	type scored struct {
+		idx       int
+		score     float64
+		cosine    float64
+		hasCosine bool
+	}
+	merged := make(map[int]*scored)
+	if opts.Mode != "semantic" {
+		prefixDF := p.prefixDocFreq(parsed.prefixes)
+		for i := range p.store.Chunks {
+			if !phraseOK(i) {
+				continue
+			}
+			if s := p.lexicalScore(parsed, prefixDF, i); s > 0 {
+				merged[i] = &scored{idx: i, score: s}
+			}
+		}
+	}
+	if queryVec != nil && len(p.store.Vectors) == len(p.store.Chunks) {
+		for i, v := range p.store.Vectors {
+			if !phraseOK(i) {
+				continue
+			}
+			cos := cosineSimilarity(queryVec, v)
+			if cos <= 0 {
+				continue
+			}
+			m := merged[i]
+			if m == nil {
+				m = &scored{idx: i}
+				merged[i] = m
+			}
+			m.cosine = cos
+			m.hasCosine = true
+		}
+	}
+
+	hits := make([]scored, 0, len(merged))
+	for _, m := range merged {
+		hits = append(hits, *m)
+	}
+	// Deterministic order: combined raw score descending, ties by chunk
+	// index. The service re-sorts by final score but follows our input on
+	// ties.
+	sort.Slice(hits, func(i, j int) bool {
+		si, sj := hits[i].score+hits[i].cosine, hits[j].score+hits[j].cosine
+		if si != sj {
+			return si > sj
+		}
+		return hits[i].idx < hits[j].idx
+	})
+	if opts.TopN > 0 && len(hits) > opts.TopN {
+		hits = hits[:opts.TopN]
+	}
+
+	candidates := make([]Candidate, 0, len(hits))
+	for _, h := range hits {
+		c := Candidate{
+			Chunk:     p.store.Chunks[h.idx],
+			RawBM25:   h.score,
+			RawCosine: h.cosine,
+			HasCosine: h.hasCosine,
+		}
+		if len(p.store.Vectors) == len(p.store.Chunks) {
+			c.Vector = p.store.Vectors[h.idx]
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+func (p *simpleProvider) FetchChunk(ctx context.Context, sourcePath string, ordinal int) (*IndexedChunk, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i := range p.store.Chunks {
+		c := &p.store.Chunks[i]
+		if c.SourcePath == sourcePath && c.ChunkOrdinal == ordinal {
+			out := *c
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("chunk %s#%d: %w", sourcePath, ordinal, ErrChunkNotFound)
+}
+
+// QueryVector embeds a query for feedback adjustment; the simple
+// provider stores raw embedder-space vectors, so no further mapping
+// applies.
+func (p *simpleProvider) QueryVector(ctx context.Context, query string) ([]float32, error) {
+	if p.embedder == nil {
+		return nil, fmt.Errorf("no embedder configured")
+	}
+	return p.embedder.EmbedQuery(ctx, query)
+}
+
+// VectorsForRefs resolves the stored vectors for specific chunk refs;
+// refs without one are omitted.
+func (p *simpleProvider) VectorsForRefs(ctx context.Context, refs []ChunkRef) ([][]float32, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.store.Vectors) != len(p.store.Chunks) {
+		return nil, nil
+	}
+	byRef := make(map[ChunkRef]int, len(p.store.Chunks))
+	for i := range p.store.Chunks {
+		byRef[ChunkRef{SourcePath: p.store.Chunks[i].SourcePath, ChunkOrdinal: p.store.Chunks[i].ChunkOrdinal}] = i
+	}
+	var out [][]float32
+	for _, ref := range refs {
+		if i, ok := byRef[ref]; ok && p.store.Vectors[i] != nil {
+			out = append(out, p.store.Vectors[i])
+		}
+	}
+	return out, nil
+}
+
+func (p *simpleProvider) FetchChunkByParagraphID(ctx context.Context, paragraphID string) (*IndexedChunk, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i := range p.store.Chunks {
+		if p.store.Chunks[i].ParagraphID == paragraphID {
+			out := p.store.Chunks[i]
+			return &out, nil
+		}
+	}
+	return nil, fmt.Errorf("paragraph %s: %w", paragraphID, ErrChunkNotFound)
+}
+
+func (p *simpleProvider) LoadIndexInfo(ctx context.Context) (*IndexInfo, error) {
+	if err := p.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	info := p.store.Info
+	return &info, nil
+}
+
+// ForEachChunk streams the chunks in id order for export.
+func (p *simpleProvider) ForEachChunk(fn func(id int, c IndexedChunk) error) error {
+	if err := p.ensureLoaded(); err != nil {
+		return err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i := range p.store.Chunks {
+		if err := fn(i, p.store.Chunks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *simpleProvider) Close() error {
+	return nil
+}
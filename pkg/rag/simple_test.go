@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseQuery(t *testing.T) {
+	p, err := newSimpleProvider(filepath.Join(t.TempDir(), "index.json"), nil, config.RAGToolsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query string
+		want  simpleQuery
+	}{
+		{"error budget", simpleQuery{tokens: []string{"error", "budget"}}},
+		{`"error budget" policy`, simpleQuery{
+			tokens:  []string{"error", "budget", "policy"},
+			phrases: []string{"error budget"},
+		}},
+		{"deploy* pipeline", simpleQuery{
+			tokens:   []string{"pipeline"},
+			prefixes: []string{"deploy"},
+		}},
+		{`"Error  Budget" deplo* review`, simpleQuery{
+			tokens:   []string{"error", "budget", "review"},
+			prefixes: []string{"deplo"},
+			phrases:  []string{"error budget"},
+		}},
+		// An unbalanced quote is literal text, not a phrase.
+		{`"error budget policy`, simpleQuery{tokens: []string{"error", "budget", "policy"}}},
+		// A bare star is not a prefix term.
+		{"* budget", simpleQuery{tokens: []string{"budget"}}},
+	}
+	for _, c := range cases {
+		if got := p.parseQuery(c.query); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseQuery(%q) = %+v, want %+v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestSimpleSearchPhraseAndPrefix(t *testing.T) {
+	p, err := newSimpleProvider(filepath.Join(t.TempDir(), "index.json"), nil, config.RAGToolsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	chunks := []IndexedChunk{
+		{SourcePath: "policy.md", ChunkOrdinal: 1, Text: "Error budget policy for the payments service."},
+		{SourcePath: "review.md", ChunkOrdinal: 1, Text: "The budget was under review; errors were rare."},
+		{SourcePath: "pipeline.md", ChunkOrdinal: 1, Text: "Deployment pipeline documentation."},
+	}
+	if err := p.Build(ctx, chunks, IndexInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := func(query string) []string {
+		t.Helper()
+		hits, err := p.Search(ctx, query, ProviderSearchOptions{TopN: 10, Mode: "keyword"})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		out := make([]string, 0, len(hits))
+		for _, h := range hits {
+			out = append(out, h.Chunk.SourcePath)
+		}
+		return out
+	}
+
+	// A quoted phrase requires the contiguous text: review.md has both
+	// tokens but never the phrase.
+	if got := paths(`"error budget"`); !reflect.DeepEqual(got, []string{"policy.md"}) {
+		t.Errorf("phrase query hit %v, want [policy.md]", got)
+	}
+	// Phrase matching is case- and whitespace-insensitive.
+	if got := paths("\"ERROR\n budget\""); !reflect.DeepEqual(got, []string{"policy.md"}) {
+		t.Errorf("normalized phrase query hit %v, want [policy.md]", got)
+	}
+	// Without quotes both token holders match.
+	if got := paths("error budget"); len(got) != 2 {
+		t.Errorf("plain query hit %v, want two chunks", got)
+	}
+
+	// Trailing * matches per-token prefixes.
+	if got := paths("deploy*"); !reflect.DeepEqual(got, []string{"pipeline.md"}) {
+		t.Errorf("prefix query hit %v, want [pipeline.md]", got)
+	}
+	if got := paths("deployment"); !reflect.DeepEqual(got, []string{"pipeline.md"}) {
+		t.Errorf("exact query hit %v, want [pipeline.md]", got)
+	}
+
+	// Mixed: the phrase gates, the prefix still scores.
+	if got := paths(`"error budget" polic*`); !reflect.DeepEqual(got, []string{"policy.md"}) {
+		t.Errorf("mixed query hit %v, want [policy.md]", got)
+	}
+	if got := paths(`"error budget" review*`); !reflect.DeepEqual(got, []string{"policy.md"}) {
+		t.Errorf("gated mixed query hit %v, want [policy.md]", got)
+	}
+}
@@ -0,0 +1,132 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizeText canonicalizes chunk/query text for matching: CRLF to LF,
+// lowercased, with runs of whitespace collapsed. Stored chunk text keeps
+// its original form; only matching uses the normalized view.
+func normalizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ToLower(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// tokenize splits text into lowercase tokens on any non-letter/non-digit
+// boundary. It is intentionally simple and deterministic: the same
+// tokenizer is used for the empty-query guard and the simple provider's
+// lexical scoring so behavior stays consistent.
+func tokenize(s string) []string {
+	s = strings.ToLower(s)
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// isCJK reports whether a rune belongs to a script without word-separating
+// whitespace: Han, Hiragana, Katakana or Hangul.
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// tokenizeCJK is tokenize with CJK awareness: runs of CJK characters are
+// emitted as overlapping character bigrams (the standard IR fallback when
+// no segmenter is available), everything else splits as tokenize does. A
+// lone CJK character yields itself so single-character queries still
+// match.
+func tokenizeCJK(s string) []string {
+	s = strings.ToLower(s)
+	var out []string
+	var latin, cjk []rune
+	flushLatin := func() {
+		if len(latin) > 0 {
+			out = append(out, string(latin))
+			latin = latin[:0]
+		}
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			out = append(out, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			out = append(out, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range s {
+		switch {
+		case isCJK(r):
+			flushLatin()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			flushCJK()
+			latin = append(latin, r)
+		default:
+			flushLatin()
+			flushCJK()
+		}
+	}
+	flushLatin()
+	flushCJK()
+	return out
+}
+
+// defaultStopwords is the built-in English stopword set used when none
+// is configured. Deliberately small: only words so common they carry no
+// retrieval signal on their own.
+var defaultStopwords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for",
+	"from", "has", "have", "if", "in", "is", "it", "its", "of", "on",
+	"or", "that", "the", "to", "was", "were", "will", "with",
+}
+
+// newTokenFilter builds the query-side token filter: stopword removal
+// plus a minimum rune length. A nil stopword list selects the built-in
+// English set; an explicitly empty one disables stopword filtering.
+func newTokenFilter(stopwords []string, minRunes int) func([]string) []string {
+	if stopwords == nil {
+		stopwords = defaultStopwords
+	}
+	drop := make(map[string]struct{}, len(stopwords))
+	for _, w := range stopwords {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			drop[w] = struct{}{}
+		}
+	}
+	return func(tokens []string) []string {
+		kept := make([]string, 0, len(tokens))
+		for _, tok := range tokens {
+			if utf8.RuneCountInString(tok) < minRunes {
+				continue
+			}
+			if _, ok := drop[tok]; ok {
+				continue
+			}
+			kept = append(kept, tok)
+		}
+		return kept
+	}
+}
+
+// tokenizeFiltered composes a tokenizer with the query-side token
+// filter. It applies only to query text — the searchable-token guard and
+// the simple provider's query parsing — never to chunk tokenization, so
+// document lengths and IDF are unaffected.
+func tokenizeFiltered(base func(string) []string, stopwords []string, minRunes int) func(string) []string {
+	filter := newTokenFilter(stopwords, minRunes)
+	return func(s string) []string { return filter(base(s)) }
+}
+
+// tokenizerFor selects the tokenizer for a configuration. The same
+// function must serve the empty-query guard and lexical scoring, so both
+// sides see identical tokens.
+func tokenizerFor(cjkBigrams bool) func(string) []string {
+	if cjkBigrams {
+		return tokenizeCJK
+	}
+	return tokenize
+}
@@ -0,0 +1,141 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestTokenizeCJK(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		// Latin-only text behaves exactly like the default tokenizer.
+		{"Hello, RAG world!", []string{"hello", "rag", "world"}},
+		// CJK runs become overlapping character bigrams.
+		{"数据库迁移", []string{"数据", "据库", "库迁", "迁移"}},
+		// A lone CJK character survives as a unigram.
+		{"猫", []string{"猫"}},
+		// Mixed scripts split at the boundary, each side tokenized its way.
+		{"k8s集群升级 guide", []string{"k8s", "集群", "群升", "升级", "guide"}},
+		// Punctuation still breaks runs on both sides.
+		{"迁移、备份 and restore", []string{"迁移", "备份", "and", "restore"}},
+	}
+	for _, tc := range cases {
+		if got := tokenizeCJK(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("tokenizeCJK(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	// The selector hands back the plain tokenizer when the flag is off.
+	if got := tokenizerFor(false)("数据库迁移"); !reflect.DeepEqual(got, []string{"数据库迁移"}) {
+		t.Errorf("plain tokenizer changed CJK handling: %v", got)
+	}
+}
+
+func TestTokenizeFiltered(t *testing.T) {
+	// Nil stopwords select the built-in English set.
+	def := tokenizeFiltered(tokenize, nil, 0)
+	if got := def("the error budget of the team"); !reflect.DeepEqual(got, []string{"error", "budget", "team"}) {
+		t.Errorf("default filter = %v, want [error budget team]", got)
+	}
+	if got := def("the and of"); len(got) != 0 {
+		t.Errorf("stopword-only query kept tokens: %v", got)
+	}
+
+	// A configured list replaces the default rather than extending it.
+	custom := tokenizeFiltered(tokenize, []string{"error"}, 0)
+	if got := custom("the error budget"); !reflect.DeepEqual(got, []string{"the", "budget"}) {
+		t.Errorf("custom filter = %v, want [the budget]", got)
+	}
+
+	// An explicitly empty list disables stopword filtering.
+	off := tokenizeFiltered(tokenize, []string{}, 0)
+	if got := off("the and of"); !reflect.DeepEqual(got, []string{"the", "and", "of"}) {
+		t.Errorf("disabled filter = %v, want all tokens kept", got)
+	}
+
+	// Minimum length counts runes, not bytes.
+	short := tokenizeFiltered(tokenize, []string{}, 3)
+	if got := short("go db 迁移 redis"); !reflect.DeepEqual(got, []string{"redis"}) {
+		t.Errorf("min-length filter = %v, want [redis]", got)
+	}
+}
+
+func TestSearchStopwordOnlyQuery(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"alpha.md": doc("Alpha", "2026-01-01", "Alpha document about rollouts."),
+		"bravo.md": doc("Bravo", "2026-01-02", "Bravo document about billing."),
+		"delta.md": doc("Delta", "2026-01-03", "Delta document about dashboards."),
+	})
+	ctx := context.Background()
+
+	// Every token is a stopword, so the guard rejects the query outright.
+	_, err := svc.Search(ctx, SearchRequest{Query: "the and of"})
+	if err == nil || !strings.Contains(err.Error(), "no searchable tokens") {
+		t.Errorf("stopword-only query error = %v, want no searchable tokens", err)
+	}
+
+	// Stopwords mixed into a real query are ignored, not fatal.
+	result, err := svc.Search(ctx, SearchRequest{Query: "the rollouts"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Ref.SourcePath != "alpha.md" {
+		t.Errorf("mixed query top hit = %v, want alpha.md", rankingOf(result))
+	}
+}
+
+func TestSearchCJKBigrams(t *testing.T) {
+	files := map[string]string{
+		"zh.md": doc("迁移计划", "2026-01-01", "数据库迁移计划需要先备份所有数据，然后逐步切换流量。"),
+		"en.md": doc("English Notes", "2026-01-02", "Assorted notes about database backups in English."),
+	}
+	newSvc := func(cjk bool) *Service {
+		t.Helper()
+		workspace := t.TempDir()
+		for path, content := range files {
+			full := filepath.Join(workspace, "knowledge", path)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		svc, err := NewService(workspace, config.RAGToolsConfig{IndexProvider: "simple", CJKBigrams: cjk})
+		if err != nil {
+			t.Fatalf("NewService: %v", err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		if _, err := svc.BuildIndex(context.Background()); err != nil {
+			t.Fatalf("BuildIndex: %v", err)
+		}
+		return svc
+	}
+
+	// Whole-run tokens: the sub-phrase query cannot match without bigrams.
+	plain := newSvc(false)
+	result, err := plain.Search(context.Background(), SearchRequest{Query: "数据库迁移"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 0 {
+		t.Errorf("plain tokenizer unexpectedly matched: %v", rankingOf(result))
+	}
+
+	bigrams := newSvc(true)
+	result, err = bigrams.Search(context.Background(), SearchRequest{Query: "数据库迁移"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) == 0 || result.Items[0].Ref.SourcePath != "zh.md" {
+		t.Errorf("bigram search top hit = %v, want zh.md", rankingOf(result))
+	}
+}
@@ -0,0 +1,268 @@
+package rag
+
+import "time"
+
+// ChunkRef identifies a single chunk within the indexed knowledge base.
+// SourcePath is always relative to the KB root; ChunkOrdinal is 1-based.
+type ChunkRef struct {
+	SourcePath   string `json:"source_path"`
+	ChunkOrdinal int    `json:"chunk_ordinal"`
+}
+
+// IndexedChunk is the unit stored by index providers: one markdown
+// paragraph/section plus the document metadata it inherits.
+type IndexedChunk struct {
+	SourcePath   string `json:"source_path"`
+	ChunkOrdinal int    `json:"chunk_ordinal"`
+	// ParagraphID is a stable content hash (path + normalized text) used
+	// for caching and cross-rebuild identity.
+	ParagraphID string `json:"paragraph_id"`
+	// DocumentVersion is the sha256 of the whole source file.
+	DocumentVersion string `json:"document_version"`
+
+	Text        string   `json:"text"`
+	HeadingPath []string `json:"heading_path,omitempty"`
+	ChunkLoc    string   `json:"chunk_loc,omitempty"` // "line:start-end" within the source file
+	// ParentID identifies the section this chunk belongs to: all chunks
+	// under the same heading path in one document share it, enabling
+	// small-to-big retrieval (match the child, return the parent).
+	ParentID string `json:"parent_id,omitempty"`
+
+	// Metadata inherited from frontmatter.
+	Title           string   `json:"title,omitempty"`
+	Date            string   `json:"date,omitempty"` // ISO date as written in frontmatter
+	DocType         string   `json:"doc_type,omitempty"`
+	Confidentiality string   `json:"confidentiality,omitempty"`
+	Project         string   `json:"project,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	// Metadata holds frontmatter keys not covered by the named fields
+	// above (team, status, severity, ...), available for generic filtering.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Security annotations computed at index time.
+	Flags     []string `json:"flags,omitempty"`
+	RiskScore float64  `json:"risk_score,omitempty"`
+}
+
+// Ref returns the chunk's reference.
+func (c *IndexedChunk) Ref() ChunkRef {
+	return ChunkRef{SourcePath: c.SourcePath, ChunkOrdinal: c.ChunkOrdinal}
+}
+
+// SearchFilters restrict which chunks are eligible as candidates.
+// Empty slices/strings mean "no restriction".
+type SearchFilters struct {
+	DocTypes []string `json:"doc_types,omitempty"`
+	Projects []string `json:"projects,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// ConfidentialityAllow admits each listed level and everything below
+	// it in the configured ordering; values outside the ordering fall
+	// back to exact matching.
+	ConfidentialityAllow []string `json:"confidentiality_allow,omitempty"`
+	// MaxConfidentiality is a ceiling: only chunks classified at or below
+	// this level pass. Chunks without a recognized level fail the
+	// ceiling, so unclassified documents never slip past it.
+	MaxConfidentiality string `json:"max_confidentiality,omitempty"`
+	// AllowRestricted is a hard gate: chunks at the top confidentiality
+	// level are never returned unless it is set, regardless of
+	// ConfidentialityAllow or MaxConfidentiality.
+	AllowRestricted bool   `json:"allow_restricted,omitempty"`
+	DateFrom        string `json:"date_from,omitempty"` // inclusive, ISO date
+	DateTo          string `json:"date_to,omitempty"`   // inclusive, ISO date
+	// WithinDays is a relative hard cutoff: only chunks whose document is
+	// at most this many days older than the freshness reference time pass.
+	// Undated documents fail the cutoff. 0 disables it.
+	WithinDays       int    `json:"within_days,omitempty"`
+	SourcePathPrefix string `json:"source_path_prefix,omitempty"`
+	// SourcePaths scopes the search to exactly these documents (KB-relative
+	// paths), serving the "drill into this doc" flow. Empty means all.
+	SourcePaths []string `json:"source_paths,omitempty"`
+	// MetadataEquals requires every listed custom frontmatter key to match
+	// the given value (case-insensitive).
+	MetadataEquals map[string]string `json:"metadata_equals,omitempty"`
+	// Metadata is the multi-value form of MetadataEquals: every listed key
+	// must match one of its allowed values (AND across keys, OR within a
+	// key, case-insensitive).
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	// ExcludeFlags rejects chunks carrying any of the listed injection
+	// flags (see detectInjectionRisk), e.g. "tool_call_attempt". A hard
+	// exclusion for callers that do not want to rely on the risk penalty
+	// alone.
+	ExcludeFlags []string `json:"exclude_flags,omitempty"`
+}
+
+// SearchRequest is a single retrieval request against the index.
+type SearchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k,omitempty"` // 0 means profile/service default
+	// Mode selects retrieval signals: "keyword", "semantic" or "hybrid".
+	// Empty means the profile's mode.
+	Mode    string `json:"mode,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	// BlendProfiles runs scoring under each listed profile and fuses the
+	// rankings with reciprocal rank fusion. When set, Profile is ignored.
+	BlendProfiles []string `json:"blend_profiles,omitempty"`
+	// DocTypeDiversity overrides the profile's DocTypeCap when positive:
+	// at most this many hits per doc type, softly enforced.
+	DocTypeDiversity int `json:"doc_type_diversity,omitempty"`
+	// PerSourceCap overrides the profile's PerSourceCap when positive, for
+	// tasks that legitimately want many chunks from one authoritative
+	// document. Clamped so a single document can never flood the results.
+	PerSourceCap int `json:"per_source_cap,omitempty"`
+	// Granularity selects what one result item stands for: "chunk" (the
+	// default) or "document", which collapses all of a document's matching
+	// chunks into a single item carrying the best chunk's snippet.
+	Granularity string `json:"granularity,omitempty"`
+	// ReturnParent attaches the full text of each hit's parent section
+	// (small-to-big retrieval): precise child chunks match, the broader
+	// section provides context.
+	ReturnParent bool `json:"return_parent,omitempty"`
+	// SnippetFormat controls how snippets are rendered: "raw" (default)
+	// leaves chunk text as-is, "markdown-safe" neutralizes code fences so
+	// snippets cannot break a surrounding markdown prompt, "plain"
+	// additionally collapses each snippet to a single line.
+	SnippetFormat string `json:"snippet_format,omitempty"`
+	// Highlight windows each snippet around the best keyword matches and
+	// attaches query term offsets (EvidenceItemFull.Highlights).
+	Highlight bool `json:"highlight,omitempty"`
+	// ExcludeRefs drops chunks the caller has already seen, before the
+	// caps, so lower-ranked chunks fill the top-k. Multi-turn callers use
+	// it to explore the KB without repetition.
+	ExcludeRefs []ChunkRef    `json:"exclude_refs,omitempty"`
+	Filters     SearchFilters `json:"filters,omitempty"`
+	// queryVector carries a feedback-adjusted query embedding set by
+	// SearchWithFeedback; it never travels on the wire and feedback
+	// searches bypass the result cache because of it.
+	queryVector []float32
+}
+
+// TermSpan is one query term occurrence within a snippet, as [Start, End)
+// rune offsets.
+type TermSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ScoreBreakdown records how a hit's final score was assembled so results
+// are explainable and reproducible.
+type ScoreBreakdown struct {
+	RawBM25       float64 `json:"raw_bm25"`
+	RawCosine     float64 `json:"raw_cosine"`
+	BM25Norm      float64 `json:"bm25_norm"`
+	CosineNorm    float64 `json:"cosine_norm"`
+	FreshnessNorm float64 `json:"freshness_norm"`
+	MetadataBoost float64 `json:"metadata_boost"`
+	// AcronymBoost is the exact-case match fraction for all-caps query
+	// tokens, in [0,1]; only set when the boost is enabled in config.
+	AcronymBoost float64 `json:"acronym_boost,omitempty"`
+	// RiskPenalty is the fraction shaved off the weighted score for
+	// injection risk: FinalScore = weighted * (1 - RiskPenalty).
+	RiskPenalty float64 `json:"risk_penalty,omitempty"`
+	FinalScore  float64 `json:"final_score"`
+}
+
+// EvidenceItemFull is one search hit with everything a caller could need:
+// snippet, provenance, metadata and the full score breakdown.
+type EvidenceItemFull struct {
+	Ref         ChunkRef `json:"ref"`
+	ParagraphID string   `json:"paragraph_id"`
+	ParentID    string   `json:"parent_id,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	HeadingPath []string `json:"heading_path,omitempty"`
+	Snippet     string   `json:"snippet"`
+	// Highlights are query term occurrences within Snippet, as rune
+	// offsets, present only when the request set Highlight.
+	Highlights []TermSpan `json:"highlights,omitempty"`
+	// ParentText is the hit's full parent section, present only when the
+	// request set ReturnParent.
+	ParentText      string   `json:"parent_text,omitempty"`
+	DocType         string   `json:"doc_type,omitempty"`
+	Confidentiality string   `json:"confidentiality,omitempty"`
+	Date            string   `json:"date,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Flags           []string `json:"flags,omitempty"`
+	RiskScore       float64  `json:"risk_score,omitempty"`
+	// MatchedChunks is how many ranked chunks of the document this item
+	// absorbed; set only under document granularity, where each item
+	// stands for a whole document.
+	MatchedChunks int            `json:"matched_chunks,omitempty"`
+	Breakdown     ScoreBreakdown `json:"breakdown"`
+}
+
+// SearchTiming breaks down where one search spent its time and how many
+// candidates flowed through, for diagnostics. It is surfaced on the full
+// result only; the compact LLM pack never includes it.
+type SearchTiming struct {
+	ProviderSearchMs int64 `json:"provider_search_ms"`
+	ScoringMs        int64 `json:"scoring_ms"`
+	TotalMs          int64 `json:"total_ms"`
+	// CandidateCount is how many candidates the provider returned;
+	// FilteredCount how many survived filtering into scoring.
+	CandidateCount int `json:"candidate_count"`
+	FilteredCount  int `json:"filtered_count"`
+}
+
+// SearchResult is the full evidence pack for one request.
+type SearchResult struct {
+	Query        string             `json:"query"`
+	Mode         string             `json:"mode"`
+	ProfileID    string             `json:"profile_id"`
+	IndexVersion string             `json:"index_version,omitempty"`
+	Items        []EvidenceItemFull `json:"items"`
+	// Notes carry human-readable caveats (degraded mode, fallbacks, ...).
+	Notes []string `json:"notes,omitempty"`
+	// Timing carries per-phase latency and candidate counts.
+	Timing *SearchTiming `json:"timing,omitempty"`
+}
+
+// Warning severity classes, least to most serious.
+const (
+	SeverityInfo     = "info"
+	SeverityWarn     = "warn"
+	SeveritySecurity = "security"
+)
+
+// Warning is the structured form of one build warning.
+type Warning struct {
+	// Code names what happened (e.g. "doc_hard_limit"); Path is the
+	// KB-relative document (or walk path) it concerns.
+	Code string `json:"code"`
+	Path string `json:"path,omitempty"`
+	// Severity is "info" (expected cleanup), "warn" (degraded or skipped
+	// output) or "security" (a blocked path escape).
+	Severity string `json:"severity"`
+}
+
+// IndexInfo describes one built index: provenance, configuration
+// fingerprints and build warnings.
+type IndexInfo struct {
+	IndexVersion     string    `json:"index_version"`
+	BuiltAt          time.Time `json:"built_at"`
+	IndexProvider    string    `json:"index_provider"`
+	EmbeddingModelID string    `json:"embedding_model_id,omitempty"`
+	ChunkingHash     string    `json:"chunking_hash"`
+	TotalDocuments   int       `json:"total_documents"`
+	TotalChunks      int       `json:"total_chunks"`
+	VectorDims       int       `json:"vector_dims,omitempty"`
+	// Warnings keeps the flat "code:path" strings for back-compat;
+	// StructuredWarnings carries the same entries classified by severity
+	// so callers can tell a benign skip from a security event.
+	Warnings           []string  `json:"warnings,omitempty"`
+	StructuredWarnings []Warning `json:"structured_warnings,omitempty"`
+	// Stats characterizes the indexed corpus; computed at build time.
+	Stats *CorpusStats `json:"stats,omitempty"`
+}
+
+// CorpusStats are corpus-wide aggregates that help operators understand
+// and tune a knowledge base: chunk size shape, vocabulary breadth and how
+// content distributes over doc types and confidentiality levels.
+type CorpusStats struct {
+	AvgChunkRunes    float64 `json:"avg_chunk_runes"`
+	MedianChunkRunes int     `json:"median_chunk_runes"`
+	// VocabSize is the number of unique tokens across all chunk text.
+	VocabSize int `json:"vocab_size"`
+	// DocTypes and Confidentiality count chunks per value; the empty key
+	// counts chunks without one.
+	DocTypes        map[string]int `json:"doc_types,omitempty"`
+	Confidentiality map[string]int `json:"confidentiality,omitempty"`
+}
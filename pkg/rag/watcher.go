@@ -0,0 +1,162 @@
+package rag
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce coalesces bursts of file events into one reindex.
+const watchDebounce = 2 * time.Second
+
+// Watch rebuilds the index whenever markdown under the KB root changes.
+// It blocks until ctx is canceled, then flushes any pending rebuild.
+func (s *Service) Watch(ctx context.Context) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watch every root's whole tree; fsnotify is not recursive by itself.
+	for _, root := range s.kbRoots {
+		err = filepath.WalkDir(root.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if s.isDenied(d.Name()) && path != root.path {
+					return filepath.SkipDir
+				}
+				return watcher.Add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		timer   *time.Timer
+		timerC  <-chan time.Time
+		rebuild bool
+		removed = make(map[string]bool)
+	)
+	reindex := func() {
+		if rebuild {
+			// A full rescan also covers any accumulated removals.
+			rebuild = false
+			clear(removed)
+			if _, err := s.BuildIndex(ctx); err != nil {
+				s.log.Error("Watch reindex failed", map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		// Only removals: drop the documents in place instead of rescanning
+		// the whole tree. Providers without in-place removal fall back to a
+		// rebuild.
+		for rel := range removed {
+			if err := s.RemoveDocument(ctx, rel); err != nil {
+				s.log.Warn("Targeted removal failed, rebuilding",
+					map[string]any{"source": rel, "error": err.Error()})
+				if _, err := s.BuildIndex(ctx); err != nil {
+					s.log.Error("Watch reindex failed", map[string]any{"error": err.Error()})
+				}
+				break
+			}
+		}
+		clear(removed)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if rebuild || len(removed) > 0 {
+				// Flush the outstanding work so edits made just before
+				// shutdown are not lost.
+				reindex()
+			}
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename) {
+				// If this was a watched directory it is gone; dropping the
+				// watch for a plain file is a harmless no-op error.
+				_ = watcher.Remove(event.Name)
+			}
+			if !s.isRelevantEvent(event) {
+				continue
+			}
+			// New directories need to be added to the watch set.
+			if event.Op.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = watcher.Add(event.Name)
+				}
+			}
+			if isRemovalEvent(event) {
+				if rel, ok := s.relToKBPath(event.Name); ok {
+					removed[rel] = true
+				}
+			} else {
+				rebuild = true
+			}
+			if timer == nil {
+				timer = time.NewTimer(watchDebounce)
+				timerC = timer.C
+			} else {
+				timer.Reset(watchDebounce)
+			}
+		case <-timerC:
+			if rebuild || len(removed) > 0 {
+				reindex()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			s.log.Warn("Watcher error", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// isRemovalEvent reports whether the event only takes a file away: a
+// Remove, or the old-name half of a Rename. The new name arrives as its
+// own Create event and triggers a rebuild like any other addition.
+func isRemovalEvent(event fsnotify.Event) bool {
+	if !event.Op.Has(fsnotify.Remove) && !event.Op.Has(fsnotify.Rename) {
+		return false
+	}
+	return !event.Op.Has(fsnotify.Create) && !event.Op.Has(fsnotify.Write)
+}
+
+// isRelevantEvent reports whether a file event should trigger a reindex:
+// writes/creates/removes of indexed files, ignoring editor temp files.
+func (s *Service) isRelevantEvent(event fsnotify.Event) bool {
+	if !event.Op.Has(fsnotify.Write) && !event.Op.Has(fsnotify.Create) &&
+		!event.Op.Has(fsnotify.Remove) && !event.Op.Has(fsnotify.Rename) {
+		return false
+	}
+	base := filepath.Base(event.Name)
+	if strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~") || strings.HasSuffix(base, ".tmp") {
+		return false
+	}
+	// Directory creates matter for the watch set; everything else must
+	// have an indexed extension.
+	if event.Op.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return s.isIndexedExt(event.Name)
+}
@@ -0,0 +1,126 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestIsRemovalEvent(t *testing.T) {
+	cases := []struct {
+		op   fsnotify.Op
+		want bool
+	}{
+		{fsnotify.Remove, true},
+		{fsnotify.Rename, true},
+		{fsnotify.Write, false},
+		{fsnotify.Create, false},
+		{fsnotify.Rename | fsnotify.Create, false},
+	}
+	for _, c := range cases {
+		got := isRemovalEvent(fsnotify.Event{Name: "a.md", Op: c.op})
+		if got != c.want {
+			t.Errorf("isRemovalEvent(%v) = %v, want %v", c.op, got, c.want)
+		}
+	}
+}
+
+func TestRemoveDocument(t *testing.T) {
+	svc := newTestService(t, map[string]string{
+		"keep.md": doc("Keep", "2026-01-10", "The aardvark stays indexed."),
+		"drop.md": doc("Drop", "2026-01-11", "The zebra is about to vanish."),
+	})
+
+	if err := svc.RemoveDocument(context.Background(), "drop.md"); err != nil {
+		t.Fatalf("RemoveDocument: %v", err)
+	}
+	hits, err := svc.provider.Search(context.Background(), "zebra", ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("removed document still in provider results: %d hits", len(hits))
+	}
+
+	if err := svc.RemoveDocument(context.Background(), "../outside.md"); err == nil {
+		t.Error("expected traversal guard error")
+	}
+}
+
+func TestWatchRemovesDeletedFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("watcher test waits out the debounce window")
+	}
+	svc := newTestService(t, map[string]string{
+		"keep.md": doc("Keep", "2026-01-10", "The aardvark stays indexed."),
+		"drop.md": doc("Drop", "2026-01-11", "The zebra is about to vanish."),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- svc.Watch(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Give the watcher a moment to register its watches before deleting.
+	time.Sleep(200 * time.Millisecond)
+	if err := os.Remove(filepath.Join(svc.kbRoots[0].path, "drop.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * watchDebounce)
+	for {
+		hits, err := svc.provider.Search(context.Background(), "zebra", ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deleted document still in provider results after %s", 3*watchDebounce)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	hits, err := svc.provider.Search(context.Background(), "aardvark", ProviderSearchOptions{TopN: 5, Mode: "keyword"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) == 0 {
+		t.Error("surviving document disappeared with the deleted one")
+	}
+}
+
+func TestIsRelevantEventExtensions(t *testing.T) {
+	deflt := &Service{}
+	custom := &Service{indexExts: resolveIndexExtensions([]string{".md", ".mdx", ".markdown"})}
+	cases := []struct {
+		svc  *Service
+		name string
+		op   fsnotify.Op
+		want bool
+	}{
+		{deflt, "a.md", fsnotify.Write, true},
+		{deflt, "a.mdx", fsnotify.Write, false},
+		{custom, "a.mdx", fsnotify.Write, true},
+		{custom, "a.markdown", fsnotify.Create, true},
+		{custom, "a.MDX", fsnotify.Remove, true},
+		{custom, "a.txt", fsnotify.Write, false},
+		{custom, ".hidden.mdx", fsnotify.Write, false},
+		{custom, "a.mdx~", fsnotify.Write, false},
+		{custom, "a.mdx", fsnotify.Chmod, false},
+	}
+	for _, c := range cases {
+		got := c.svc.isRelevantEvent(fsnotify.Event{Name: c.name, Op: c.op})
+		if got != c.want {
+			t.Errorf("isRelevantEvent(%q, %v) = %v, want %v", c.name, c.op, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/rag"
+)
+
+// defaultToolMaxTopK is the agent-facing ceiling on result count when not
+// configured; the library allows more but the tool keeps packs small for
+// LLM context.
+const defaultToolMaxTopK = 20
+
+// toolMaxPerSourceCap is the agent-facing ceiling on the per-source cap
+// override, stricter than the library's so evidence packs stay diverse.
+const toolMaxPerSourceCap = 5
+
+// ragResultCache is a small TTL cache for identical tool queries, so an
+// agent retrying the same search within a loop does not re-run retrieval.
+type ragResultCache struct {
+	mu      sync.Mutex
+	entries map[string]ragCacheEntry
+	maxSize int
+	ttl     time.Duration
+}
+
+type ragCacheEntry struct {
+	pack    string
+	created time.Time
+}
+
+func newRAGResultCache(maxSize int, ttl time.Duration) *ragResultCache {
+	return &ragResultCache{
+		entries: make(map[string]ragCacheEntry),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+func (c *ragResultCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.created) > c.ttl {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.pack, true
+}
+
+func (c *ragResultCache) put(key, pack string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxSize {
+		// Drop the oldest entry; the cache is small enough that a scan is fine.
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range c.entries {
+			if oldestKey == "" || e.created.Before(oldest) {
+				oldestKey, oldest = k, e.created
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+	c.entries[key] = ragCacheEntry{pack: pack, created: time.Now()}
+}
+
+// RAGSearchTool lets the agent search the indexed knowledge base.
+type RAGSearchTool struct {
+	svc     *rag.Service
+	cache   *ragResultCache
+	maxTopK int
+	// modes holds the retrieval modes the service can actually serve,
+	// captured at construction so the parameter schema matches reality
+	// instead of advertising semantic search without an embedder.
+	modes []string
+}
+
+// NewRAGSearchTool creates the knowledge search tool around a shared
+// rag.Service (built from config in the agent loop). maxTopK caps how
+// many results the agent may request; non-positive means the default.
+func NewRAGSearchTool(svc *rag.Service, maxTopK int) *RAGSearchTool {
+	if maxTopK <= 0 {
+		maxTopK = defaultToolMaxTopK
+	}
+	return &RAGSearchTool{
+		svc:     svc,
+		cache:   newRAGResultCache(32, 60*time.Second),
+		maxTopK: maxTopK,
+		modes:   svc.AvailableModes(),
+	}
+}
+
+func (t *RAGSearchTool) Name() string {
+	return "kb_search"
+}
+
+func (t *RAGSearchTool) Description() string {
+	desc := "Search the local knowledge base for relevant document chunks. Returns scored evidence snippets with source citations. Use kb_fetch to retrieve a full chunk by source_path and chunk_ordinal." +
+		" Available profiles: " + strings.Join(rag.ProfileIDs(), ", ") + "."
+	if len(t.modes) == 1 {
+		desc += " Only keyword retrieval is available (no embedder configured)."
+	}
+	return desc
+}
+
+func (t *RAGSearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Search query describing the information needed",
+			},
+			"top_k": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of results (1-%d, default 8)", t.maxTopK),
+				"minimum":     1.0,
+				"maximum":     float64(t.maxTopK),
+			},
+			"mode": map[string]any{
+				"type":        "string",
+				"description": "Retrieval mode",
+				"enum":        t.modes,
+			},
+			"profile": map[string]any{
+				"type":        "string",
+				"description": "Scoring profile, one of: " + strings.Join(rag.ProfileIDs(), ", "),
+			},
+			"doc_types": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Restrict to these doc types",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Restrict to chunks carrying any of these tags",
+			},
+			"source_paths": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Scope the search to these exact document paths (as returned by kb_search)",
+			},
+			"date_from": map[string]any{
+				"type":        "string",
+				"description": "Only documents dated on/after this ISO date (YYYY-MM-DD)",
+			},
+			"date_to": map[string]any{
+				"type":        "string",
+				"description": "Only documents dated on/before this ISO date (YYYY-MM-DD)",
+			},
+			"exclude_flags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Exclude chunks carrying any of these injection-risk flags (e.g. tool_call_attempt)",
+			},
+			"granularity": map[string]any{
+				"type":        "string",
+				"description": "What each result stands for: individual chunks (default) or whole documents, one item per document with its best chunk's snippet",
+				"enum":        []string{"chunk", "document"},
+			},
+			"per_source_cap": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Allow up to this many results from one document (1-%d; default follows the profile)", toolMaxPerSourceCap),
+				"minimum":     1.0,
+				"maximum":     float64(toolMaxPerSourceCap),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+// parseRAGFilters extracts the filter fields from tool arguments.
+func parseRAGFilters(args map[string]any) rag.SearchFilters {
+	var f rag.SearchFilters
+	f.DocTypes = stringSliceArg(args, "doc_types")
+	f.Tags = stringSliceArg(args, "tags")
+	f.SourcePaths = stringSliceArg(args, "source_paths")
+	f.ExcludeFlags = stringSliceArg(args, "exclude_flags")
+	if v, ok := args["date_from"].(string); ok {
+		f.DateFrom = v
+	}
+	if v, ok := args["date_to"].(string); ok {
+		f.DateTo = v
+	}
+	return f
+}
+
+func stringSliceArg(args map[string]any, key string) []string {
+	raw, ok := args[key].([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (t *RAGSearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, ok := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if !ok || query == "" {
+		return ErrorResult("query is required and must be a non-empty string")
+	}
+
+	topK := 0
+	if v, ok := args["top_k"].(float64); ok {
+		topK = int(v)
+	}
+	topK = rag.ClampTopK(topK, t.maxTopK)
+
+	req := rag.SearchRequest{
+		Query:   query,
+		TopK:    topK,
+		Filters: parseRAGFilters(args),
+	}
+	if v, ok := args["mode"].(string); ok {
+		req.Mode = v
+	}
+	if v, ok := args["profile"].(string); ok {
+		req.Profile = v
+	}
+	if v, ok := args["granularity"].(string); ok {
+		req.Granularity = v
+	}
+	if v, ok := args["per_source_cap"].(float64); ok && v > 0 {
+		req.PerSourceCap = int(v)
+		if req.PerSourceCap > toolMaxPerSourceCap {
+			req.PerSourceCap = toolMaxPerSourceCap
+		}
+	}
+
+	cacheKey := ragCacheKey(req)
+	if pack, hit := t.cache.get(cacheKey); hit {
+		return SilentResult(pack)
+	}
+
+	result, err := t.svc.Search(ctx, req)
+	if err != nil {
+		if err == rag.ErrQueueFull {
+			return ErrorResult(fmt.Sprintf("knowledge base busy, retry in %d seconds", t.svc.RetryAfterSeconds()))
+		}
+		return ErrorResult(fmt.Sprintf("knowledge search failed: %v", err))
+	}
+
+	pack := formatEvidencePack(result)
+	t.cache.put(cacheKey, pack)
+	return SilentResult(pack)
+}
+
+func ragCacheKey(req rag.SearchRequest) string {
+	data, _ := json.Marshal(req)
+	return string(data)
+}
+
+// formatEvidencePack renders the compact evidence pack sent to the LLM.
+func formatEvidencePack(result *rag.SearchResult) string {
+	if len(result.Items) == 0 {
+		return fmt.Sprintf("No knowledge base results for %q.", result.Query)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Knowledge base results for %q (%d hits, profile %s):\n\n",
+		result.Query, len(result.Items), result.ProfileID))
+	for i, item := range result.Items {
+		sb.WriteString(fmt.Sprintf("%d. [%s#%d] score %.3f",
+			i+1, item.Ref.SourcePath, item.Ref.ChunkOrdinal, item.Breakdown.FinalScore))
+		if item.MatchedChunks > 1 {
+			sb.WriteString(fmt.Sprintf(" (%d matching chunks)", item.MatchedChunks))
+		}
+		if item.Title != "" {
+			sb.WriteString(" — " + item.Title)
+		}
+		sb.WriteString("\n")
+		if len(item.HeadingPath) > 0 {
+			sb.WriteString("   section: " + strings.Join(item.HeadingPath, " > ") + "\n")
+		}
+		sb.WriteString("   " + strings.ReplaceAll(item.Snippet, "\n", "\n   ") + "\n\n")
+	}
+	for _, note := range result.Notes {
+		sb.WriteString("note: " + note + "\n")
+	}
+	return sb.String()
+}
+
+// RAGFetchTool retrieves one full chunk by reference, for drilling into a
+// search hit.
+type RAGFetchTool struct {
+	svc *rag.Service
+}
+
+func NewRAGFetchTool(svc *rag.Service) *RAGFetchTool {
+	return &RAGFetchTool{svc: svc}
+}
+
+func (t *RAGFetchTool) Name() string {
+	return "kb_fetch"
+}
+
+func (t *RAGFetchTool) Description() string {
+	return "Fetch the full text of one knowledge base chunk by source_path and chunk_ordinal, as returned by kb_search."
+}
+
+func (t *RAGFetchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"source_path": map[string]any{
+				"type":        "string",
+				"description": "Document path relative to the knowledge base root",
+			},
+			"chunk_ordinal": map[string]any{
+				"type":        "integer",
+				"description": "1-based chunk number within the document",
+			},
+		},
+		"required": []string{"source_path", "chunk_ordinal"},
+	}
+}
+
+func (t *RAGFetchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	sourcePath, _ := args["source_path"].(string)
+	if sourcePath == "" {
+		return ErrorResult("source_path is required")
+	}
+	ordinalF, ok := args["chunk_ordinal"].(float64)
+	if !ok || ordinalF < 1 {
+		return ErrorResult("chunk_ordinal is required and must be a positive integer")
+	}
+
+	chunk, err := t.svc.FetchChunk(ctx, sourcePath, int(ordinalF))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("fetch failed: %v", err))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[%s#%d]", chunk.SourcePath, chunk.ChunkOrdinal))
+	if chunk.Title != "" {
+		sb.WriteString(" " + chunk.Title)
+	}
+	if len(chunk.HeadingPath) > 0 {
+		sb.WriteString(" — " + strings.Join(chunk.HeadingPath, " > "))
+	}
+	sb.WriteString("\n\n" + chunk.Text)
+	return SilentResult(sb.String())
+}